@@ -0,0 +1,365 @@
+package agi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channel variables Listen and the say and listen scripts set for the dialplan
+const (
+	// VariableStatus is SUCCESS, NOMATCH when nothing was recognized, or ERROR
+	VariableStatus         = "ATTSPEECH_STATUS"
+	VariableText           = "ATTSPEECH_TEXT"
+	VariableConfidence     = "ATTSPEECH_CONFIDENCE"
+	VariableInterpretation = "ATTSPEECH_INTERPRETATION"
+	VariableError          = "ATTSPEECH_ERROR"
+)
+
+const (
+	// DefaultMaxRecording is how long Listen records for when Server.MaxRecording is not set
+	DefaultMaxRecording = 10 * time.Second
+	// DefaultSilence is how much silence ends a recording when Server.Silence is not set
+	DefaultSilence = 2 * time.Second
+)
+
+// PromptFormat is the audio Say writes for Asterisk to play, the 8 kHz signed linear of its wav format
+var PromptFormat = audio.Format{Encoding: audio.PCM, SampleRate: 8000, Channels: 1, BitsPerSample: 16}
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close
+var ErrServerClosed = errors.New("agi: server closed")
+
+// Handler handles a session, returning once the AGI script is done
+type Handler func(session *Session) error
+
+/*
+Server accepts FastAGI connections from Asterisk. Asterisk plays prompts
+and records callers from its own filesystem, so SoundDir must be shared
+with it, e.g. by running on the same host or over a network mount.
+
+Without a Handler, the AGI URL's path selects a script:
+
+	agi://host/say,<text>       speak the text, setting ATTSPEECH_STATUS
+	agi://host/listen[,<name>]  record the caller and recognize it with the SRGS grammar name
+	                            in GrammarDir, or with generic recognition when none is given
+
+Grammar names come from the dialplan, so they are read only from inside
+GrammarDir: absolute names and names leaving it with ".." are rejected.
+*/
+type Server struct {
+	Client *attspeech.Client
+	// SoundDir is where prompts are written and recordings are read, shared with Asterisk
+	SoundDir string
+	// GrammarDir holds the grammars the listen script may name, which it refuses when empty
+	GrammarDir string
+	// Voice is the voice Say uses, the API's default when empty
+	Voice string
+	// MaxRecording and Silence limit the recordings made by Listen
+	MaxRecording time.Duration
+	Silence      time.Duration
+	// Handler handles every session in place of the say and listen scripts
+	Handler Handler
+	// Logger receives the errors sessions end with, slog.Default() when nil
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	listeners []net.Listener
+}
+
+// ListenAndServe listens on the TCP address addr, 4573 being the FastAGI port, and serves until Close
+func (server *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Serve handles the connections accepted from listener, each in its own goroutine, until Close
+func (server *Server) Serve(listener net.Listener) error {
+	ctx := server.context()
+	server.mu.Lock()
+	server.listeners = append(server.listeners, listener)
+	server.mu.Unlock()
+	if ctx.Err() != nil {
+		listener.Close()
+		return ErrServerClosed
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go server.serveConn(ctx, conn)
+	}
+}
+
+// Close stops the listeners and ends every session in progress
+func (server *Server) Close() error {
+	server.context()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.cancel()
+	var err error
+	for _, listener := range server.listeners {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	server.listeners = nil
+	return err
+}
+
+// context returns the context sessions run in, canceled by Close
+func (server *Server) context() context.Context {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.ctx == nil {
+		server.ctx, server.cancel = context.WithCancel(context.Background())
+	}
+	return server.ctx
+}
+
+// serveConn runs the handler for one AGI request
+func (server *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Closing the connection unblocks a handler waiting on Asterisk
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	session, err := newSession(ctx, server, conn)
+	if err != nil {
+		server.logger().Warn("invalid AGI request", "remote", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+	handler := server.Handler
+	if handler == nil {
+		handler = server.route
+	}
+	if err := handler(session); err != nil && !errors.Is(err, ErrHangup) {
+		server.logger().Warn("AGI session failed", "script", session.Script(), "remote", session.remoteAddr(), "error", err)
+	}
+}
+
+// route runs the say or listen script named by the AGI URL
+func (server *Server) route(session *Session) error {
+	switch session.Script() {
+	case "say":
+		// Asterisk splits the application's arguments on commas, so they are joined back into the text
+		text := strings.Join(session.Args, ",")
+		if strings.TrimSpace(text) == "" {
+			return session.setError(errors.New("say requires the text to speak"))
+		}
+		if err := session.Say(text); err != nil {
+			return session.setError(err)
+		}
+		return session.SetVariable(VariableStatus, "SUCCESS")
+	case "listen":
+		grammar := ""
+		if len(session.Args) > 0 && session.Args[0] != "" {
+			data, err := server.readGrammar(session.Args[0])
+			if err != nil {
+				return session.setError(err)
+			}
+			grammar = string(data)
+		}
+		_, err := session.Listen(grammar)
+		return err
+	}
+	return session.setError(errors.New("unknown AGI script " + strconv.Quote(session.Script())))
+}
+
+// readGrammar reads the grammar name from GrammarDir, refusing names outside it
+func (server *Server) readGrammar(name string) ([]byte, error) {
+	if server.GrammarDir == "" {
+		return nil, errors.New("listen was given the grammar " + strconv.Quote(name) + " but no grammar directory is configured")
+	}
+	if !filepath.IsLocal(name) {
+		return nil, errors.New("the grammar " + strconv.Quote(name) + " must be a relative path inside the grammar directory")
+	}
+	return os.ReadFile(filepath.Join(server.GrammarDir, name))
+}
+
+// logger returns the logger for session errors
+func (server *Server) logger() *slog.Logger {
+	if server.Logger != nil {
+		return server.Logger
+	}
+	return slog.Default()
+}
+
+/*
+Say speaks text to the caller. The audio is synthesized with the server's
+voice, converted to 8 kHz signed linear and written to SoundDir, where
+it is reused by later sessions saying the same text.
+
+	err := session.Say("Welcome to Acme")
+*/
+func (session *Session) Say(text string) error {
+	server := session.server
+	apiRequest := server.Client.NewAPIRequest(server.Client.TTSResource)
+	apiRequest.Text = text
+	apiRequest.VoiceName = server.Voice
+	apiRequest.Accept = attspeech.AcceptWAV
+	prompt := filepath.Join(server.SoundDir, "tts-"+attspeech.TTSCacheKey(apiRequest, server.Client.TextChunking))
+
+	if _, err := os.Stat(prompt + ".wav"); err != nil {
+		speech, err := server.Client.Synthesize(session.ctx, apiRequest)
+		if err != nil {
+			return err
+		}
+		data, err := speech.WAV(PromptFormat)
+		if err != nil {
+			return err
+		}
+		if err := writePrompt(prompt+".wav", data); err != nil {
+			return err
+		}
+	}
+	_, err := session.StreamFile(prompt, "")
+	return err
+}
+
+// writePrompt writes a prompt through a temporary file, so Asterisk never plays one half written
+func writePrompt(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".tts-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// Asterisk may run as another user
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+/*
+Listen records the caller after a beep, until they are silent, press #
+or the recording reaches its limit, and recognizes what they said with
+the SRGS grammar, or with generic recognition when grammar is empty.
+
+The result is also set as channel variables: ATTSPEECH_STATUS is SUCCESS
+with ATTSPEECH_TEXT, ATTSPEECH_CONFIDENCE and ATTSPEECH_INTERPRETATION
+set from the best hypothesis, NOMATCH when nothing was recognized, or
+ERROR with ATTSPEECH_ERROR describing what went wrong.
+
+	recognition, err := session.Listen(grammar)
+*/
+func (session *Session) Listen(grammar string) (*attspeech.Recognition, error) {
+	recognition, err := session.listen(grammar)
+	if errors.Is(err, ErrHangup) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, session.setError(err)
+	}
+	return recognition, session.setRecognition(recognition)
+}
+
+// listen records the caller and recognizes the recording
+func (session *Session) listen(grammar string) (*attspeech.Recognition, error) {
+	server := session.server
+	maxRecording, silence := server.MaxRecording, server.Silence
+	if maxRecording <= 0 {
+		maxRecording = DefaultMaxRecording
+	}
+	if silence <= 0 {
+		silence = DefaultSilence
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	recording := filepath.Join(server.SoundDir, "rec-"+hex.EncodeToString(id))
+	defer os.Remove(recording + ".wav")
+
+	reply, err := session.Command("RECORD FILE", recording, "wav", "#",
+		strconv.FormatInt(maxRecording.Milliseconds(), 10), "BEEP", "s="+strconv.Itoa(int(silence.Round(time.Second)/time.Second)))
+	if err != nil {
+		return nil, err
+	}
+	if reply.Result < 0 {
+		if reply.Data == "hangup" {
+			return nil, ErrHangup
+		}
+		return nil, errors.New("agi: could not record " + recording)
+	}
+	data, err := os.ReadFile(recording + ".wav")
+	if err != nil {
+		return nil, err
+	}
+
+	if grammar == "" {
+		apiRequest := server.Client.NewAPIRequest(server.Client.STTResource)
+		apiRequest.Data = bytes.NewBuffer(data)
+		apiRequest.ContentType = audio.ContentTypeWAV
+		return server.Client.SpeechToTextContext(session.ctx, apiRequest)
+	}
+	apiRequest := server.Client.NewAPIRequest(server.Client.STTCResource)
+	apiRequest.Data = bytes.NewBuffer(data)
+	apiRequest.ContentType = audio.ContentTypeWAV
+	apiRequest.Filename = filepath.Base(recording) + ".wav"
+	return server.Client.SpeechToTextCustomContext(session.ctx, apiRequest, grammar, "")
+}
+
+// setRecognition sets the channel variables for the best hypothesis of a recognition
+func (session *Session) setRecognition(recognition *attspeech.Recognition) error {
+	result := recognition.Recognition
+	if result.Status != "OK" || len(result.NBest) == 0 {
+		return session.setVariables(VariableStatus, "NOMATCH", VariableText, "", VariableConfidence, "", VariableInterpretation, "")
+	}
+	best := result.NBest[0]
+	interpretation := ""
+	if len(best.NluHypothesis.OutComposite) > 0 {
+		interpretation = best.NluHypothesis.OutComposite[0].Out
+	}
+	return session.setVariables(
+		VariableStatus, "SUCCESS",
+		VariableText, best.ResultText,
+		VariableConfidence, strconv.FormatFloat(float64(best.Confidence), 'f', 3, 32),
+		VariableInterpretation, interpretation,
+	)
+}
+
+// setError sets the ERROR status for err and returns it
+func (session *Session) setError(err error) error {
+	if setErr := session.setVariables(VariableStatus, "ERROR", VariableError, err.Error()); setErr != nil {
+		return errors.Join(err, setErr)
+	}
+	return err
+}
+
+// setVariables sets channel variables given as name and value pairs
+func (session *Session) setVariables(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if err := session.SetVariable(pairs[i], pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package agi
+
+import (
+	"bufio"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wav returns a second of 16 kHz PCM, as the API synthesizes it
+func wav(sampleRate int) []byte {
+	buffer := &audio.Buffer{SampleRate: sampleRate, Channels: 1, Samples: make([]int16, sampleRate)}
+	data, _ := buffer.WAV(audio.PCM)
+	return data
+}
+
+/*
+dialAsterisk starts an AGI request to the server at addr for script and
+answers its commands: RECORD FILE writes a recording, every other command
+succeeds. It returns the commands once the server ends the request.
+*/
+func dialAsterisk(addr string, script string, args ...string) []string {
+	conn, err := net.Dial("tcp", addr)
+	So(err, ShouldBeNil)
+	defer conn.Close()
+	env := "agi_network: yes\nagi_network_script: " + script + "\n"
+	for i, arg := range args {
+		env += "agi_arg_" + string(rune('1'+i)) + ": " + arg + "\n"
+	}
+	conn.Write([]byte(env + "\n"))
+	commands := []string{}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return commands
+		}
+		command := strings.TrimSuffix(line, "\n")
+		commands = append(commands, command)
+		if strings.HasPrefix(command, "RECORD FILE ") {
+			os.WriteFile(strings.Fields(command)[2]+".wav", wav(8000), 0644)
+		}
+		conn.Write([]byte("200 result=0\n"))
+	}
+}
+
+func TestServer(t *testing.T) {
+	Convey("Serving FastAGI requests", t, func() {
+		var synthesized atomic.Int32
+		uploads := make(chan string, 1)
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			if req.URL.Path == attspeech.TTSResource {
+				synthesized.Add(1)
+				w.Write(wav(16000))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			uploads <- req.URL.Path + " " + string(body)
+			if strings.Contains(string(body), "nomatch") {
+				w.Write([]byte(`{"Recognition":{"Status":"Speech Not Recognized","NBest":[]}}`))
+				return
+			}
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"sales","Confidence":0.9,
+				"NluHypothesis":{"OutComposite":[{"Grammar":"menu","Out":"SALES"}]}}]}}`))
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+
+		dir := t.TempDir()
+		server := &Server{Client: client, SoundDir: dir, GrammarDir: dir, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(listener) }()
+		defer server.Close()
+		addr := listener.Addr().String()
+
+		Convey("Should play synthesized prompts, converted and cached for Asterisk", func() {
+			commands := dialAsterisk(addr, "say", "Hello", " world")
+			So(len(commands), ShouldEqual, 2)
+			So(commands[0], ShouldStartWith, "STREAM FILE "+dir+"/tts-")
+			So(commands[1], ShouldEqual, "SET VARIABLE ATTSPEECH_STATUS SUCCESS")
+			prompt := strings.Fields(commands[0])[2]
+			data, err := os.ReadFile(prompt + ".wav")
+			So(err, ShouldBeNil)
+			header, _ := audio.ParseWAVHeader(data)
+			So(header.Format, ShouldResemble, PromptFormat)
+			So(header.Duration(), ShouldEqual, time.Second)
+
+			So(dialAsterisk(addr, "say", "Hello", " world")[0], ShouldEqual, commands[0])
+			So(synthesized.Load(), ShouldEqual, 1)
+		})
+		Convey("Should recognize recordings with a grammar and set the result", func() {
+			grammar := dir + "/menu.srgs"
+			os.WriteFile(grammar, []byte("<grammar>sales</grammar>"), 0644)
+			commands := dialAsterisk(addr, "listen", "menu.srgs")
+			So(commands[0], ShouldStartWith, "RECORD FILE "+dir+"/rec-")
+			So(commands[0], ShouldEndWith, " wav # 10000 BEEP s=2")
+			So(commands[1:], ShouldResemble, []string{
+				"SET VARIABLE ATTSPEECH_STATUS SUCCESS",
+				"SET VARIABLE ATTSPEECH_TEXT sales",
+				"SET VARIABLE ATTSPEECH_CONFIDENCE 0.900",
+				"SET VARIABLE ATTSPEECH_INTERPRETATION SALES",
+			})
+			upload := <-uploads
+			So(upload, ShouldStartWith, attspeech.STTCResource)
+			So(upload, ShouldContainSubstring, "<grammar>sales</grammar>")
+			_, err := os.Stat(strings.Fields(commands[0])[2] + ".wav")
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+		Convey("Should use generic recognition without a grammar", func() {
+			dialAsterisk(addr, "listen")
+			So(<-uploads, ShouldStartWith, attspeech.STTResource)
+		})
+		Convey("Should report nothing recognized as NOMATCH", func() {
+			os.WriteFile(dir+"/nomatch.srgs", []byte("<grammar>nomatch</grammar>"), 0644)
+			commands := dialAsterisk(addr, "listen", "nomatch.srgs")
+			<-uploads
+			So(commands[1], ShouldEqual, "SET VARIABLE ATTSPEECH_STATUS NOMATCH")
+		})
+		Convey("Should report errors as channel variables", func() {
+			commands := dialAsterisk(addr, "listen", "missing.srgs")
+			So(commands[0], ShouldEqual, "SET VARIABLE ATTSPEECH_STATUS ERROR")
+			So(commands[1], ShouldStartWith, "SET VARIABLE ATTSPEECH_ERROR \"open "+dir+"/missing.srgs")
+			So(dialAsterisk(addr, "dance")[1], ShouldEqual, `SET VARIABLE ATTSPEECH_ERROR "unknown AGI script \"dance\""`)
+		})
+		Convey("Should only read grammars from the grammar directory", func() {
+			os.WriteFile(dir+"/menu.srgs", []byte("<grammar>sales</grammar>"), 0644)
+			for _, name := range []string{dir + "/menu.srgs", "../menu.srgs", "grammars/../../menu.srgs"} {
+				commands := dialAsterisk(addr, "listen", name)
+				So(commands[0], ShouldEqual, "SET VARIABLE ATTSPEECH_STATUS ERROR")
+				So(commands[1], ShouldContainSubstring, "must be a relative path inside the grammar directory")
+			}
+			unconfigured := &Server{Client: client, SoundDir: dir, Logger: server.Logger}
+			listener, _ := net.Listen("tcp", "127.0.0.1:0")
+			go unconfigured.Serve(listener)
+			defer unconfigured.Close()
+			commands := dialAsterisk(listener.Addr().String(), "listen", "menu.srgs")
+			So(commands[1], ShouldContainSubstring, "no grammar directory is configured")
+		})
+		Convey("Should run a custom handler", func() {
+			custom := &Server{Client: client, SoundDir: dir, Handler: func(session *Session) error {
+				return session.Verbose("hello "+session.Script(), 1)
+			}}
+			listener, _ := net.Listen("tcp", "127.0.0.1:0")
+			go custom.Serve(listener)
+			defer custom.Close()
+			So(dialAsterisk(listener.Addr().String(), "custom"), ShouldResemble, []string{`VERBOSE "hello custom" 1`})
+		})
+		Convey("Should stop serving once closed", func() {
+			So(server.Close(), ShouldBeNil)
+			So(<-served, ShouldEqual, ErrServerClosed)
+		})
+	})
+}
@@ -0,0 +1,250 @@
+/*
+Package agi is a FastAGI server that lets Asterisk dialplans speak and
+listen through the AT&T Speech API:
+
+	server := &agi.Server{Client: client, SoundDir: "/var/lib/asterisk/sounds/attspeech"}
+	log.Fatal(server.ListenAndServe(":4573"))
+
+	exten => 100,1,Answer()
+	 same => n,AGI(agi://127.0.0.1/say,Welcome to Acme. Say sales or support.)
+	 same => n,AGI(agi://127.0.0.1/listen,/etc/asterisk/grammars/menu.srgs)
+	 same => n,GotoIf($["${ATTSPEECH_TEXT}" = "sales"]?sales,1)
+
+Handlers written in Go use the Session directly, calling Say, Listen and
+any other AGI command.
+*/
+package agi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Reply is the response to an AGI command, e.g. 200 result=1 (timeout) endpos=8000
+type Reply struct {
+	Code   int
+	Result int
+	// Data is the text in parentheses after the result, e.g. the value of GET VARIABLE
+	Data string
+	// Line is the full response line
+	Line string
+}
+
+// ErrHangup is returned by commands sent after the caller has hung up
+var ErrHangup = errors.New("agi: the channel has hung up")
+
+// Session is one AGI request from Asterisk, lasting until the script ends
+type Session struct {
+	// Env holds the agi_ variables Asterisk sent, without their prefix, e.g. Env["callerid"]
+	Env map[string]string
+	// Args are the arguments given to the AGI application after the URL
+	Args []string
+
+	server *Server
+	ctx    context.Context
+	conn   io.ReadWriter
+	reader *bufio.Reader
+	hungUp bool
+}
+
+// newSession reads the AGI environment Asterisk sends at the start of a request
+func newSession(ctx context.Context, server *Server, conn io.ReadWriter) (*Session, error) {
+	session := &Session{
+		Env:    make(map[string]string),
+		Args:   []string{},
+		server: server,
+		ctx:    ctx,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	for {
+		line, err := session.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok || !strings.HasPrefix(name, "agi_") {
+			return nil, errors.New("agi: invalid environment line " + strconv.Quote(line))
+		}
+		session.Env[strings.TrimPrefix(name, "agi_")] = value
+	}
+	for i := 1; ; i++ {
+		arg, ok := session.Env["arg_"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		session.Args = append(session.Args, arg)
+	}
+	return session, nil
+}
+
+// Context is done when the server shuts down or the connection to Asterisk closes
+func (session *Session) Context() context.Context {
+	return session.ctx
+}
+
+// Script returns the path of the AGI URL, e.g. "listen" for agi://host/listen
+func (session *Session) Script() string {
+	return strings.Trim(session.Env["network_script"], "/")
+}
+
+/*
+Command sends an AGI command with its arguments, quoting those that need
+it, and returns the reply. Replies other than 200 are returned as errors.
+
+	reply, err := session.Command("STREAM FILE", "beep", "#")
+*/
+func (session *Session) Command(command string, args ...string) (*Reply, error) {
+	if session.hungUp {
+		return nil, ErrHangup
+	}
+	line := command
+	for _, arg := range args {
+		line += " " + quote(arg)
+	}
+	if _, err := io.WriteString(session.conn, line+"\n"); err != nil {
+		return nil, err
+	}
+	return session.readReply()
+}
+
+// readReply reads the reply to a command, skipping the HANGUP notice Asterisk may send first
+func (session *Session) readReply() (*Reply, error) {
+	line, err := session.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "HANGUP" {
+		session.hungUp = true
+		if line, err = session.readLine(); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(line, "520-") {
+		// Usage follows until the end of the multi-line reply
+		for !strings.HasPrefix(line, "520 ") {
+			if line, err = session.readLine(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, errors.New("agi: invalid command syntax")
+	}
+	return parseReply(line)
+}
+
+// parseReply parses a reply line such as 200 result=1 (timeout) endpos=8000
+func parseReply(line string) (*Reply, error) {
+	reply := &Reply{Line: line}
+	code, rest, _ := strings.Cut(line, " ")
+	var err error
+	if reply.Code, err = strconv.Atoi(code); err != nil {
+		return nil, errors.New("agi: invalid reply " + strconv.Quote(line))
+	}
+	switch reply.Code {
+	case 200:
+	case 511:
+		return nil, ErrHangup
+	default:
+		return nil, errors.New("agi: " + line)
+	}
+	result, rest, _ := strings.Cut(rest, " ")
+	if !strings.HasPrefix(result, "result=") {
+		return nil, errors.New("agi: invalid reply " + strconv.Quote(line))
+	}
+	if reply.Result, err = strconv.Atoi(strings.TrimPrefix(result, "result=")); err != nil {
+		return nil, errors.New("agi: invalid reply " + strconv.Quote(line))
+	}
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.LastIndex(rest, ")"); end > 0 {
+			reply.Data = rest[1:end]
+		}
+	}
+	return reply, nil
+}
+
+// readLine reads a line without its line ending
+func (session *Session) readLine() (string, error) {
+	line, err := session.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != io.EOF {
+			return "", err
+		}
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+/*
+quote quotes an argument that is empty or contains spaces or quotes. AGI
+has no escape for line breaks, which would end the command early, so they
+are replaced with spaces.
+*/
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"\\\r\n") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", " ", "\r", " ", "\n", " ").Replace(arg) + `"`
+}
+
+// Answer answers the channel
+func (session *Session) Answer() error {
+	_, err := session.Command("ANSWER")
+	return err
+}
+
+// Hangup hangs up the channel
+func (session *Session) Hangup() error {
+	_, err := session.Command("HANGUP")
+	return err
+}
+
+// SetVariable sets a channel variable for the dialplan to use after the AGI returns
+func (session *Session) SetVariable(name string, value string) error {
+	_, err := session.Command("SET VARIABLE", name, value)
+	return err
+}
+
+// GetVariable returns the value of a channel variable, and false when it is not set
+func (session *Session) GetVariable(name string) (string, bool, error) {
+	reply, err := session.Command("GET VARIABLE", name)
+	if err != nil {
+		return "", false, err
+	}
+	return reply.Data, reply.Result == 1, nil
+}
+
+// StreamFile plays a sound file, given without its extension, returning the digit pressed to interrupt it or 0
+func (session *Session) StreamFile(file string, escapeDigits string) (rune, error) {
+	reply, err := session.Command("STREAM FILE", file, escapeDigits)
+	if err != nil {
+		return 0, err
+	}
+	if reply.Result < 0 {
+		return 0, errors.New("agi: could not play " + file)
+	}
+	return rune(reply.Result), nil
+}
+
+// Verbose logs a message to the Asterisk console at level 1 to 4
+func (session *Session) Verbose(message string, level int) error {
+	_, err := session.Command("VERBOSE", message, strconv.Itoa(level))
+	return err
+}
+
+// remoteAddr returns the address of the Asterisk server, when the session is on a network connection
+func (session *Session) remoteAddr() string {
+	if conn, ok := session.conn.(net.Conn); ok {
+		return conn.RemoteAddr().String()
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package agi
+
+import (
+	"bufio"
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeAsterisk answers each command read from conn with the next reply
+func fakeAsterisk(conn net.Conn, env string, replies ...string) chan string {
+	commands := make(chan string, len(replies))
+	go func() {
+		defer close(commands)
+		conn.Write([]byte(env + "\n"))
+		reader := bufio.NewReader(conn)
+		for _, reply := range replies {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			commands <- strings.TrimSuffix(line, "\n")
+			conn.Write([]byte(reply + "\n"))
+		}
+	}()
+	return commands
+}
+
+func TestSession(t *testing.T) {
+	Convey("Talking AGI to Asterisk", t, func() {
+		client, asterisk := net.Pipe()
+		defer client.Close()
+		defer asterisk.Close()
+		env := "agi_network: yes\nagi_network_script: listen\nagi_callerid: 5551234\nagi_arg_1: /tmp/menu.srgs\nagi_arg_2: fast\n"
+
+		Convey("Should read the environment and arguments", func() {
+			fakeAsterisk(asterisk, env)
+			session, err := newSession(context.Background(), nil, client)
+			So(err, ShouldBeNil)
+			So(session.Env["callerid"], ShouldEqual, "5551234")
+			So(session.Script(), ShouldEqual, "listen")
+			So(session.Args, ShouldResemble, []string{"/tmp/menu.srgs", "fast"})
+		})
+		Convey("Should reject an invalid environment", func() {
+			fakeAsterisk(asterisk, "GET / HTTP/1.1\n")
+			_, err := newSession(context.Background(), nil, client)
+			So(err.Error(), ShouldEqual, `agi: invalid environment line "GET / HTTP/1.1"`)
+		})
+		Convey("Should quote arguments and parse replies", func() {
+			commands := fakeAsterisk(asterisk, env, "200 result=1 (sales) endpos=8000")
+			session, _ := newSession(context.Background(), nil, client)
+			reply, err := session.Command("SET VARIABLE", "ATTSPEECH_TEXT", `say "hi"`)
+			So(err, ShouldBeNil)
+			So(<-commands, ShouldEqual, `SET VARIABLE ATTSPEECH_TEXT "say \"hi\""`)
+			So(reply.Code, ShouldEqual, 200)
+			So(reply.Result, ShouldEqual, 1)
+			So(reply.Data, ShouldEqual, "sales")
+		})
+		Convey("Should keep line breaks from ending a command early", func() {
+			commands := fakeAsterisk(asterisk, env, "200 result=1")
+			session, _ := newSession(context.Background(), nil, client)
+			_, err := session.Command("SET VARIABLE", "ATTSPEECH_ERROR", "bad\r\nEXEC Hangup\nnow")
+			So(err, ShouldBeNil)
+			So(<-commands, ShouldEqual, `SET VARIABLE ATTSPEECH_ERROR "bad EXEC Hangup now"`)
+			So(quote("a\rb"), ShouldEqual, `"a b"`)
+		})
+		Convey("Should return variables and the digits that interrupt files", func() {
+			fakeAsterisk(asterisk, env, "200 result=1 (SIP/100)", "200 result=0", "200 result=35 endpos=400")
+			session, _ := newSession(context.Background(), nil, client)
+			value, ok, err := session.GetVariable("CHANNEL")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, "SIP/100")
+			_, ok, _ = session.GetVariable("MISSING")
+			So(ok, ShouldBeFalse)
+			digit, err := session.StreamFile("beep", "#")
+			So(err, ShouldBeNil)
+			So(digit, ShouldEqual, '#')
+		})
+		Convey("Should report hangups", func() {
+			fakeAsterisk(asterisk, env, "HANGUP\n200 result=1")
+			session, _ := newSession(context.Background(), nil, client)
+			So(session.Answer(), ShouldBeNil)
+			So(session.Answer(), ShouldEqual, ErrHangup)
+		})
+		Convey("Should report dead channels and invalid commands", func() {
+			fakeAsterisk(asterisk, env, "511 Command Not Permitted on a dead channel",
+				"520-Invalid command syntax.  Proper usage follows:\nUsage: ANSWER\n520 End of proper usage.", "510 Invalid or unknown command")
+			session, _ := newSession(context.Background(), nil, client)
+			So(session.Answer(), ShouldEqual, ErrHangup)
+			So(session.Answer().Error(), ShouldEqual, "agi: invalid command syntax")
+			So(session.Answer().Error(), ShouldEqual, "agi: 510 Invalid or unknown command")
+		})
+	})
+}
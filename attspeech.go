@@ -2,8 +2,11 @@ package attspeech
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/jsgoecke/attspeech/audio"
+	"github.com/jsgoecke/attspeech/ssml"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
@@ -11,6 +14,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -62,29 +66,87 @@ SetAuthTokens sets the provided authorization tokens for the client
 	client.SetAuthTokens()
 */
 func (client *Client) SetAuthTokens() error {
+	return client.SetAuthTokensContext(context.Background())
+}
+
+/*
+SetAuthTokensContext is SetAuthTokens with a context to cancel the requests
+or their rate limiting, and to parent the spans traced for them
+*/
+func (client *Client) SetAuthTokensContext(ctx context.Context) error {
+	tokens, err := client.FetchAuthTokens(ctx)
+	if err != nil {
+		return err
+	}
+	client.Tokens = tokens
+	return nil
+}
+
+/*
+FetchAuthTokens requests a token for every scope and returns them by scope
+without storing them, so a caller sharing the client can fetch fresh
+tokens without locking and swap them in once they arrive
+
+	tokens, err := client.FetchAuthTokens(ctx)
+	if err == nil {
+		mu.Lock()
+		client.Tokens = tokens
+		mu.Unlock()
+	}
+*/
+func (client *Client) FetchAuthTokens(ctx context.Context) (tokens map[string]*Token, err error) {
+	ctx, span := client.startSpan(ctx, "SetAuthTokens", ResourceKey.String(client.OauthResource))
+	defer func() { endSpan(span, err) }()
+
 	data := "grant_type=client_credentials&"
 	data += "client_id=" + client.ID + "&"
 	data += "client_secret=" + client.Secret + "&"
 	data += "scope="
 
-	m := make(map[string]*Token)
+	tokens = make(map[string]*Token)
 	for _, scope := range client.Scope {
-		req, _ := http.NewRequest("POST", client.APIBase+OauthResource+"?"+data+scope, nil)
-		res, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return err
-		}
-		body, _ := ioutil.ReadAll(res.Body)
-		res.Body.Close()
-		token := &Token{}
-		err = json.Unmarshal(body, token)
+		token, err := client.fetchToken(ctx, scope, data+scope)
+		client.observeTokenRefresh(scope, err)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		m[scope] = token
+		tokens[scope] = token
 	}
-	client.Tokens = m
-	return nil
+	return tokens, nil
+}
+
+// fetchToken requests the token for one scope
+func (client *Client) fetchToken(ctx context.Context, scope string, query string) (token *Token, err error) {
+	ctx, span := client.startSpan(ctx, "FetchToken", ResourceKey.String(client.OauthResource), ScopeKey.String(scope))
+	defer func() { endSpan(span, err) }()
+	release, err := client.acquire(ctx, client.OauthResource)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, "POST", client.APIBase+client.OauthResource+"?"+query, nil)
+	client.injectTraceContext(ctx, req)
+	client.logRequest(ctx, client.OauthResource, req, nil, 0)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		client.observeRequest(client.OauthResource, 0, start)
+		// The URL holds the client secret, so it is redacted before the error is logged or returned
+		err = redactError(err)
+		client.logResponse(ctx, client.OauthResource, 0, nil, time.Since(start), err)
+		return nil, err
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	client.observeRequest(client.OauthResource, res.StatusCode, start)
+	client.logResponse(ctx, client.OauthResource, res.StatusCode, body, time.Since(start), nil)
+	span.SetAttributes(StatusCodeKey.Int(res.StatusCode))
+	token = &Token{}
+	err = json.Unmarshal(body, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
 }
 
 /*
@@ -94,7 +156,7 @@ SpeechToText converts an audio file to text
 	client.SetAuthTokens()
 	apiRequest := client.NewAPIRequest(STTResource)
 	apiRequest.Data = data // where data is audio content as *bytes.Buffer
-	apiRequest.ContentType = "audio/wav"
+	apiRequest.ContentType = "audio/wav" // sniffed from data if left empty
 	result, apiError, err := client.SpeechToText(apiRequest)
 
 More details available here:
@@ -102,14 +164,35 @@ More details available here:
 	http://developer.att.com/apis/speech/docs#resources-speech-to-text
 */
 func (client *Client) SpeechToText(apiRequest *APIRequest) (*Recognition, error) {
+	return client.SpeechToTextContext(context.Background(), apiRequest)
+}
+
+/*
+SpeechToTextContext is SpeechToText with a context to cancel the request
+or its rate limiting, and to parent the spans traced for it
+*/
+func (client *Client) SpeechToTextContext(ctx context.Context, apiRequest *APIRequest) (recognition *Recognition, err error) {
+	ctx, span := client.startSpan(ctx, "SpeechToText", ResourceKey.String(client.STTResource))
+	defer func() { endSpan(span, err) }()
+
+	sniffContentType(apiRequest)
 	if apiRequest.ContentType == "" {
 		return nil, errors.New("a content type must be provided")
 	}
 	if apiRequest.Data == nil {
 		return nil, errors.New("data to convert to text must be provided")
 	}
+	key, cached := client.cachedRecognition(apiRequest, "", "")
+	span.SetAttributes(CacheHitKey.Bool(cached != nil))
+	if cached != nil {
+		return cached, nil
+	}
+	if err := client.checkAudio(apiRequest, audio.STT); err != nil {
+		return nil, err
+	}
+	client.observeUpload(ctx, client.STTResource, apiRequest)
 
-	body, statusCode, err := client.post(client.STTResource, apiRequest.Data, apiRequest)
+	body, statusCode, err := client.post(ctx, client.STTResource, apiRequest.Data, apiRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -119,14 +202,10 @@ func (client *Client) SpeechToText(apiRequest *APIRequest) (*Recognition, error)
 		if err != nil {
 			return nil, err
 		}
+		client.storeRecognition(key, body)
 		return recognition, nil
 	}
-	apiError := &APIError{}
-	err = json.Unmarshal(body, apiError)
-	if err != nil {
-		return nil, apiError.generateErr()
-	}
-	return nil, apiError.generateErr()
+	return nil, client.apiError(ctx, client.STTResource, statusCode, body)
 }
 
 /*
@@ -146,6 +225,17 @@ More details available here:
 
 */
 func (client *Client) SpeechToTextCustom(apiRequest *APIRequest, grammar string, dictionary string) (*Recognition, error) {
+	return client.SpeechToTextCustomContext(context.Background(), apiRequest, grammar, dictionary)
+}
+
+/*
+SpeechToTextCustomContext is SpeechToTextCustom with a context to cancel
+the request or its rate limiting, and to parent the spans traced for it
+*/
+func (client *Client) SpeechToTextCustomContext(ctx context.Context, apiRequest *APIRequest, grammar string, dictionary string) (recognition *Recognition, err error) {
+	ctx, span := client.startSpan(ctx, "SpeechToTextCustom", ResourceKey.String(client.STTCResource))
+	defer func() { endSpan(span, err) }()
+
 	if grammar == "" {
 		return nil, errors.New("a grammar must be provided")
 	}
@@ -155,29 +245,35 @@ func (client *Client) SpeechToTextCustom(apiRequest *APIRequest, grammar string,
 	if apiRequest.Filename == "" {
 		return nil, errors.New("filename must be provided")
 	}
+	sniffContentType(apiRequest)
 	if apiRequest.ContentType == "" {
 		return nil, errors.New("content type must be provided")
 	}
+	key, cached := client.cachedRecognition(apiRequest, grammar, dictionary)
+	span.SetAttributes(CacheHitKey.Bool(cached != nil))
+	if cached != nil {
+		return cached, nil
+	}
+	if err := client.checkAudio(apiRequest, audio.STTC); err != nil {
+		return nil, err
+	}
+	client.observeUpload(ctx, client.STTCResource, apiRequest)
 
 	apiRequest.Data, apiRequest.ContentType = buildForm(apiRequest, grammar, dictionary)
-	body, statusCode, err := client.post(client.STTCResource, apiRequest.Data, apiRequest)
+	body, statusCode, err := client.post(ctx, client.STTCResource, apiRequest.Data, apiRequest)
 	if err != nil {
 		return nil, err
 	}
-	apiError := &APIError{}
 	if statusCode == 200 {
 		recognition := &Recognition{}
 		err := json.Unmarshal(body, recognition)
 		if err != nil {
-			return nil, apiError.generateErr()
+			return nil, (&APIError{}).generateErr()
 		}
+		client.storeRecognition(key, body)
 		return recognition, nil
 	}
-	err = json.Unmarshal(body, apiError)
-	if err != nil {
-		return nil, apiError.generateErr()
-	}
-	return nil, apiError.generateErr()
+	return nil, client.apiError(ctx, client.STTCResource, statusCode, body)
 }
 
 /*
@@ -192,28 +288,63 @@ TextToSpeech converts text to a speech file
 	apiRequest.Text = "I want to be an airborne ranger, I want to live the life of danger.",
 	data, err := client.TextToSpeech(apiRequest)
 
+Text that is an SSML document, such as one made with ssml.NewBuilder, is
+validated and sent as application/ssml+xml.
+
 More details available here:
 
 	http://developer.att.com/apis/speech/docs#resources-text-to-speech
 */
 func (client *Client) TextToSpeech(apiRequest *APIRequest) ([]byte, error) {
+	return client.TextToSpeechContext(context.Background(), apiRequest)
+}
+
+/*
+TextToSpeechContext is TextToSpeech with a context to cancel the request
+or its rate limiting, and to parent the spans traced for it
+*/
+func (client *Client) TextToSpeechContext(ctx context.Context, apiRequest *APIRequest) (data []byte, err error) {
+	ctx, span := client.startSpan(ctx, "TextToSpeech", ResourceKey.String(client.TTSResource), VoiceKey.String(apiRequest.VoiceName))
+	defer func() { endSpan(span, err) }()
+
 	if apiRequest.Text == "" {
 		return nil, errors.New("text to convert to speech must be provided")
 	}
+	if err := checkVoice(apiRequest); err != nil {
+		return nil, err
+	}
+	if err := checkProsody(apiRequest); err != nil {
+		return nil, err
+	}
+	if err := prepareSSML(apiRequest); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(ContentTypeKey.String(apiRequest.ContentType))
+	if client.TTSCache != nil {
+		return client.cachedTextToSpeech(ctx, apiRequest)
+	}
+	return client.synthesize(ctx, apiRequest)
+}
 
-	body, statusCode, err := client.post(client.TTSResource, bytes.NewBuffer([]byte(apiRequest.Text)), apiRequest)
+// synthesize sends text in one request, or in chunks when client.TextChunking is set
+func (client *Client) synthesize(ctx context.Context, apiRequest *APIRequest) ([]byte, error) {
+	if client.TextChunking != nil {
+		return client.textToSpeechChunks(ctx, apiRequest, client.TextChunking)
+	}
+	return client.textToSpeech(ctx, apiRequest)
+}
+
+// textToSpeech sends a single Text to Speech request
+func (client *Client) textToSpeech(ctx context.Context, apiRequest *APIRequest) ([]byte, error) {
+	body, statusCode, err := client.post(ctx, client.TTSResource, bytes.NewBuffer([]byte(apiRequest.Text)), apiRequest)
 	if err != nil {
 		return nil, err
 	}
 	if statusCode == 200 {
+		client.observeSynthesis(ctx, client.TTSResource, apiRequest.Text, body)
 		return body, nil
 	}
-	apiError := &APIError{}
-	err = json.Unmarshal(body, apiError)
-	if err != nil {
-		return nil, apiError.generateErr()
-	}
-	return nil, apiError.generateErr()
+	return nil, client.apiError(ctx, client.TTSResource, statusCode, body)
 }
 
 /*
@@ -250,22 +381,130 @@ func (client *Client) NewAPIRequest(resource string) *APIRequest {
 	return apiRequest
 }
 
-// post to the AT&T Speech API
-func (client *Client) post(resource string, body *bytes.Buffer, apiRequest *APIRequest) ([]byte, int, error) {
-	req, err := http.NewRequest("POST", client.APIBase+resource, body)
+/*
+post to the AT&T Speech API, waiting for the resource's rate limit first.
+The request has its own span, so the time spent waiting and uploading can
+be told apart from the rest of an operation.
+*/
+func (client *Client) post(ctx context.Context, resource string, body io.Reader, apiRequest *APIRequest) (respBody []byte, statusCode int, err error) {
+	ctx, span := client.startSpan(ctx, "POST", ResourceKey.String(resource))
+	defer func() { endSpan(span, err) }()
+	release, err := client.acquire(ctx, resource)
 	if err != nil {
 		return nil, 0, err
 	}
+	defer release()
+	req, err := http.NewRequestWithContext(ctx, "POST", client.APIBase+resource, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if req.ContentLength == 0 && req.Body != nil && req.Body != http.NoBody {
+		// A stream of unknown length, sent with chunked transfer encoding
+		req.ContentLength = -1
+	}
 	apiRequest.setHeaders(req)
+	client.injectTraceContext(ctx, req)
+	client.logRequest(ctx, resource, req, apiRequest, req.ContentLength)
+	start := time.Now()
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		client.observeRequest(resource, 0, start)
+		client.logResponse(ctx, resource, 0, nil, time.Since(start), err)
 		return nil, 0, err
 	}
-	respBody, _ := ioutil.ReadAll(resp.Body)
+	respBody, _ = ioutil.ReadAll(resp.Body)
 	defer resp.Body.Close()
+	client.observeRequest(resource, resp.StatusCode, start)
+	client.logResponse(ctx, resource, resp.StatusCode, respBody, time.Since(start), nil)
+	span.SetAttributes(StatusCodeKey.Int(resp.StatusCode))
 	return respBody, resp.StatusCode, nil
 }
 
+// sniffContentType detects the content type from the audio when none was provided
+func sniffContentType(apiRequest *APIRequest) {
+	if apiRequest.ContentType == "" && apiRequest.Data != nil {
+		apiRequest.ContentType = audio.Sniff(apiRequest.Data.Bytes())
+	}
+}
+
+/*
+checkAudio parses the header of WAV audio and checks it against the
+endpoint's requirements, so unsupported audio is rejected before upload.
+When client.TranscodeAudio is set, unsupported audio is first converted
+to a format the endpoint accepts.
+*/
+func (client *Client) checkAudio(apiRequest *APIRequest, requirements *audio.Requirements) error {
+	if !isWAV(apiRequest.ContentType) {
+		return nil
+	}
+	header, err := audio.ParseWAVHeader(apiRequest.Data.Bytes())
+	if err != nil {
+		return errors.New("invalid WAV audio: " + err.Error())
+	}
+	err = requirements.Check(header)
+	if err == nil || !client.TranscodeAudio {
+		return err
+	}
+
+	data, err := audio.Transcode(apiRequest.Data.Bytes(), requirements)
+	if err != nil {
+		return errors.New("could not transcode WAV audio: " + err.Error())
+	}
+	apiRequest.Data = bytes.NewBuffer(data)
+	header, err = audio.ParseWAVHeader(data)
+	if err != nil {
+		return err
+	}
+	return requirements.Check(header)
+}
+
+/*
+prepareSSML sends text that is an SSML document as application/ssml+xml
+rather than the default text/plain, and validates SSML before it is sent
+*/
+func prepareSSML(apiRequest *APIRequest) error {
+	contentType := mediaType(apiRequest.ContentType)
+	if (contentType == "" || contentType == "text/plain") && ssml.IsSSML(apiRequest.Text) {
+		apiRequest.ContentType = ssml.ContentType
+		contentType = ssml.ContentType
+	}
+	if contentType != ssml.ContentType {
+		return nil
+	}
+	return ssml.Validate(apiRequest.Text)
+}
+
+// isWAV reports whether contentType is one of the WAV content types
+func isWAV(contentType string) bool {
+	switch mediaType(contentType) {
+	case "audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave":
+		return true
+	}
+	return false
+}
+
+// mediaType returns a content type without its parameters, in lower case
+func mediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+/*
+apiError turns an error response from the API into a *ServiceError,
+recording its MessageId. A body that is not JSON still produces an error,
+as the STTC endpoint does not always return valid JSON.
+*/
+func (client *Client) apiError(ctx context.Context, resource string, statusCode int, body []byte) error {
+	apiError := &APIError{}
+	json.Unmarshal(body, apiError)
+	messageID := client.observeAPIError(ctx, resource, apiError)
+	return &ServiceError{StatusCode: statusCode, MessageID: messageID, err: apiError.generateErr()}
+}
+
+// Error returns the message of the error returned by the API
+func (serviceError *ServiceError) Error() string {
+	return serviceError.err.Error()
+}
+
 // generateErr takes the APIError and turns it into a Go error
 func (apiError *APIError) generateErr() error {
 	msg := apiError.RequestError.ServiceException.MessageID + " - "
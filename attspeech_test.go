@@ -2,7 +2,10 @@ package attspeech
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"github.com/jsgoecke/attspeech/audio"
 	. "github.com/smartystreets/goconvey/convey"
 	"io"
 	"io/ioutil"
@@ -11,6 +14,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestClient(t *testing.T) {
@@ -122,6 +126,17 @@ func TestGetTokens(t *testing.T) {
 			So(client.Tokens[scope].RefreshToken, ShouldEqual, "456")
 		}
 	})
+	Convey("Should fetch tokens without storing them", t, func() {
+		ts := serveHTTP(t)
+		client := New("foo", "bar", "")
+		client.APIBase = ts.URL
+		tokens, err := client.FetchAuthTokens(context.Background())
+
+		So(err, ShouldBeNil)
+		So(len(tokens), ShouldEqual, 3)
+		So(tokens["TTS"].AccessToken, ShouldEqual, "123")
+		So(client.Tokens, ShouldBeNil)
+	})
 }
 
 func TestSpeechToText(t *testing.T) {
@@ -196,6 +211,69 @@ func TestSpeechToText(t *testing.T) {
 	})
 }
 
+func TestCheckAudio(t *testing.T) {
+	Convey("Should check WAV audio before it is uploaded", t, func() {
+		ts := serveHTTP(t)
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		apiRequest := client.NewAPIRequest(STTResource)
+		apiRequest.ContentType = "audio/x-wav"
+
+		Convey("Should reject stereo audio with a descriptive error", func() {
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 2, 44100, 16, 100))
+			response, err := client.SpeechToText(apiRequest)
+			So(response, ShouldBeNil)
+			So(err.Error(), ShouldEqual, "speech to text does not accept PCM 16-bit 44100 Hz stereo audio: the audio must be mono")
+		})
+		Convey("Should reject data that is not a WAV file", func() {
+			apiRequest.Data = bytes.NewBuffer([]byte("foobar"))
+			_, err := client.SpeechToText(apiRequest)
+			So(err.Error(), ShouldEqual, "invalid WAV audio: not a RIFF/WAV file")
+		})
+		Convey("Should not check other content types", func() {
+			apiRequest.ContentType = "audio/amr"
+			apiRequest.Data = bytes.NewBuffer([]byte("foobar"))
+			So(client.checkAudio(apiRequest, nil), ShouldBeNil)
+		})
+		Convey("Should transcode unsupported audio when enabled", func() {
+			client.TranscodeAudio = true
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 2, 44100, 16, 44100))
+			So(client.checkAudio(apiRequest, audio.STT), ShouldBeNil)
+			header, err := audio.ParseWAVHeader(apiRequest.Data.Bytes())
+			So(err, ShouldBeNil)
+			So(header.Format, ShouldResemble, audio.Format{Encoding: audio.PCM, SampleRate: 16000, Channels: 1, BitsPerSample: 16})
+			So(header.Duration(), ShouldEqual, time.Second)
+		})
+	})
+}
+
+func TestSniffContentType(t *testing.T) {
+	Convey("Should detect the content type when none is provided", t, func() {
+		ts := serveHTTP(t)
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		apiRequest := client.NewAPIRequest(STTResource)
+
+		Convey("Should sniff WAV audio", func() {
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 2, 44100, 16, 100))
+			_, err := client.SpeechToText(apiRequest)
+			So(apiRequest.ContentType, ShouldEqual, "audio/wav")
+			So(err.Error(), ShouldEqual, "speech to text does not accept PCM 16-bit 44100 Hz stereo audio: the audio must be mono")
+		})
+		Convey("Should keep an explicit content type", func() {
+			apiRequest.ContentType = "audio/amr"
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 1, 8000, 16, 100))
+			sniffContentType(apiRequest)
+			So(apiRequest.ContentType, ShouldEqual, "audio/amr")
+		})
+		Convey("Should still require a content type for unknown audio", func() {
+			apiRequest.Data = bytes.NewBuffer([]byte("foobar"))
+			_, err := client.SpeechToText(apiRequest)
+			So(err.Error(), ShouldEqual, "a content type must be provided")
+		})
+	})
+}
+
 func TestBuildForm(t *testing.T) {
 	ts := serveHTTP(t)
 	client := New(os.Getenv("ATT_APP_KEY"), os.Getenv("ATT_APP_SECRET"), "")
@@ -315,6 +393,47 @@ func TestTextToSpeech(t *testing.T) {
 	})
 }
 
+func TestTextToSpeechSSML(t *testing.T) {
+	Convey("Should send SSML to Text to Speech", t, func() {
+		var contentType, body string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			contentType = req.Header.Get("Content-Type")
+			data, _ := ioutil.ReadAll(req.Body)
+			body = string(data)
+			w.Write([]byte("RIFF"))
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		apiRequest := client.NewAPIRequest(TTSResource)
+
+		Convey("Should send SSML documents as application/ssml+xml", func() {
+			apiRequest.Text = `<speak version="1.0">Hello<break time="500ms"/>world</speak>`
+			_, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(contentType, ShouldEqual, "application/ssml+xml")
+			So(body, ShouldEqual, apiRequest.Text)
+		})
+		Convey("Should keep sending plain text as text/plain", func() {
+			apiRequest.Text = "1 < 2"
+			_, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(contentType, ShouldEqual, "text/plain")
+		})
+		Convey("Should reject invalid SSML before sending it", func() {
+			apiRequest.ContentType = "application/ssml+xml"
+			apiRequest.Text = `<speak version="1.0"><audio src="a.wav"/></speak>`
+			_, err := client.TextToSpeech(apiRequest)
+			So(err.Error(), ShouldEqual, "unsupported SSML element <audio>")
+			So(contentType, ShouldBeBlank)
+		})
+	})
+}
+
 func TestGenerateErr(t *testing.T) {
 	Convey("Should generate error messages", t, func() {
 		Convey("ServiceException", func() {
@@ -383,6 +502,27 @@ func serveHTTP(t *testing.T) *httptest.Server {
 	}))
 }
 
+// wavBytes builds a WAV file with the given format and number of silent frames
+func wavBytes(encoding uint16, channels int, sampleRate int, bitsPerSample int, frames int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+	dataSize := frames * blockAlign
+	data := &bytes.Buffer{}
+	data.WriteString("RIFF")
+	binary.Write(data, binary.LittleEndian, uint32(36+dataSize))
+	data.WriteString("WAVEfmt ")
+	binary.Write(data, binary.LittleEndian, uint32(16))
+	binary.Write(data, binary.LittleEndian, encoding)
+	binary.Write(data, binary.LittleEndian, uint16(channels))
+	binary.Write(data, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(data, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(data, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(data, binary.LittleEndian, uint16(bitsPerSample))
+	data.WriteString("data")
+	binary.Write(data, binary.LittleEndian, uint32(dataSize))
+	data.Write(make([]byte, dataSize))
+	return data.Bytes()
+}
+
 func checkHeaders(t *testing.T, req *http.Request) {
 	Convey("Default headers should be set", t, func() {
 		So(req.Header.Get("X-Arg"), ShouldEqual, "ClientApp=GoLibForATTSpeech,ClientVersion=0.1,DeviceType=amd64,DeviceOs=darwin")
@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// amrNoData is an AMR storage format frame header with frame type 15, no data, which decoders play as 20ms of silence
+const amrNoData = 0x7C
+
+/*
+Concat joins WAV or AMR files into one file, inserting silence between
+them. WAV files must share a format and are written with a header
+describing the combined data; AMR silence is made of 20ms no-data frames.
+
+	joined, err := audio.Concat([][]byte{first, second}, 250*time.Millisecond)
+*/
+func Concat(parts [][]byte, silence time.Duration) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("no audio to join")
+	}
+	switch contentType := Sniff(parts[0]); contentType {
+	case ContentTypeWAV:
+		return concatWAV(parts, silence)
+	case ContentTypeAMR, ContentTypeAMRWB:
+		return concatAMR(parts, silence, contentType)
+	}
+	return nil, errors.New("only WAV and AMR audio can be joined")
+}
+
+// concatWAV joins the data chunks of WAV files sharing a format
+func concatWAV(parts [][]byte, silence time.Duration) ([]byte, error) {
+	headers := make([]*Header, len(parts))
+	for i, part := range parts {
+		header, err := ParseWAVHeader(part)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && header.Format != headers[0].Format {
+			return nil, errors.New("cannot join " + headers[0].Format.String() + " and " + header.Format.String() + " audio")
+		}
+		headers[i] = header
+	}
+	format := headers[0].Format
+	gap, err := silenceBytes(format, frames(silence, format.SampleRate)*headers[0].BlockAlign)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &bytes.Buffer{}
+	for i, part := range parts {
+		if i > 0 {
+			data.Write(gap)
+		}
+		data.Write(part[headers[i].DataOffset : headers[i].DataOffset+headers[i].DataSize])
+	}
+	wav := &bytes.Buffer{}
+	writeWAVHeader(wav, format, data.Len())
+	wav.Write(data.Bytes())
+	if data.Len()%2 == 1 {
+		wav.WriteByte(0)
+	}
+	return wav.Bytes(), nil
+}
+
+// silenceBytes returns size bytes of silence in format
+func silenceBytes(format Format, size int) ([]byte, error) {
+	var value byte
+	switch {
+	case format.Encoding == PCM && format.BitsPerSample == 8:
+		// 8-bit PCM is unsigned
+		value = 0x80
+	case format.Encoding == PCM, format.Encoding == Float:
+		value = 0
+	case format.Encoding == MuLaw:
+		value = 0xFF
+	case format.Encoding == ALaw:
+		value = 0xD5
+	default:
+		return nil, errors.New("cannot insert silence into " + format.String() + " audio")
+	}
+	return bytes.Repeat([]byte{value}, size), nil
+}
+
+// concatAMR joins AMR storage format files, keeping only the first file's magic number
+func concatAMR(parts [][]byte, silence time.Duration, contentType string) ([]byte, error) {
+	magic := "#!AMR\n"
+	if contentType == ContentTypeAMRWB {
+		magic = "#!AMR-WB\n"
+	}
+	gap := bytes.Repeat([]byte{amrNoData}, int(silence/(20*time.Millisecond)))
+
+	joined := &bytes.Buffer{}
+	joined.WriteString(magic)
+	for i, part := range parts {
+		if !bytes.HasPrefix(part, []byte(magic)) {
+			return nil, errors.New("cannot join AMR audio with other audio")
+		}
+		if i > 0 {
+			joined.Write(gap)
+		}
+		joined.Write(part[len(magic):])
+	}
+	return joined.Bytes(), nil
+}
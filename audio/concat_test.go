@@ -0,0 +1,51 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConcat(t *testing.T) {
+	Convey("Joining audio files", t, func() {
+		Convey("Should join WAV files with silence and a header for the combined data", func() {
+			first, _ := tone(8000, 440, 10000, time.Second).WAV(PCM)
+			second, _ := tone(8000, 440, 10000, 500*time.Millisecond).WAV(PCM)
+			joined, err := Concat([][]byte{first, second}, 250*time.Millisecond)
+			So(err, ShouldBeNil)
+			header, err := ParseWAVHeader(joined)
+			So(err, ShouldBeNil)
+			So(header.Duration(), ShouldEqual, 1750*time.Millisecond)
+			So(len(joined), ShouldEqual, header.DataOffset+header.DataSize)
+			buffer, _ := Decode(joined)
+			So(buffer.Samples[8000:10000], ShouldResemble, make([]int16, 2000))
+		})
+		Convey("Should insert µ-law silence", func() {
+			data, _ := os.ReadFile("../test/test.wav")
+			joined, err := Concat([][]byte{data, data}, 100*time.Millisecond)
+			So(err, ShouldBeNil)
+			header, _ := ParseWAVHeader(joined)
+			So(header.Format, ShouldResemble, Format{MuLaw, 8000, 1, 8})
+			So(header.Duration(), ShouldEqual, 23220*time.Millisecond)
+			So(joined[header.DataOffset+92480], ShouldEqual, 0xFF)
+		})
+		Convey("Should reject WAV files in different formats", func() {
+			first, _ := tone(8000, 440, 10000, time.Second).WAV(PCM)
+			second, _ := tone(16000, 440, 10000, time.Second).WAV(PCM)
+			_, err := Concat([][]byte{first, second}, 0)
+			So(err.Error(), ShouldEqual, "cannot join PCM 16-bit 8000 Hz mono and PCM 16-bit 16000 Hz mono audio")
+		})
+		Convey("Should join AMR files with no-data frames as silence", func() {
+			joined, err := Concat([][]byte{[]byte("#!AMR\n\x3c1"), []byte("#!AMR\n\x3c2")}, 40*time.Millisecond)
+			So(err, ShouldBeNil)
+			So(string(joined), ShouldEqual, "#!AMR\n\x3c1\x7c\x7c\x3c2")
+			_, err = Concat([][]byte{[]byte("#!AMR\n\x3c1"), []byte("#!AMR-WB\n\x24")}, 0)
+			So(err.Error(), ShouldEqual, "cannot join AMR audio with other audio")
+		})
+		Convey("Should reject other audio", func() {
+			_, err := Concat([][]byte{[]byte("fLaC")}, 0)
+			So(err.Error(), ShouldEqual, "only WAV and AMR audio can be joined")
+		})
+	})
+}
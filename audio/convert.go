@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"errors"
+)
+
+/*
+Convert decodes a WAV file and re-encodes it as a WAV file in format,
+downmixing to mono and resampling as needed. Only PCM (16-bit), MuLaw
+and ALaw output with one channel, or the source's channels, is supported.
+
+	converted, err := audio.Convert(data, audio.Format{Encoding: audio.PCM, SampleRate: 16000, Channels: 1, BitsPerSample: 16})
+*/
+func Convert(data []byte, format Format) ([]byte, error) {
+	buffer, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Convert(format)
+}
+
+/*
+ConvertRaw is Convert producing headerless samples, as telephony
+platforms often expect, rather than a WAV file
+
+	ulaw, err := audio.ConvertRaw(data, audio.Format{Encoding: audio.MuLaw, SampleRate: 8000, Channels: 1, BitsPerSample: 8})
+*/
+func ConvertRaw(data []byte, format Format) ([]byte, error) {
+	buffer, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.ConvertRaw(format)
+}
+
+// Convert re-encodes the audio as a WAV file in format
+func (buffer *Buffer) Convert(format Format) ([]byte, error) {
+	converted, err := buffer.convert(format)
+	if err != nil {
+		return nil, err
+	}
+	return converted.WAV(format.Encoding)
+}
+
+// ConvertRaw re-encodes the audio as headerless samples in format
+func (buffer *Buffer) ConvertRaw(format Format) ([]byte, error) {
+	converted, err := buffer.convert(format)
+	if err != nil {
+		return nil, err
+	}
+	return converted.Raw(format.Encoding)
+}
+
+// convert downmixes and resamples the audio for format
+func (buffer *Buffer) convert(format Format) (*Buffer, error) {
+	switch {
+	case format.Channels == 1:
+		buffer = buffer.Mono()
+	case format.Channels != buffer.Channels:
+		return nil, errors.New("audio can only be converted to mono or its original channels")
+	}
+	if format.Encoding == PCM && format.BitsPerSample != 16 || format.Encoding != PCM && format.BitsPerSample != 8 {
+		return nil, errors.New("converting to " + format.String() + " audio is not supported")
+	}
+	return buffer.Resample(format.SampleRate), nil
+}
+
+/*
+Transcode converts a WAV file to a format the requirements accept,
+returning data unchanged if it is already acceptable. The highest
+supported sample rate not above the source's is chosen, so no bandwidth
+is invented by upsampling, preferring 16-bit PCM at that rate.
+
+	data, err := audio.Transcode(data, audio.STT)
+*/
+func Transcode(data []byte, requirements *Requirements) ([]byte, error) {
+	header, err := ParseWAVHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if requirements.checkFormat(header) == nil {
+		return data, nil
+	}
+	target, ok := requirements.Target(header.Format)
+	if !ok {
+		return nil, errors.New(requirements.Endpoint + " has no format audio can be converted to")
+	}
+	return Convert(data, target)
+}
+
+/*
+Target returns the format that audio in source is best converted to for
+the requirements, or false if Convert cannot produce any supported format.
+Source is returned as is when it is already supported.
+*/
+func (requirements *Requirements) Target(source Format) (Format, bool) {
+	for _, format := range requirements.Formats {
+		if format == source && convertible(format) {
+			return format, true
+		}
+	}
+	var best Format
+	found := false
+	for _, format := range requirements.Formats {
+		if !convertible(format) {
+			continue
+		}
+		if !found || better(format, best, source.SampleRate) {
+			best = format
+			found = true
+		}
+	}
+	return best, found
+}
+
+// convertible reports whether Convert can produce format
+func convertible(format Format) bool {
+	switch format.Encoding {
+	case PCM:
+		return format.BitsPerSample == 16 && format.Channels == 1
+	case MuLaw, ALaw:
+		return format.BitsPerSample == 8 && format.Channels == 1
+	}
+	return false
+}
+
+// better reports whether candidate is a better conversion target than current for audio at sampleRate
+func better(candidate Format, current Format, sampleRate int) bool {
+	candidateFits := candidate.SampleRate <= sampleRate
+	currentFits := current.SampleRate <= sampleRate
+	if candidateFits != currentFits {
+		return candidateFits
+	}
+	if candidate.SampleRate != current.SampleRate {
+		// Among rates that fit prefer the highest, otherwise the closest above
+		if candidateFits {
+			return candidate.SampleRate > current.SampleRate
+		}
+		return candidate.SampleRate < current.SampleRate
+	}
+	return candidate.Encoding == PCM && current.Encoding != PCM
+}
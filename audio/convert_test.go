@@ -0,0 +1,119 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestResample(t *testing.T) {
+	Convey("Resampling audio", t, func() {
+		Convey("Should keep tones below the new Nyquist frequency", func() {
+			buffer := tone(44100, 1000, 10000, time.Second).Resample(16000)
+			So(buffer.SampleRate, ShouldEqual, 16000)
+			So(buffer.Frames(), ShouldEqual, 16000)
+			So(rms(buffer), ShouldAlmostEqual, 10000/math.Sqrt2, 300)
+		})
+		Convey("Should filter tones above the new Nyquist frequency instead of aliasing them", func() {
+			buffer := tone(44100, 7000, 10000, time.Second).Resample(8000)
+			So(rms(buffer), ShouldBeLessThan, 100)
+		})
+		Convey("Should upsample", func() {
+			buffer := tone(8000, 440, 10000, time.Second).Resample(16000)
+			So(buffer.Frames(), ShouldEqual, 16000)
+			So(rms(buffer), ShouldAlmostEqual, 10000/math.Sqrt2, 300)
+		})
+	})
+}
+
+func TestConvert(t *testing.T) {
+	Convey("Converting audio", t, func() {
+		Convey("Should downmix stereo to mono", func() {
+			buffer := &Buffer{SampleRate: 8000, Channels: 2, Samples: []int16{100, 300, -100, -300}}
+			So(buffer.Mono().Samples, ShouldResemble, []int16{200, -200})
+		})
+		Convey("Should convert µ-law to 16 kHz PCM", func() {
+			data, _ := os.ReadFile("../test/test.wav")
+			converted, err := Convert(data, Format{PCM, 16000, 1, 16})
+			So(err, ShouldBeNil)
+			header, err := ParseWAVHeader(converted)
+			So(err, ShouldBeNil)
+			So(header.Format, ShouldResemble, Format{PCM, 16000, 1, 16})
+			So(header.Duration(), ShouldEqual, 11560*time.Millisecond)
+		})
+		Convey("Should convert PCM to A-law", func() {
+			data, _ := tone(8000, 440, 10000, time.Second).WAV(PCM)
+			converted, err := Convert(data, Format{ALaw, 8000, 1, 8})
+			So(err, ShouldBeNil)
+			decoded, err := Decode(converted)
+			So(err, ShouldBeNil)
+			So(decoded.Frames(), ShouldEqual, 8000)
+			So(rms(decoded), ShouldAlmostEqual, 10000/math.Sqrt2, 200)
+		})
+		Convey("Should convert to headerless µ-law", func() {
+			data, _ := tone(16000, 440, 10000, time.Second).WAV(PCM)
+			raw, err := ConvertRaw(data, Format{MuLaw, 8000, 1, 8})
+			So(err, ShouldBeNil)
+			So(len(raw), ShouldEqual, 8000)
+			So(Sniff(raw), ShouldEqual, ContentTypeMuLaw)
+			pcm, _ := ConvertRaw(data, Format{PCM, 16000, 1, 16})
+			So(len(pcm), ShouldEqual, 32000)
+		})
+		Convey("Should reject unsupported output formats", func() {
+			data, _ := tone(8000, 440, 10000, time.Second).WAV(PCM)
+			_, err := Convert(data, Format{PCM, 8000, 1, 24})
+			So(err.Error(), ShouldEqual, "converting to PCM 24-bit 8000 Hz mono audio is not supported")
+		})
+	})
+}
+
+func TestTranscode(t *testing.T) {
+	Convey("Transcoding audio for an endpoint", t, func() {
+		Convey("Should leave supported audio untouched", func() {
+			data, _ := os.ReadFile("../test/test.wav")
+			transcoded, err := Transcode(data, STT)
+			So(err, ShouldBeNil)
+			So(len(transcoded), ShouldEqual, len(data))
+		})
+		Convey("Should convert 44.1 kHz stereo to 16 kHz mono PCM", func() {
+			stereo := tone(44100, 440, 10000, time.Second)
+			stereo.Channels = 2
+			data, _ := stereo.WAV(PCM)
+			transcoded, err := Transcode(data, STT)
+			So(err, ShouldBeNil)
+			header, _ := ParseWAVHeader(transcoded)
+			So(header.Format, ShouldResemble, Format{PCM, 16000, 1, 16})
+			So(STT.Check(header), ShouldBeNil)
+		})
+		Convey("Should convert 11.025 kHz to 8 kHz rather than upsampling", func() {
+			data, _ := tone(11025, 440, 10000, time.Second).WAV(PCM)
+			transcoded, err := Transcode(data, STT)
+			So(err, ShouldBeNil)
+			header, _ := ParseWAVHeader(transcoded)
+			So(header.SampleRate, ShouldEqual, 8000)
+		})
+	})
+}
+
+// tone generates a mono sine wave
+func tone(sampleRate int, frequency float64, amplitude float64, duration time.Duration) *Buffer {
+	frames := int(int64(sampleRate) * int64(duration) / int64(time.Second))
+	buffer := &Buffer{SampleRate: sampleRate, Channels: 1, Samples: make([]int16, frames)}
+	for i := range buffer.Samples {
+		buffer.Samples[i] = clip(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+	}
+	return buffer
+}
+
+// rms measures the level of the audio, ignoring the filter's edges
+func rms(buffer *Buffer) float64 {
+	edge := buffer.SampleRate / 100
+	sum := 0.0
+	samples := buffer.Samples[edge : len(buffer.Samples)-edge]
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
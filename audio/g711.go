@@ -0,0 +1,89 @@
+package audio
+
+const (
+	muLawBias = 0x84
+	muLawClip = 32635
+)
+
+// aLawSegmentEnds are the upper bounds of the A-law segments for 13-bit samples
+var aLawSegmentEnds = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+
+// MuLawEncode compresses a linear sample to G.711 µ-law
+func MuLawEncode(sample int16) byte {
+	s := int(sample)
+	sign := 0
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	if s > muLawClip {
+		s = muLawClip
+	}
+	s += muLawBias
+	exponent := 7
+	for mask := 0x4000; s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (s >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// MuLawDecode expands a G.711 µ-law byte to a linear sample
+func MuLawDecode(value byte) int16 {
+	value = ^value
+	exponent := uint(value>>4) & 0x07
+	mantissa := int(value & 0x0F)
+	s := ((mantissa << 3) + muLawBias) << exponent
+	s -= muLawBias
+	if value&0x80 != 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+// ALawEncode compresses a linear sample to G.711 A-law
+func ALawEncode(sample int16) byte {
+	s := int(sample) >> 3
+	mask := 0xD5
+	if s < 0 {
+		mask = 0x55
+		s = -s - 1
+	}
+	segment := len(aLawSegmentEnds)
+	for i, end := range aLawSegmentEnds {
+		if s <= end {
+			segment = i
+			break
+		}
+	}
+	if segment >= len(aLawSegmentEnds) {
+		return byte(0x7F ^ mask)
+	}
+	value := segment << 4
+	if segment < 2 {
+		value |= (s >> 1) & 0x0F
+	} else {
+		value |= (s >> uint(segment)) & 0x0F
+	}
+	return byte(value ^ mask)
+}
+
+// ALawDecode expands a G.711 A-law byte to a linear sample
+func ALawDecode(value byte) int16 {
+	value ^= 0x55
+	s := int(value&0x0F) << 4
+	segment := uint(value&0x70) >> 4
+	switch segment {
+	case 0:
+		s += 8
+	case 1:
+		s += 0x108
+	default:
+		s += 0x108
+		s <<= segment - 1
+	}
+	if value&0x80 != 0 {
+		return int16(s)
+	}
+	return int16(-s)
+}
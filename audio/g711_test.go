@@ -0,0 +1,46 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math"
+	"testing"
+)
+
+func TestG711(t *testing.T) {
+	Convey("G.711 companding", t, func() {
+		Convey("Should encode and decode known µ-law values", func() {
+			So(MuLawEncode(0), ShouldEqual, 0xFF)
+			So(MuLawDecode(0xFF), ShouldEqual, 0)
+			So(MuLawDecode(0x80), ShouldEqual, 32124)
+			So(MuLawDecode(0x00), ShouldEqual, -32124)
+			So(MuLawEncode(math.MaxInt16), ShouldEqual, 0x80)
+			So(MuLawEncode(math.MinInt16), ShouldEqual, 0x00)
+		})
+		Convey("Should encode and decode known A-law values", func() {
+			So(ALawEncode(0), ShouldEqual, 0xD5)
+			So(ALawDecode(0xD5), ShouldEqual, 8)
+			So(ALawDecode(0xAA), ShouldEqual, 32256)
+			So(ALawDecode(0x2A), ShouldEqual, -32256)
+		})
+		Convey("Should round trip every code", func() {
+			for i := 0; i < 256; i++ {
+				So(MuLawEncode(MuLawDecode(byte(i))), ShouldEqual, canonicalMuLaw(byte(i)))
+				So(ALawEncode(ALawDecode(byte(i))), ShouldEqual, byte(i))
+			}
+		})
+		Convey("Should keep the quantization error relative to the signal", func() {
+			for _, sample := range []int16{100, -100, 1000, -1000, 10000, -10000, 30000} {
+				So(math.Abs(float64(MuLawDecode(MuLawEncode(sample))-sample)), ShouldBeLessThanOrEqualTo, math.Abs(float64(sample))/16+4)
+				So(math.Abs(float64(ALawDecode(ALawEncode(sample))-sample)), ShouldBeLessThanOrEqualTo, math.Abs(float64(sample))/16+8)
+			}
+		})
+	})
+}
+
+// canonicalMuLaw maps negative zero (0x7F) to positive zero, the only µ-law code that does not round trip
+func canonicalMuLaw(code byte) byte {
+	if code == 0x7F {
+		return 0xFF
+	}
+	return code
+}
@@ -0,0 +1,183 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Buffer holds decoded audio as interleaved 16-bit linear samples
+type Buffer struct {
+	SampleRate int
+	Channels   int
+	Samples    []int16
+}
+
+// Frames returns the number of samples per channel
+func (buffer *Buffer) Frames() int {
+	if buffer.Channels == 0 {
+		return 0
+	}
+	return len(buffer.Samples) / buffer.Channels
+}
+
+/*
+Decode parses a WAV file and decodes its samples. PCM of 8, 16, 24 and
+32 bits, 32-bit IEEE float, µ-law and A-law are supported.
+*/
+func Decode(data []byte) (*Buffer, error) {
+	header, err := ParseWAVHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := decodeSamples(header.Format, data[header.DataOffset:header.DataOffset+header.DataSize])
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{SampleRate: header.SampleRate, Channels: header.Channels, Samples: samples}, nil
+}
+
+// decodeSamples converts raw sample data in format to 16-bit linear samples
+func decodeSamples(format Format, data []byte) ([]int16, error) {
+	width := format.BitsPerSample / 8
+	if width == 0 {
+		return nil, errors.New("unsupported bit depth of " + strconv.Itoa(format.BitsPerSample))
+	}
+	samples := make([]int16, len(data)/width)
+	for i := range samples {
+		b := data[i*width : (i+1)*width]
+		switch {
+		case format.Encoding == MuLaw && width == 1:
+			samples[i] = MuLawDecode(b[0])
+		case format.Encoding == ALaw && width == 1:
+			samples[i] = ALawDecode(b[0])
+		case format.Encoding == PCM && width == 1:
+			// 8-bit PCM is unsigned
+			samples[i] = int16(int(b[0])-128) << 8
+		case format.Encoding == PCM && width == 2:
+			samples[i] = int16(binary.LittleEndian.Uint16(b))
+		case format.Encoding == PCM && width == 3:
+			samples[i] = int16(uint16(b[1]) | uint16(b[2])<<8)
+		case format.Encoding == PCM && width == 4:
+			samples[i] = int16(binary.LittleEndian.Uint32(b) >> 16)
+		case format.Encoding == Float && width == 4:
+			samples[i] = clip(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) * 32767)
+		default:
+			return nil, errors.New("decoding " + format.String() + " audio is not supported")
+		}
+	}
+	return samples, nil
+}
+
+// Mono returns the audio with all channels averaged into one
+func (buffer *Buffer) Mono() *Buffer {
+	if buffer.Channels <= 1 {
+		return buffer
+	}
+	frames := buffer.Frames()
+	mono := &Buffer{SampleRate: buffer.SampleRate, Channels: 1, Samples: make([]int16, frames)}
+	for i := 0; i < frames; i++ {
+		sum := 0
+		for _, sample := range buffer.Samples[i*buffer.Channels : (i+1)*buffer.Channels] {
+			sum += int(sample)
+		}
+		mono.Samples[i] = int16(sum / buffer.Channels)
+	}
+	return mono
+}
+
+/*
+WAV encodes the audio as a WAV file using encoding, which must be PCM
+(written as 16-bit), MuLaw or ALaw
+*/
+func (buffer *Buffer) WAV(encoding Encoding) ([]byte, error) {
+	data, err := buffer.Raw(encoding)
+	if err != nil {
+		return nil, err
+	}
+	format := Format{Encoding: encoding, SampleRate: buffer.SampleRate, Channels: buffer.Channels, BitsPerSample: 8}
+	if encoding == PCM {
+		format.BitsPerSample = 16
+	}
+
+	wav := &bytes.Buffer{}
+	writeWAVHeader(wav, format, len(data))
+	wav.Write(data)
+	if len(data)%2 == 1 {
+		wav.WriteByte(0)
+	}
+	return wav.Bytes(), nil
+}
+
+/*
+Raw encodes the audio as headerless samples using encoding, which must be
+PCM (written as 16-bit little endian), MuLaw or ALaw
+*/
+func (buffer *Buffer) Raw(encoding Encoding) ([]byte, error) {
+	var data []byte
+	switch encoding {
+	case PCM:
+		data = make([]byte, len(buffer.Samples)*2)
+		for i, sample := range buffer.Samples {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+		}
+	case MuLaw, ALaw:
+		data = make([]byte, len(buffer.Samples))
+		for i, sample := range buffer.Samples {
+			if encoding == MuLaw {
+				data[i] = MuLawEncode(sample)
+			} else {
+				data[i] = ALawEncode(sample)
+			}
+		}
+	default:
+		return nil, errors.New("encoding " + encoding.String() + " audio is not supported")
+	}
+	return data, nil
+}
+
+/*
+writeWAVHeader writes the RIFF, fmt and data chunk headers for dataSize
+bytes of samples. Non-PCM formats get the cbSize field and fact chunk
+that some decoders require.
+*/
+func writeWAVHeader(w *bytes.Buffer, format Format, dataSize int) {
+	blockAlign := format.Channels * format.BitsPerSample / 8
+	fmtSize := 16
+	riffSize := 4 + 8 + fmtSize + 8 + dataSize
+	if format.Encoding != PCM {
+		fmtSize = 18
+		riffSize = 4 + 8 + fmtSize + 12 + 8 + dataSize
+	}
+	w.WriteString("RIFF")
+	binary.Write(w, binary.LittleEndian, uint32(riffSize+dataSize%2))
+	w.WriteString("WAVEfmt ")
+	binary.Write(w, binary.LittleEndian, uint32(fmtSize))
+	binary.Write(w, binary.LittleEndian, uint16(format.Encoding))
+	binary.Write(w, binary.LittleEndian, uint16(format.Channels))
+	binary.Write(w, binary.LittleEndian, uint32(format.SampleRate))
+	binary.Write(w, binary.LittleEndian, uint32(format.SampleRate*blockAlign))
+	binary.Write(w, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(w, binary.LittleEndian, uint16(format.BitsPerSample))
+	if format.Encoding != PCM {
+		binary.Write(w, binary.LittleEndian, uint16(0))
+		w.WriteString("fact")
+		binary.Write(w, binary.LittleEndian, uint32(4))
+		binary.Write(w, binary.LittleEndian, uint32(dataSize/blockAlign))
+	}
+	w.WriteString("data")
+	binary.Write(w, binary.LittleEndian, uint32(dataSize))
+}
+
+// clip rounds a sample to the nearest 16-bit value
+func clip(sample float64) int16 {
+	switch {
+	case sample > math.MaxInt16:
+		return math.MaxInt16
+	case sample < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(sample))
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// zeroCrossings is the number of sinc lobes on each side of the filter kernel
+	zeroCrossings = 16
+	// kernelResolution is the number of kernel table entries per lobe
+	kernelResolution = 512
+	// passband is the fraction of the output Nyquist frequency kept, leaving room for the filter to roll off
+	passband = 0.9
+)
+
+var (
+	kernelOnce  sync.Once
+	kernelTable []float64
+)
+
+/*
+Resample returns the audio at sampleRate using band-limited windowed sinc
+interpolation. When downsampling, the kernel's cutoff is lowered to the
+new Nyquist frequency so higher frequencies are filtered out instead of
+aliasing into the speech band.
+*/
+func (buffer *Buffer) Resample(sampleRate int) *Buffer {
+	if sampleRate == buffer.SampleRate || buffer.Channels == 0 {
+		return buffer
+	}
+	frames := buffer.Frames()
+	outFrames := int(int64(frames) * int64(sampleRate) / int64(buffer.SampleRate))
+	resampled := &Buffer{SampleRate: sampleRate, Channels: buffer.Channels, Samples: make([]int16, outFrames*buffer.Channels)}
+
+	in := make([]float64, frames)
+	for channel := 0; channel < buffer.Channels; channel++ {
+		for i := range in {
+			in[i] = float64(buffer.Samples[i*buffer.Channels+channel])
+		}
+		out := resampleChannel(in, buffer.SampleRate, sampleRate, outFrames)
+		for i, sample := range out {
+			resampled.Samples[i*buffer.Channels+channel] = clip(sample)
+		}
+	}
+	return resampled
+}
+
+// resampleChannel interpolates a single channel of samples from inRate to outRate
+func resampleChannel(in []float64, inRate int, outRate int, outFrames int) []float64 {
+	kernelOnce.Do(buildKernel)
+	ratio := float64(outRate) / float64(inRate)
+	cutoff := math.Min(1, ratio) * passband
+	// halfWidth is the kernel radius measured in input samples
+	halfWidth := zeroCrossings / cutoff
+
+	out := make([]float64, outFrames)
+	for i := range out {
+		t := float64(i) / ratio
+		first := int(math.Ceil(t - halfWidth))
+		if first < 0 {
+			first = 0
+		}
+		last := int(math.Floor(t + halfWidth))
+		if last > len(in)-1 {
+			last = len(in) - 1
+		}
+		sum := 0.0
+		for k := first; k <= last; k++ {
+			sum += in[k] * kernel(cutoff*(t-float64(k)))
+		}
+		out[i] = sum * cutoff
+	}
+	return out
+}
+
+// kernel looks up the Blackman windowed sinc at u, measured in lobes, interpolating the table
+func kernel(u float64) float64 {
+	u = math.Abs(u) * kernelResolution
+	index := int(u)
+	if index >= len(kernelTable)-1 {
+		return 0
+	}
+	fraction := u - float64(index)
+	return kernelTable[index] + fraction*(kernelTable[index+1]-kernelTable[index])
+}
+
+// buildKernel tabulates one side of the windowed sinc kernel
+func buildKernel() {
+	kernelTable = make([]float64, zeroCrossings*kernelResolution+1)
+	for i := range kernelTable {
+		u := float64(i) / kernelResolution
+		sinc := 1.0
+		if u != 0 {
+			sinc = math.Sin(math.Pi*u) / (math.Pi * u)
+		}
+		w := u / zeroCrossings
+		blackman := 0.42 + 0.5*math.Cos(math.Pi*w) + 0.08*math.Cos(2*math.Pi*w)
+		kernelTable[i] = sinc * blackman
+	}
+}
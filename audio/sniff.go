@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+)
+
+// muLawSmoothness is the largest ratio of sample-to-sample change to amplitude accepted as µ-law speech
+const muLawSmoothness = 0.5
+
+// The content types the AT&T Speech API expects for each container
+const (
+	ContentTypeWAV   = "audio/wav"
+	ContentTypeAMR   = "audio/amr"
+	ContentTypeAMRWB = "audio/amr-wb"
+	ContentTypeSpeex = "audio/x-speex"
+	ContentTypeOpus  = "audio/ogg;codecs=opus"
+	ContentTypeFLAC  = "audio/flac"
+	ContentTypeMuLaw = "audio/basic"
+)
+
+/*
+Sniff detects the content type of audio from its leading bytes, returning
+an empty string when the format is not recognized. WAV, AMR-NB, AMR-WB,
+Speex and Opus in Ogg, FLAC and µ-law (Sun .au or headerless) are detected.
+
+	apiRequest.ContentType = audio.Sniff(data.Bytes())
+
+Headerless µ-law has no magic bytes, so it is recognized statistically
+and only when nothing else matches.
+*/
+func Sniff(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return ContentTypeWAV
+	case bytes.HasPrefix(data, []byte("#!AMR-WB\n")):
+		return ContentTypeAMRWB
+	case bytes.HasPrefix(data, []byte("#!AMR\n")):
+		return ContentTypeAMR
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ContentTypeFLAC
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return sniffOgg(data)
+	case bytes.HasPrefix(data, []byte(".snd")) && len(data) >= 16 && data[15] == 1:
+		// Sun .au with encoding 1, 8-bit µ-law
+		return ContentTypeMuLaw
+	case looksLikeMuLaw(data):
+		return ContentTypeMuLaw
+	}
+	return ""
+}
+
+// sniffOgg identifies the codec from the first packet of an Ogg stream
+func sniffOgg(data []byte) string {
+	if len(data) < 27 {
+		return ""
+	}
+	// The first packet follows the 27 byte page header and its segment table
+	packet := data[27:]
+	segments := int(data[26])
+	if len(packet) < segments {
+		return ""
+	}
+	packet = packet[segments:]
+	switch {
+	case bytes.HasPrefix(packet, []byte("Speex   ")):
+		return ContentTypeSpeex
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		return ContentTypeOpus
+	case bytes.HasPrefix(packet, []byte("\x7fFLAC")):
+		return ContentTypeFLAC
+	}
+	return ""
+}
+
+/*
+looksLikeMuLaw reports whether data decodes as µ-law to a smooth signal.
+Speech sampled at 8 kHz changes little from one sample to the next
+relative to its amplitude, whereas text, linear PCM and compressed audio
+decode to noise.
+*/
+func looksLikeMuLaw(data []byte) bool {
+	// At least 100ms at 8 kHz is needed for the statistics to mean anything
+	if len(data) < 800 {
+		return false
+	}
+	mean := 0.0
+	for _, b := range data {
+		mean += float64(MuLawDecode(b))
+	}
+	mean /= float64(len(data))
+
+	deviation, change := 0.0, 0.0
+	previous := float64(MuLawDecode(data[0]))
+	for _, b := range data {
+		sample := float64(MuLawDecode(b))
+		deviation += math.Abs(sample - mean)
+		change += math.Abs(sample - previous)
+		previous = sample
+	}
+	return deviation > 0 && change < deviation*muLawSmoothness
+}
@@ -0,0 +1,55 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSniff(t *testing.T) {
+	Convey("Sniffing audio content types", t, func() {
+		Convey("Should detect WAV", func() {
+			data, _ := os.ReadFile("../test/test.wav")
+			So(Sniff(data), ShouldEqual, ContentTypeWAV)
+		})
+		Convey("Should detect AMR narrowband and wideband", func() {
+			So(Sniff([]byte("#!AMR\n\x3c")), ShouldEqual, ContentTypeAMR)
+			So(Sniff([]byte("#!AMR-WB\n\x24")), ShouldEqual, ContentTypeAMRWB)
+		})
+		Convey("Should detect FLAC", func() {
+			So(Sniff([]byte("fLaC\x00\x00\x00\x22")), ShouldEqual, ContentTypeFLAC)
+		})
+		Convey("Should detect the codec inside Ogg", func() {
+			So(Sniff(oggPage("Speex   1.2rc1")), ShouldEqual, ContentTypeSpeex)
+			So(Sniff(oggPage("OpusHead\x01\x01")), ShouldEqual, ContentTypeOpus)
+			So(Sniff(oggPage("\x01vorbis")), ShouldBeBlank)
+		})
+		Convey("Should detect Sun .au µ-law", func() {
+			So(Sniff([]byte(".snd\x00\x00\x00\x18\xff\xff\xff\xff\x00\x00\x00\x01")), ShouldEqual, ContentTypeMuLaw)
+		})
+		Convey("Should detect headerless µ-law speech", func() {
+			data, _ := os.ReadFile("../test/test.wav")
+			So(Sniff(data[58:]), ShouldEqual, ContentTypeMuLaw)
+		})
+		Convey("Should not mistake other data for µ-law", func() {
+			text, _ := os.ReadFile("wav.go")
+			So(Sniff(text), ShouldBeBlank)
+			pcm, _ := tone(8000, 440, 10000, time.Second).WAV(PCM)
+			So(Sniff(pcm[44:]), ShouldBeBlank)
+			noise := make([]byte, 8000)
+			rand.New(rand.NewSource(1)).Read(noise)
+			So(Sniff(noise), ShouldBeBlank)
+			So(Sniff(make([]byte, 8000)), ShouldBeBlank)
+		})
+	})
+}
+
+// oggPage builds the first page of an Ogg stream holding packet
+func oggPage(packet string) []byte {
+	page := []byte("OggS\x00\x02")
+	page = append(page, make([]byte, 20)...)
+	page = append(page, 1, byte(len(packet)))
+	return append(page, packet...)
+}
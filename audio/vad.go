@@ -0,0 +1,216 @@
+package audio
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// The mean square sample values of a faint hiss and of quiet speech, around -60 and -30 dBFS
+const (
+	minNoiseFloor = 1e2
+	speechEnergy  = 1e6
+)
+
+// SplitOptions configures how Split finds the pauses to cut audio at
+type SplitOptions struct {
+	// MaxSegment is the longest segment produced, zero means no limit
+	MaxSegment time.Duration
+	// MinSilence is the shortest pause that separates segments, 300ms by default
+	MinSilence time.Duration
+	// Padding is the silence kept either side of speech, 150ms by default
+	Padding time.Duration
+	// FrameSize is the analysis window, 20ms by default
+	FrameSize time.Duration
+	// Threshold is how many times louder than the noise floor a frame must be to count as speech, 4 by default
+	Threshold float64
+}
+
+// Segment is a span of audio containing speech
+type Segment struct {
+	// StartFrame and EndFrame are the sample offsets, per channel, of the segment
+	StartFrame int
+	EndFrame   int
+	// Start and End are the offsets of the segment in time
+	Start time.Duration
+	End   time.Duration
+}
+
+// withDefaults fills in unset options
+func (options SplitOptions) withDefaults() SplitOptions {
+	if options.MinSilence <= 0 {
+		options.MinSilence = 300 * time.Millisecond
+	}
+	if options.Padding <= 0 {
+		options.Padding = 150 * time.Millisecond
+	}
+	if options.FrameSize <= 0 {
+		options.FrameSize = 20 * time.Millisecond
+	}
+	if options.Threshold <= 0 {
+		options.Threshold = 4
+	}
+	return options
+}
+
+/*
+Split detects speech with an energy and zero-crossing voice activity
+detector and returns the segments of speech, cut in the middle of pauses.
+Long pauses are dropped, keeping options.Padding of silence around speech,
+and segments longer than options.MaxSegment are cut at their quietest
+frame so every segment can be sent to the API on its own.
+
+	buffer, err := audio.Decode(data)
+	segments := buffer.Split(audio.SplitOptions{MaxSegment: time.Minute})
+	for _, segment := range segments {
+		data, err := buffer.Slice(segment).WAV(audio.PCM)
+	}
+*/
+func (buffer *Buffer) Split(options SplitOptions) []Segment {
+	options = options.withDefaults()
+	mono := buffer.Mono()
+	frameLength := frames(options.FrameSize, buffer.SampleRate)
+	if frameLength == 0 || mono.Frames() == 0 {
+		return []Segment{}
+	}
+
+	energies, speech := detectSpeech(mono.Samples, frameLength, options.Threshold)
+	minSilence := int(math.Ceil(float64(options.MinSilence) / float64(options.FrameSize)))
+	padding := int(options.Padding / options.FrameSize)
+
+	// Speech regions, in analysis frames, separated by pauses of at least minSilence
+	regions := [][2]int{}
+	start, silence := -1, 0
+	for i, isSpeech := range speech {
+		if isSpeech {
+			if start < 0 {
+				start = i
+			} else if silence >= minSilence {
+				regions = append(regions, [2]int{start, i - silence})
+				start = i
+			}
+			silence = 0
+			continue
+		}
+		silence++
+	}
+	if start >= 0 {
+		regions = append(regions, [2]int{start, len(speech) - silence})
+	}
+
+	maxFrames := 0
+	if options.MaxSegment > 0 {
+		maxFrames = int(options.MaxSegment / options.FrameSize)
+	}
+	segments := []Segment{}
+	for i, region := range regions {
+		// Pad into the neighbouring pauses without overlapping the padding of the next region
+		first := region[0] - padding
+		if i > 0 && first < (regions[i-1][1]+region[0])/2 {
+			first = (regions[i-1][1] + region[0]) / 2
+		}
+		if first < 0 {
+			first = 0
+		}
+		last := region[1] + padding
+		if i < len(regions)-1 && last > (region[1]+regions[i+1][0])/2 {
+			last = (region[1] + regions[i+1][0]) / 2
+		}
+		if last > len(speech) {
+			last = len(speech)
+		}
+		for _, span := range limit(first, last, maxFrames, energies) {
+			segments = append(segments, buffer.segment(span[0]*frameLength, span[1]*frameLength))
+		}
+	}
+	return segments
+}
+
+// Slice returns the audio within segment
+func (buffer *Buffer) Slice(segment Segment) *Buffer {
+	return &Buffer{
+		SampleRate: buffer.SampleRate,
+		Channels:   buffer.Channels,
+		Samples:    buffer.Samples[segment.StartFrame*buffer.Channels : segment.EndFrame*buffer.Channels],
+	}
+}
+
+// segment builds a Segment between two sample offsets, clamped to the audio
+func (buffer *Buffer) segment(start int, end int) Segment {
+	if end > buffer.Frames() {
+		end = buffer.Frames()
+	}
+	return Segment{
+		StartFrame: start,
+		EndFrame:   end,
+		Start:      duration(start, buffer.SampleRate),
+		End:        duration(end, buffer.SampleRate),
+	}
+}
+
+/*
+detectSpeech classifies each analysis frame as speech or silence. The
+noise floor is taken as the 10th percentile frame energy; frames above
+threshold times the floor are speech, as are quieter frames with the high
+zero-crossing rate of unvoiced consonants such as 's' and 'f'.
+*/
+func detectSpeech(samples []int16, frameLength int, threshold float64) ([]float64, []bool) {
+	count := (len(samples) + frameLength - 1) / frameLength
+	energies := make([]float64, count)
+	crossings := make([]float64, count)
+	for i := range energies {
+		frame := samples[i*frameLength:]
+		if len(frame) > frameLength {
+			frame = frame[:frameLength]
+		}
+		sum, crossed := 0.0, 0
+		for j, sample := range frame {
+			sum += float64(sample) * float64(sample)
+			if j > 0 && (sample >= 0) != (frame[j-1] >= 0) {
+				crossed++
+			}
+		}
+		energies[i] = sum / float64(len(frame))
+		crossings[i] = float64(crossed) / float64(len(frame))
+	}
+
+	sorted := append([]float64{}, energies...)
+	sort.Float64s(sorted)
+	// Digital silence would make every sound speech, so the floor never drops below a quiet
+	// hiss, and audio that is speech throughout would have no speech, so it never rises
+	// above the level of normal speech
+	floor := math.Min(math.Max(sorted[len(sorted)/10], minNoiseFloor), speechEnergy/threshold)
+
+	speech := make([]bool, count)
+	for i, energy := range energies {
+		speech[i] = energy > floor*threshold || energy > floor*threshold/2 && crossings[i] > 0.3
+	}
+	return energies, speech
+}
+
+// limit cuts the frames first to last into spans no longer than maxFrames, at their quietest frames
+func limit(first int, last int, maxFrames int, energies []float64) [][2]int {
+	spans := [][2]int{}
+	for maxFrames > 0 && last-first > maxFrames {
+		// Cut in the second half of the allowed span so segments are not left tiny
+		cut := first + maxFrames
+		for i := first + maxFrames/2; i < first+maxFrames; i++ {
+			if energies[i] < energies[cut-1] {
+				cut = i + 1
+			}
+		}
+		spans = append(spans, [2]int{first, cut})
+		first = cut
+	}
+	return append(spans, [2]int{first, last})
+}
+
+// frames converts a duration to a number of samples at sampleRate
+func frames(d time.Duration, sampleRate int) int {
+	return int(int64(d) * int64(sampleRate) / int64(time.Second))
+}
+
+// duration converts a number of samples at sampleRate to a duration
+func duration(frames int, sampleRate int) time.Duration {
+	return time.Duration(int64(frames) * int64(time.Second) / int64(sampleRate))
+}
@@ -0,0 +1,89 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	Convey("Splitting audio at pauses", t, func() {
+		Convey("Should find each burst of speech with its offsets", func() {
+			buffer := concat(
+				tone(8000, 300, 8000, time.Second),
+				silence(8000, time.Second),
+				tone(8000, 300, 8000, 2*time.Second),
+				silence(8000, 500*time.Millisecond),
+				tone(8000, 300, 8000, time.Second),
+			)
+			segments := buffer.Split(SplitOptions{})
+			So(len(segments), ShouldEqual, 3)
+			So(segments[0].Start, ShouldEqual, 0)
+			So(segments[0].End, ShouldAlmostEqual, 1150*time.Millisecond, 20*time.Millisecond)
+			So(segments[1].Start, ShouldAlmostEqual, 1850*time.Millisecond, 20*time.Millisecond)
+			So(segments[1].End, ShouldAlmostEqual, 4150*time.Millisecond, 20*time.Millisecond)
+			So(segments[2].Start, ShouldAlmostEqual, 4350*time.Millisecond, 20*time.Millisecond)
+			So(segments[2].End, ShouldEqual, 5500*time.Millisecond)
+			So(buffer.Slice(segments[1]).Frames(), ShouldEqual, segments[1].EndFrame-segments[1].StartFrame)
+		})
+		Convey("Should not split at pauses shorter than MinSilence", func() {
+			buffer := concat(
+				tone(8000, 300, 8000, time.Second),
+				silence(8000, 100*time.Millisecond),
+				tone(8000, 300, 8000, time.Second),
+			)
+			So(len(buffer.Split(SplitOptions{})), ShouldEqual, 1)
+		})
+		Convey("Should cut continuous speech to MaxSegment", func() {
+			buffer := tone(16000, 300, 8000, 10*time.Second)
+			segments := buffer.Split(SplitOptions{MaxSegment: 3 * time.Second})
+			So(len(segments), ShouldEqual, 4)
+			for i, segment := range segments {
+				So(segment.End-segment.Start, ShouldBeLessThanOrEqualTo, 3*time.Second)
+				if i > 0 {
+					So(segment.StartFrame, ShouldEqual, segments[i-1].EndFrame)
+				}
+			}
+			So(segments[3].End, ShouldEqual, 10*time.Second)
+		})
+		Convey("Should count quiet hissing consonants as speech", func() {
+			hiss := silence(8000, 200*time.Millisecond)
+			random := rand.New(rand.NewSource(1))
+			for i := range hiss.Samples {
+				hiss.Samples[i] = int16(random.Intn(1200) - 600)
+			}
+			buffer := concat(
+				tone(8000, 300, 8000, time.Second),
+				silence(8000, 200*time.Millisecond),
+				hiss,
+				silence(8000, 200*time.Millisecond),
+				tone(8000, 300, 8000, time.Second),
+			)
+			So(len(buffer.Split(SplitOptions{})), ShouldEqual, 1)
+		})
+		Convey("Should find nothing in silence", func() {
+			So(silence(8000, time.Second).Split(SplitOptions{}), ShouldBeEmpty)
+			So((&Buffer{SampleRate: 8000, Channels: 1}).Split(SplitOptions{}), ShouldBeEmpty)
+		})
+	})
+}
+
+// silence generates mono audio with a faint background noise
+func silence(sampleRate int, duration time.Duration) *Buffer {
+	buffer := tone(sampleRate, 0, 0, duration)
+	random := rand.New(rand.NewSource(int64(len(buffer.Samples))))
+	for i := range buffer.Samples {
+		buffer.Samples[i] = int16(random.Intn(11) - 5)
+	}
+	return buffer
+}
+
+// concat joins mono buffers end to end
+func concat(buffers ...*Buffer) *Buffer {
+	joined := &Buffer{SampleRate: buffers[0].SampleRate, Channels: 1}
+	for _, buffer := range buffers {
+		joined.Samples = append(joined.Samples, buffer.Samples...)
+	}
+	return joined
+}
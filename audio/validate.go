@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requirements are the audio formats and length an endpoint accepts
+type Requirements struct {
+	// Endpoint names the endpoint in errors
+	Endpoint string
+	// Formats lists every supported combination of encoding, sample rate, channels and bit depth
+	Formats []Format
+	// MaxDuration is the longest audio accepted, zero means unlimited
+	MaxDuration time.Duration
+}
+
+var (
+	// STT are the requirements of the speech to text endpoint
+	STT = &Requirements{
+		Endpoint: "speech to text",
+		Formats: []Format{
+			{PCM, 8000, 1, 16},
+			{PCM, 16000, 1, 16},
+			{MuLaw, 8000, 1, 8},
+		},
+		MaxDuration: 4 * time.Minute,
+	}
+	// STTC are the requirements of the speech to text custom endpoint
+	STTC = &Requirements{
+		Endpoint: "speech to text custom",
+		Formats: []Format{
+			{PCM, 8000, 1, 16},
+			{PCM, 16000, 1, 16},
+			{MuLaw, 8000, 1, 8},
+		},
+		MaxDuration: 4 * time.Minute,
+	}
+)
+
+// FormatError reports audio that an endpoint does not accept
+type FormatError struct {
+	Endpoint string
+	Format   Format
+	Duration time.Duration
+	Reason   string
+}
+
+// Error describes why the audio was rejected
+func (err *FormatError) Error() string {
+	return err.Endpoint + " does not accept " + err.Format.String() + " audio: " + err.Reason
+}
+
+/*
+Check returns a *FormatError describing the first way the audio in
+header does not meet the requirements, or nil if it does
+*/
+func (requirements *Requirements) Check(header *Header) error {
+	if err := requirements.checkFormat(header); err != nil {
+		return err
+	}
+	duration := header.Duration()
+	if requirements.MaxDuration > 0 && duration > requirements.MaxDuration {
+		return requirements.reject(header, fmt.Sprintf("%s long exceeds the %s limit", duration, requirements.MaxDuration))
+	}
+	return nil
+}
+
+// checkFormat explains which property of the header's format is unsupported
+func (requirements *Requirements) checkFormat(header *Header) error {
+	format := header.Format
+	for _, supported := range requirements.Formats {
+		if supported == format {
+			return nil
+		}
+	}
+
+	// Narrow the supported formats one property at a time, reporting the first mismatch
+	encodings := []string{}
+	matching := []Format{}
+	for _, supported := range requirements.Formats {
+		encodings = appendUnique(encodings, supported.Encoding.String())
+		if supported.Encoding == format.Encoding {
+			matching = append(matching, supported)
+		}
+	}
+	if len(matching) == 0 {
+		return requirements.reject(header, "the encoding must be "+strings.Join(encodings, " or "))
+	}
+
+	channels := []string{}
+	narrowed := []Format{}
+	for _, supported := range matching {
+		channels = appendUnique(channels, strconv.Itoa(supported.Channels))
+		if supported.Channels == format.Channels {
+			narrowed = append(narrowed, supported)
+		}
+	}
+	if len(narrowed) == 0 {
+		if len(channels) == 1 && channels[0] == "1" {
+			return requirements.reject(header, "the audio must be mono")
+		}
+		return requirements.reject(header, "the number of channels must be "+strings.Join(channels, " or "))
+	}
+
+	rates := []string{}
+	matching, narrowed = narrowed, []Format{}
+	for _, supported := range matching {
+		rates = appendUnique(rates, strconv.Itoa(supported.SampleRate))
+		if supported.SampleRate == format.SampleRate {
+			narrowed = append(narrowed, supported)
+		}
+	}
+	if len(narrowed) == 0 {
+		return requirements.reject(header, "the sample rate must be "+strings.Join(rates, " or ")+" Hz")
+	}
+
+	bits := []string{}
+	for _, supported := range narrowed {
+		bits = appendUnique(bits, strconv.Itoa(supported.BitsPerSample))
+	}
+	return requirements.reject(header, "the bit depth must be "+strings.Join(bits, " or ")+" bits")
+}
+
+// reject builds a FormatError for the audio in header
+func (requirements *Requirements) reject(header *Header, reason string) error {
+	return &FormatError{
+		Endpoint: requirements.Endpoint,
+		Format:   header.Format,
+		Duration: header.Duration(),
+		Reason:   reason,
+	}
+}
+
+// appendUnique appends value to values unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,46 @@
+package audio
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	Convey("Checking audio against an endpoint's requirements", t, func() {
+		check := func(encoding Encoding, channels int, sampleRate int, bitsPerSample int, frames int) error {
+			header, err := ParseWAVHeader(wavBytes(encoding, channels, sampleRate, bitsPerSample, frames))
+			So(err, ShouldBeNil)
+			return STT.Check(header)
+		}
+
+		Convey("Should accept supported formats", func() {
+			So(check(PCM, 1, 8000, 16, 8000), ShouldBeNil)
+			So(check(PCM, 1, 16000, 16, 8000), ShouldBeNil)
+			So(check(MuLaw, 1, 8000, 8, 8000), ShouldBeNil)
+		})
+		Convey("Should explain an unsupported encoding", func() {
+			err := check(Float, 1, 8000, 32, 10)
+			So(err.Error(), ShouldEqual, "speech to text does not accept IEEE float 32-bit 8000 Hz mono audio: the encoding must be PCM or µ-law")
+		})
+		Convey("Should explain an unsupported number of channels", func() {
+			err := check(PCM, 2, 44100, 16, 10)
+			So(err.Error(), ShouldEqual, "speech to text does not accept PCM 16-bit 44100 Hz stereo audio: the audio must be mono")
+		})
+		Convey("Should explain an unsupported sample rate", func() {
+			err := check(PCM, 1, 44100, 16, 10)
+			So(err.Error(), ShouldEqual, "speech to text does not accept PCM 16-bit 44100 Hz mono audio: the sample rate must be 8000 or 16000 Hz")
+		})
+		Convey("Should explain an unsupported bit depth", func() {
+			err := check(PCM, 1, 8000, 8, 10)
+			So(err.Error(), ShouldEqual, "speech to text does not accept PCM 8-bit 8000 Hz mono audio: the bit depth must be 16 bits")
+		})
+		Convey("Should reject audio that is too long", func() {
+			err := check(MuLaw, 1, 8000, 8, 8000*5*60)
+			formatErr, ok := err.(*FormatError)
+			So(ok, ShouldBeTrue)
+			So(formatErr.Duration, ShouldEqual, 5*time.Minute)
+			So(err.Error(), ShouldEqual, "speech to text does not accept µ-law 8-bit 8000 Hz mono audio: 5m0s long exceeds the 4m0s limit")
+		})
+	})
+}
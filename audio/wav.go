@@ -0,0 +1,151 @@
+/*
+Package audio inspects audio before it is uploaded to the AT&T Speech API,
+so that unsupported formats are reported locally with a descriptive error
+instead of by the server after a full upload.
+
+	header, err := audio.ParseWAVHeader(data.Bytes())
+	if err != nil {
+		return err
+	}
+	fmt.Println(header.SampleRate, header.Channels, header.Duration())
+	err = audio.STT.Check(header)
+*/
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Encoding is the WAV format tag describing how samples are encoded
+type Encoding uint16
+
+const (
+	// PCM is linear pulse code modulation
+	PCM Encoding = 0x0001
+	// Float is IEEE floating point
+	Float Encoding = 0x0003
+	// ALaw is ITU G.711 A-law
+	ALaw Encoding = 0x0006
+	// MuLaw is ITU G.711 µ-law
+	MuLaw Encoding = 0x0007
+	// extensible means the encoding is given by the sub format of the fmt chunk
+	extensible Encoding = 0xFFFE
+)
+
+// String returns the name of the encoding
+func (encoding Encoding) String() string {
+	switch encoding {
+	case PCM:
+		return "PCM"
+	case Float:
+		return "IEEE float"
+	case ALaw:
+		return "A-law"
+	case MuLaw:
+		return "µ-law"
+	}
+	return fmt.Sprintf("format 0x%04X", uint16(encoding))
+}
+
+// Format describes how audio samples are encoded
+type Format struct {
+	Encoding      Encoding
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// String describes the format, e.g. "PCM 16-bit 8000 Hz mono"
+func (format Format) String() string {
+	channels := "mono"
+	if format.Channels == 2 {
+		channels = "stereo"
+	} else if format.Channels != 1 {
+		channels = fmt.Sprintf("%d channels", format.Channels)
+	}
+	return fmt.Sprintf("%s %d-bit %d Hz %s", format.Encoding, format.BitsPerSample, format.SampleRate, channels)
+}
+
+// Header is the parsed header of a RIFF/WAV file
+type Header struct {
+	Format
+	// BlockAlign is the size in bytes of one sample for every channel
+	BlockAlign int
+	// DataOffset is the offset of the first sample in the file
+	DataOffset int
+	// DataSize is the number of bytes of samples
+	DataSize int
+}
+
+// Duration returns the length of the audio
+func (header *Header) Duration() time.Duration {
+	if header.SampleRate == 0 || header.BlockAlign == 0 {
+		return 0
+	}
+	frames := header.DataSize / header.BlockAlign
+	return time.Duration(frames) * time.Second / time.Duration(header.SampleRate)
+}
+
+/*
+ParseWAVHeader parses the RIFF/WAV header at the start of data. A data
+chunk whose declared size runs past the end of data, as written by
+recorders that stream before they know the length, is truncated to the
+bytes actually present.
+*/
+func ParseWAVHeader(data []byte) (*Header, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAV file")
+	}
+
+	header := &Header{}
+	haveFormat := false
+	offset := 12
+	for offset+8 <= len(data) {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		offset += 8
+		switch id {
+		case "fmt ":
+			if size < 16 || offset+size > len(data) {
+				return nil, errors.New("WAV fmt chunk is truncated")
+			}
+			parseFormat(header, data[offset:offset+size])
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("WAV data chunk appears before the fmt chunk")
+			}
+			if size > len(data)-offset || size < 0 {
+				size = len(data) - offset
+			}
+			header.DataOffset = offset
+			header.DataSize = size
+			if header.BlockAlign == 0 {
+				return nil, errors.New("WAV fmt chunk has a block align of zero")
+			}
+			return header, nil
+		}
+		// Chunks are padded to an even number of bytes
+		offset += size + size%2
+	}
+	if !haveFormat {
+		return nil, errors.New("WAV file has no fmt chunk")
+	}
+	return nil, errors.New("WAV file has no data chunk")
+}
+
+// parseFormat reads the fields of a fmt chunk into header
+func parseFormat(header *Header, chunk []byte) {
+	header.Encoding = Encoding(binary.LittleEndian.Uint16(chunk[0:2]))
+	header.Channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+	header.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+	header.BlockAlign = int(binary.LittleEndian.Uint16(chunk[12:14]))
+	header.BitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+	// WAVE_FORMAT_EXTENSIBLE keeps the real format tag at the start of the sub format GUID
+	if header.Encoding == extensible && len(chunk) >= 26 {
+		header.Encoding = Encoding(binary.LittleEndian.Uint16(chunk[24:26]))
+	}
+}
@@ -0,0 +1,111 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseWAVHeader(t *testing.T) {
+	Convey("Parsing WAV headers", t, func() {
+		Convey("Should parse a µ-law file with a fact chunk", func() {
+			data, err := os.ReadFile("../test/test.wav")
+			So(err, ShouldBeNil)
+			header, err := ParseWAVHeader(data)
+			So(err, ShouldBeNil)
+			So(header.Encoding, ShouldEqual, MuLaw)
+			So(header.SampleRate, ShouldEqual, 8000)
+			So(header.Channels, ShouldEqual, 1)
+			So(header.BitsPerSample, ShouldEqual, 8)
+			So(header.DataOffset, ShouldEqual, 58)
+			So(header.DataSize, ShouldEqual, 92480)
+			So(header.Duration(), ShouldEqual, 11560*time.Millisecond)
+		})
+		Convey("Should parse a PCM file", func() {
+			data, err := os.ReadFile("../test/tts_test.wav")
+			So(err, ShouldBeNil)
+			header, err := ParseWAVHeader(data)
+			So(err, ShouldBeNil)
+			So(header.Format, ShouldResemble, Format{PCM, 16000, 1, 16})
+			So(header.Format.String(), ShouldEqual, "PCM 16-bit 16000 Hz mono")
+		})
+		Convey("Should read the encoding of WAVE_FORMAT_EXTENSIBLE files", func() {
+			header, err := ParseWAVHeader(extensibleWAV())
+			So(err, ShouldBeNil)
+			So(header.Encoding, ShouldEqual, PCM)
+			So(header.Channels, ShouldEqual, 2)
+		})
+		Convey("Should truncate a data size that runs past the end of the file", func() {
+			data := wavBytes(PCM, 1, 8000, 16, 8000)
+			binary.LittleEndian.PutUint32(data[40:44], 0xFFFFFFFF)
+			header, err := ParseWAVHeader(data)
+			So(err, ShouldBeNil)
+			So(header.DataSize, ShouldEqual, 16000)
+			So(header.Duration(), ShouldEqual, time.Second)
+		})
+		Convey("Should reject files that are not WAV", func() {
+			_, err := ParseWAVHeader([]byte("#!AMR\n"))
+			So(err.Error(), ShouldEqual, "not a RIFF/WAV file")
+		})
+		Convey("Should reject files without a data chunk", func() {
+			data := wavBytes(PCM, 1, 8000, 16, 0)
+			_, err := ParseWAVHeader(data[:36])
+			So(err.Error(), ShouldEqual, "WAV file has no data chunk")
+		})
+	})
+}
+
+func TestEncoding(t *testing.T) {
+	Convey("Should name encodings", t, func() {
+		So(ALaw.String(), ShouldEqual, "A-law")
+		So(Encoding(0x55).String(), ShouldEqual, "format 0x0055")
+	})
+}
+
+// wavBytes builds a WAV file with the given format and number of silent frames
+func wavBytes(encoding Encoding, channels int, sampleRate int, bitsPerSample int, frames int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+	dataSize := frames * blockAlign
+	data := &bytes.Buffer{}
+	data.WriteString("RIFF")
+	binary.Write(data, binary.LittleEndian, uint32(36+dataSize))
+	data.WriteString("WAVEfmt ")
+	binary.Write(data, binary.LittleEndian, uint32(16))
+	binary.Write(data, binary.LittleEndian, uint16(encoding))
+	binary.Write(data, binary.LittleEndian, uint16(channels))
+	binary.Write(data, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(data, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(data, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(data, binary.LittleEndian, uint16(bitsPerSample))
+	data.WriteString("data")
+	binary.Write(data, binary.LittleEndian, uint32(dataSize))
+	data.Write(make([]byte, dataSize))
+	return data.Bytes()
+}
+
+// extensibleWAV builds a stereo PCM WAV file using WAVE_FORMAT_EXTENSIBLE
+func extensibleWAV() []byte {
+	data := &bytes.Buffer{}
+	data.WriteString("RIFF")
+	binary.Write(data, binary.LittleEndian, uint32(60+4))
+	data.WriteString("WAVEfmt ")
+	binary.Write(data, binary.LittleEndian, uint32(40))
+	binary.Write(data, binary.LittleEndian, uint16(extensible))
+	binary.Write(data, binary.LittleEndian, uint16(2))
+	binary.Write(data, binary.LittleEndian, uint32(44100))
+	binary.Write(data, binary.LittleEndian, uint32(44100*4))
+	binary.Write(data, binary.LittleEndian, uint16(4))
+	binary.Write(data, binary.LittleEndian, uint16(16))
+	binary.Write(data, binary.LittleEndian, uint16(22))
+	binary.Write(data, binary.LittleEndian, uint16(16))
+	binary.Write(data, binary.LittleEndian, uint32(3))
+	binary.Write(data, binary.LittleEndian, uint16(PCM))
+	data.Write(make([]byte, 14))
+	data.WriteString("data")
+	binary.Write(data, binary.LittleEndian, uint32(4))
+	data.Write(make([]byte, 4))
+	return data.Bytes()
+}
@@ -0,0 +1,301 @@
+/*
+Package batch transcribes many audio files with a bounded number of
+concurrent SpeechToText requests sharing one attspeech.Client.
+
+	client := attspeech.New("<id>", "<secret>", "")
+	client.SetAuthTokens()
+	jobs, err := batch.Dir("/var/spool/voicemail")
+	summary, err := batch.Run(context.Background(), client, jobs, &batch.Options{
+		Workers:    8,
+		Checkpoint: "/var/lib/voicemail/checkpoint.ndjson",
+		OutputDir:  "/var/lib/voicemail/results",
+	})
+
+Progress is appended to the checkpoint file as each file completes, so a
+run that crashes or is cancelled picks up where it left off when it is
+started again with the same checkpoint.
+*/
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of concurrent recognitions used when Options.Workers is not set
+const DefaultWorkers = 4
+
+// Extensions are the audio file extensions picked up by Dir
+var Extensions = map[string]bool{
+	".wav":  true,
+	".amr":  true,
+	".awb":  true,
+	".spx":  true,
+	".opus": true,
+	".flac": true,
+	".au":   true,
+	".ul":   true,
+}
+
+// Job is a single audio file to transcribe
+type Job struct {
+	// Path is where the audio is read from
+	Path string `json:"path"`
+	// Name identifies the job in results and names its output file
+	Name string `json:"name"`
+	// ContentType of the audio, detected from the audio if empty
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// Result is the outcome of a single Job
+type Result struct {
+	Name        string                 `json:"name"`
+	Path        string                 `json:"path"`
+	Recognition *attspeech.Recognition `json:"recognition,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Elapsed     time.Duration          `json:"elapsed"`
+}
+
+/*
+Summary reports the outcome of a Run. Skipped counts the jobs that already
+completed according to the checkpoint, or were not started before cancellation.
+*/
+type Summary struct {
+	Total     int      `json:"total"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Skipped   int      `json:"skipped"`
+	Failures  []Result `json:"failures"`
+}
+
+// Options configures a Run
+type Options struct {
+	// Workers is the maximum number of recognitions in flight
+	Workers int
+	// Checkpoint is the file completed results are appended to, if set
+	Checkpoint string
+	// OutputDir is where a JSON file per result and summary.json are written, if set
+	OutputDir string
+	// Prepare is called on each APIRequest before it is sent, e.g. to set XSpeechContext
+	Prepare func(apiRequest *attspeech.APIRequest)
+	// OnResult is called with each result as it completes
+	OnResult func(result Result)
+}
+
+/*
+Dir returns a Job for every file under root with an extension listed in
+Extensions, named by its path relative to root
+*/
+func Dir(root string) ([]Job, error) {
+	jobs := []Job{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !Extensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, Job{Path: path, Name: filepath.ToSlash(name)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+/*
+Manifest reads one Job per line from r. Each line holds a path, optionally
+followed by a tab and the content type. Blank lines and lines starting
+with '#' are ignored. Paths that would share a job name, such as
+"../b.wav" and "b.wav", are an error, since their checkpoint entries and
+output files would collide.
+*/
+func Manifest(r io.Reader) ([]Job, error) {
+	jobs := []Job{}
+	paths := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		job := Job{}
+		fields := strings.SplitN(line, "\t", 2)
+		job.Path = strings.TrimSpace(fields[0])
+		if len(fields) == 2 {
+			job.ContentType = strings.TrimSpace(fields[1])
+		}
+		job.Name = manifestName(job.Path)
+		if path, ok := paths[job.Name]; ok {
+			return nil, errors.New("manifest paths " + path + " and " + job.Path + " both name the job " + job.Name)
+		}
+		paths[job.Name] = job.Path
+		jobs = append(jobs, job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// manifestName names a manifest job by its cleaned path, kept relative so it can name an output file
+func manifestName(path string) string {
+	name := filepath.ToSlash(filepath.Clean(path))
+	for strings.HasPrefix(name, "../") {
+		name = name[3:]
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+/*
+Run transcribes jobs using client with at most options.Workers concurrent
+requests. Jobs that already succeeded according to the checkpoint are
+skipped. Run returns once every job has completed or ctx is cancelled;
+individual job failures are reported in the Summary rather than as an error.
+*/
+func Run(ctx context.Context, client *attspeech.Client, jobs []Job, options *Options) (*Summary, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if options.OutputDir != "" {
+		if err := os.MkdirAll(options.OutputDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	summary := &Summary{Total: len(jobs), Failures: []Result{}}
+	var progress *checkpoint
+	if options.Checkpoint != "" {
+		var err error
+		progress, err = openCheckpoint(options.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		defer progress.Close()
+	}
+
+	pending := make(chan Job)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range pending {
+				results <- transcribe(ctx, client, job, options.Prepare)
+			}
+		}()
+	}
+	go func() {
+		defer close(pending)
+		for _, job := range jobs {
+			if progress != nil && progress.done(job.Name) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case pending <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	for result := range results {
+		if result.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+			summary.Failures = append(summary.Failures, result)
+		}
+		if err == nil && progress != nil {
+			err = progress.record(result)
+		}
+		if err == nil && options.OutputDir != "" {
+			err = writeJSON(filepath.Join(options.OutputDir, filepath.FromSlash(result.Name)+".json"), result)
+		}
+		if options.OnResult != nil {
+			options.OnResult(result)
+		}
+	}
+	if err != nil {
+		return summary, err
+	}
+	summary.Skipped = summary.Total - summary.Succeeded - summary.Failed
+	if options.OutputDir != "" {
+		if err := writeJSON(filepath.Join(options.OutputDir, "summary.json"), summary); err != nil {
+			return summary, err
+		}
+	}
+	return summary, ctx.Err()
+}
+
+// transcribe runs a single job through SpeechToText
+func transcribe(ctx context.Context, client *attspeech.Client, job Job, prepare func(*attspeech.APIRequest)) Result {
+	start := time.Now()
+	result := Result{Name: job.Name, Path: job.Path}
+	recognition, err := recognize(ctx, client, job, prepare)
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Recognition = recognition
+	return result
+}
+
+// recognize reads the job's audio and sends it to the API
+func recognize(ctx context.Context, client *attspeech.Client, job Job, prepare func(*attspeech.APIRequest)) (*attspeech.Recognition, error) {
+	data, err := os.ReadFile(job.Path)
+	if err != nil {
+		return nil, err
+	}
+	apiRequest := client.NewAPIRequest(client.STTResource)
+	apiRequest.Data = bytes.NewBuffer(data)
+	apiRequest.ContentType = job.ContentType
+	if prepare != nil {
+		prepare(apiRequest)
+	}
+	return client.SpeechToTextContext(ctx, apiRequest)
+}
+
+// writeJSON writes value as indented JSON to path, creating parent directories
+func writeJSON(path string, value interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,138 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jsgoecke/attspeech"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDir(t *testing.T) {
+	Convey("Should find audio files in a directory tree", t, func() {
+		root := t.TempDir()
+		writeFiles(root, "a.wav", "sub/b.WAV", "notes.txt")
+		jobs, err := Dir(root)
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 2)
+		So(jobs[0].Name, ShouldEqual, "a.wav")
+		So(jobs[1].Name, ShouldEqual, "sub/b.WAV")
+	})
+}
+
+func TestManifest(t *testing.T) {
+	Convey("Should read jobs from a manifest", t, func() {
+		jobs, err := Manifest(strings.NewReader("# voicemail\n/var/spool/a.wav\n\n../b.raw\taudio/raw\n"))
+		So(err, ShouldBeNil)
+		So(len(jobs), ShouldEqual, 2)
+		So(jobs[0].Path, ShouldEqual, "/var/spool/a.wav")
+		So(jobs[0].Name, ShouldEqual, "var/spool/a.wav")
+		So(jobs[1].Name, ShouldEqual, "b.raw")
+		So(jobs[1].ContentType, ShouldEqual, "audio/raw")
+	})
+	Convey("Should reject paths that would share a job name", t, func() {
+		_, err := Manifest(strings.NewReader("../b.raw\nb.raw\n"))
+		So(err.Error(), ShouldEqual, "manifest paths ../b.raw and b.raw both name the job b.raw")
+		_, err = Manifest(strings.NewReader("/a/x.wav\n# same name\na/x.wav\n"))
+		So(err.Error(), ShouldEqual, "manifest paths /a/x.wav and a/x.wav both name the job a/x.wav")
+	})
+}
+
+func TestRun(t *testing.T) {
+	Convey("Running a batch", t, func() {
+		var inFlight, maxInFlight, requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","expires_in":500}`))
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if req.Header.Get("Content-Type") == "audio/amr" {
+				w.WriteHeader(400)
+				w.Write([]byte(`{"RequestError":{"ServiceException":{"MessageId":"SVC0002","Text":"Invalid input value for message part %1","Variables":"Content-Type"}}}`))
+				return
+			}
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"hello"}]}}`))
+		}))
+		defer ts.Close()
+		client := attspeech.New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+
+		root := t.TempDir()
+		writeFiles(root, "1.wav", "2.wav", "3.wav", "4.wav", "5.wav", "6.amr")
+		jobs, _ := Dir(root)
+		output := t.TempDir()
+		options := &Options{
+			Workers:    2,
+			Checkpoint: filepath.Join(output, "checkpoint.ndjson"),
+			OutputDir:  filepath.Join(output, "results"),
+		}
+
+		summary, err := Run(context.Background(), client, jobs, options)
+
+		Convey("Should report successes and failures", func() {
+			So(err, ShouldBeNil)
+			So(summary.Total, ShouldEqual, 6)
+			So(summary.Succeeded, ShouldEqual, 5)
+			So(summary.Failed, ShouldEqual, 1)
+			So(summary.Failures[0].Name, ShouldEqual, "6.amr")
+			So(summary.Failures[0].Error, ShouldEqual, "SVC0002 - Invalid input value for message part %1 - Content-Type")
+		})
+		Convey("Should not exceed the worker limit", func() {
+			So(atomic.LoadInt32(&maxInFlight), ShouldBeLessThanOrEqualTo, 2)
+		})
+		Convey("Should write per-file results and a summary", func() {
+			result := Result{}
+			data, err := os.ReadFile(filepath.Join(options.OutputDir, "1.wav.json"))
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &result), ShouldBeNil)
+			So(result.Recognition.Recognition.NBest[0].ResultText, ShouldEqual, "hello")
+			_, err = os.Stat(filepath.Join(options.OutputDir, "summary.json"))
+			So(err, ShouldBeNil)
+		})
+		Convey("Should resume from the checkpoint, retrying only failures", func() {
+			atomic.StoreInt32(&requests, 0)
+			summary, err := Run(context.Background(), client, jobs, options)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+			So(summary.Skipped, ShouldEqual, 5)
+			So(summary.Failed, ShouldEqual, 1)
+		})
+		Convey("Should stop handing out jobs once cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			summary, err := Run(ctx, client, jobs, &Options{Workers: 1})
+			So(err, ShouldEqual, context.Canceled)
+			So(summary.Skipped, ShouldBeGreaterThan, 0)
+		})
+	})
+}
+
+func writeFiles(root string, names ...string) {
+	wav, _ := os.ReadFile("../test/test.wav")
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if filepath.Ext(name) == ".amr" {
+			os.WriteFile(path, []byte("#!AMR\n"), 0644)
+			continue
+		}
+		os.WriteFile(path, wav, 0644)
+	}
+}
@@ -0,0 +1,65 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+/*
+checkpoint is an append-only NDJSON log of completed results. Only
+successful results mark a job as done, so failures are retried when a
+run is resumed.
+*/
+type checkpoint struct {
+	file      *os.File
+	completed map[string]bool
+}
+
+// openCheckpoint loads the completed jobs from path and opens it for appending
+func openCheckpoint(path string) (*checkpoint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	completed := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		result := Result{}
+		// A line torn by a crash fails to parse and its job is simply run again
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		if result.Error == "" {
+			completed[result.Name] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &checkpoint{file: file, completed: completed}, nil
+}
+
+// done reports whether the named job completed successfully in an earlier run
+func (c *checkpoint) done(name string) bool {
+	return c.completed[name]
+}
+
+// record appends a result to the checkpoint and syncs it to disk
+func (c *checkpoint) record(result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// Close closes the checkpoint file
+func (c *checkpoint) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,187 @@
+package attspeech
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jsgoecke/attspeech/cache"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStats counts the lookups in one of the client's caches
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// cacheCounters records cache lookups as they happen
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// stats returns a snapshot of the counters
+func (counters *cacheCounters) stats() CacheStats {
+	return CacheStats{Hits: counters.hits.Load(), Misses: counters.misses.Load()}
+}
+
+// record counts a lookup as a hit or a miss
+func (counters *cacheCounters) record(hit bool) {
+	if hit {
+		counters.hits.Add(1)
+	} else {
+		counters.misses.Add(1)
+	}
+}
+
+// TTSCacheStats reports how often Text to Speech audio was found in client.TTSCache
+func (client *Client) TTSCacheStats() CacheStats {
+	return client.ttsCacheCounters.stats()
+}
+
+// RecognitionCacheStats reports how often recognitions were found in client.RecognitionCache
+func (client *Client) RecognitionCacheStats() CacheStats {
+	return client.recognitionCacheCounters.stats()
+}
+
+/*
+TTSCacheKey returns the key Text to Speech audio is cached under, a hash
+of everything that changes the audio: the text and its content type, the
+voice, tempo, volume, language, Accept type and X-Arg, and the chunking
+that splits and joins long text, which is nil when the client does not
+chunk.
+
+	key := attspeech.TTSCacheKey(apiRequest, client.TextChunking)
+*/
+func TTSCacheKey(apiRequest *APIRequest, chunking *ChunkOptions) string {
+	parts := []string{"tts", apiRequest.Text, apiRequest.ContentType, apiRequest.VoiceName, apiRequest.Tempo,
+		apiRequest.Volume, apiRequest.ContentLanguage, apiRequest.Accept, apiRequest.XArg}
+	if chunking != nil {
+		maxCharacters := chunking.MaxCharacters
+		if maxCharacters <= 0 {
+			maxCharacters = DefaultMaxCharacters
+		}
+		parts = append(parts, "chunked", strconv.Itoa(maxCharacters), chunking.Silence.String())
+	}
+	return cache.Key(parts...)
+}
+
+/*
+cachedTextToSpeech returns the cached audio for the request, synthesizing
+and caching it on a miss. The cache only saves work, so a store that
+fails to read or write does not fail the request.
+*/
+func (client *Client) cachedTextToSpeech(ctx context.Context, apiRequest *APIRequest) ([]byte, error) {
+	key := TTSCacheKey(apiRequest, client.TextChunking)
+	data, ok, err := client.TTSCache.Get(key)
+	client.ttsCacheCounters.record(err == nil && ok)
+	trace.SpanFromContext(ctx).SetAttributes(CacheHitKey.Bool(err == nil && ok))
+	if err == nil && ok {
+		return data, nil
+	}
+	data, err = client.synthesize(ctx, apiRequest)
+	if err != nil {
+		return nil, err
+	}
+	client.TTSCache.Set(key, data)
+	return data, nil
+}
+
+/*
+WarmTTSCache synthesizes the prompts not already in client.TTSCache, so
+the first callers to use them do not wait. Every prompt is attempted; the
+number synthesized and the first error are returned.
+
+	client.TTSCache = cache.Tiered(cache.NewMemory(64<<20, 0), disk)
+	prompts := []*attspeech.APIRequest{}
+	for _, text := range []string{"Welcome.", "Please hold."} {
+		apiRequest := client.NewAPIRequest(client.TTSResource)
+		apiRequest.VoiceName = "crystal"
+		apiRequest.Text = text
+		prompts = append(prompts, apiRequest)
+	}
+	synthesized, err := client.WarmTTSCache(ctx, prompts)
+*/
+func (client *Client) WarmTTSCache(ctx context.Context, apiRequests []*APIRequest) (int, error) {
+	if client.TTSCache == nil {
+		return 0, nil
+	}
+	var (
+		mu          sync.Mutex
+		synthesized int
+		first       error
+		wg          sync.WaitGroup
+	)
+	indexes := make(chan int)
+	for i := 0; i < DefaultSegmentWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				apiRequest := apiRequests[index]
+				if err := prepareSSML(apiRequest); err == nil {
+					if _, ok, err := client.TTSCache.Get(TTSCacheKey(apiRequest, client.TextChunking)); err == nil && ok {
+						continue
+					}
+				}
+				_, err := client.TextToSpeechContext(ctx, apiRequest)
+				mu.Lock()
+				if err == nil {
+					synthesized++
+				} else if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for index := range apiRequests {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return synthesized, first
+}
+
+/*
+RecognitionCacheKey returns the key a recognition is cached under, a hash
+of the audio and the parameters that change the result: the declared
+content type, the speech context and sub context, the language, the
+X-Arg options, and for Speech to Text Custom the grammar and dictionary.
+*/
+func RecognitionCacheKey(apiRequest *APIRequest, grammar string, dictionary string) string {
+	audio := sha256.Sum256(apiRequest.Data.Bytes())
+	return cache.Key("recognition", hex.EncodeToString(audio[:]), apiRequest.ContentType, apiRequest.XSpeechContext,
+		apiRequest.XSpeechSubContext, apiRequest.ContentLanguage, apiRequest.XArg, grammar, dictionary)
+}
+
+/*
+cachedRecognition looks the request up in client.RecognitionCache,
+returning its key, to store the result under on a miss, and the cached
+recognition on a hit. The key is empty when there is no cache.
+*/
+func (client *Client) cachedRecognition(apiRequest *APIRequest, grammar string, dictionary string) (string, *Recognition) {
+	if client.RecognitionCache == nil {
+		return "", nil
+	}
+	key := RecognitionCacheKey(apiRequest, grammar, dictionary)
+	data, ok, err := client.RecognitionCache.Get(key)
+	recognition := &Recognition{}
+	hit := err == nil && ok && json.Unmarshal(data, recognition) == nil
+	client.recognitionCacheCounters.record(hit)
+	if !hit {
+		return key, nil
+	}
+	return key, recognition
+}
+
+// storeRecognition caches the body of a successful recognition response
+func (client *Client) storeRecognition(key string, body []byte) {
+	if key != "" {
+		client.RecognitionCache.Set(key, body)
+	}
+}
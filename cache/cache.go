@@ -0,0 +1,76 @@
+/*
+Package cache stores synthesized audio and recognition results so
+identical requests are not sent to the AT&T Speech API twice. Stores are
+keyed by strings, usually hashes of the request, and hold raw bytes.
+
+	memory := cache.NewMemory(64<<20, 24*time.Hour)
+	disk, err := cache.NewDisk("/var/cache/attspeech", 1<<30, 30*24*time.Hour)
+	client.TTSCache = cache.Tiered(memory, disk)
+	client.RecognitionCache = disk
+*/
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Store is a cache of byte values, implementations must be safe for concurrent use
+type Store interface {
+	// Get returns the value for key, and false when it is missing or expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key
+	Set(key string, value []byte) error
+}
+
+// tiered checks faster stores before slower ones
+type tiered []Store
+
+/*
+Tiered combines stores from fastest to slowest, such as a Memory in front
+of a Disk. Values are written to every store, and a value found in a
+slower store is copied into the faster stores before it.
+*/
+func Tiered(stores ...Store) Store {
+	return tiered(stores)
+}
+
+// Get returns the value from the first store that has it
+func (stores tiered) Get(key string) ([]byte, bool, error) {
+	for i, store := range stores {
+		value, ok, err := store.Get(key)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			continue
+		}
+		for _, faster := range stores[:i] {
+			if err := faster.Set(key, value); err != nil {
+				return nil, false, err
+			}
+		}
+		return value, true, nil
+	}
+	return nil, false, nil
+}
+
+// Set stores value in every store
+func (stores tiered) Set(key string, value []byte) error {
+	for _, store := range stores {
+		if err := store.Set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key hashes parts into a key, separating them so ("ab", "c") and ("a", "bc") differ
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
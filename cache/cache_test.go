@@ -0,0 +1,42 @@
+package cache
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestTiered(t *testing.T) {
+	Convey("Combining stores", t, func() {
+		fast := NewMemory(0, 0)
+		slow := NewMemory(0, 0)
+		store := Tiered(fast, slow)
+		Convey("Should write to every store", func() {
+			So(store.Set("a", []byte("1")), ShouldBeNil)
+			So(fast.Len(), ShouldEqual, 1)
+			So(slow.Len(), ShouldEqual, 1)
+		})
+		Convey("Should copy values found in slower stores into faster ones", func() {
+			slow.Set("a", []byte("1"))
+			value, ok, err := store.Get("a")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "1")
+			value, ok, _ = fast.Get("a")
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "1")
+		})
+		Convey("Should miss when no store has the value", func() {
+			_, ok, err := store.Get("b")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestKey(t *testing.T) {
+	Convey("Hashing keys", t, func() {
+		So(Key("ab", "c"), ShouldNotEqual, Key("a", "bc"))
+		So(Key("a", "b"), ShouldEqual, Key("a", "b"))
+		So(Key("a"), ShouldHaveLength, 64)
+	})
+}
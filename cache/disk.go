@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tempPrefix marks files being written, which are ignored until renamed into place
+const tempPrefix = ".tmp-"
+
+/*
+Disk is a cache of files in a directory, limited by their total size.
+Values are written to a temporary file and renamed into place, so readers,
+including other processes sharing the directory, never see partial values.
+When the limit is exceeded the oldest values are removed first.
+*/
+type Disk struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+
+	mu   sync.Mutex
+	size int64
+}
+
+// file is a value stored on disk
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewDisk opens or creates a cache in dir holding up to maxBytes of values for ttl, zero meaning no limit
+func NewDisk(dir string, maxBytes int64, ttl time.Duration) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	disk := &Disk{dir: dir, maxBytes: maxBytes, ttl: ttl}
+	files, err := disk.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		disk.size += file.size
+	}
+	return disk, nil
+}
+
+// Get reads the value for key, removing it if it has expired
+func (disk *Disk) Get(key string) ([]byte, bool, error) {
+	path, err := disk.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if disk.ttl > 0 && time.Since(info.ModTime()) > disk.ttl {
+		disk.mu.Lock()
+		if os.Remove(path) == nil {
+			disk.size -= info.Size()
+		}
+		disk.mu.Unlock()
+		return nil, false, nil
+	}
+	value, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Removed by another writer's eviction since the Stat
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+// Set atomically writes value, then evicts the oldest values if the cache is over its size limit
+func (disk *Disk) Set(key string, value []byte) error {
+	path, err := disk.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(value); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	disk.mu.Lock()
+	defer disk.mu.Unlock()
+	if info, err := os.Stat(path); err == nil {
+		disk.size -= info.Size()
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	disk.size += int64(len(value))
+	if disk.maxBytes > 0 && disk.size > disk.maxBytes {
+		return disk.evict()
+	}
+	return nil
+}
+
+// evict removes the oldest values until the cache is a tenth under its limit, the caller must hold the lock
+func (disk *Disk) evict() error {
+	files, err := disk.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	// Recount, as other processes sharing the directory may have changed it
+	disk.size = 0
+	for _, file := range files {
+		disk.size += file.size
+	}
+	target := disk.maxBytes - disk.maxBytes/10
+	for _, file := range files {
+		if disk.size <= target {
+			break
+		}
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		disk.size -= file.size
+	}
+	return nil
+}
+
+// files lists the values stored in the cache
+func (disk *Disk) files() ([]file, error) {
+	files := []file{}
+	err := filepath.WalkDir(disk.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// path returns the file holding key, spread over subdirectories named by the key's first two characters
+func (disk *Disk) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", errors.New("invalid cache key " + key)
+	}
+	shard := "_"
+	if len(key) > 2 {
+		shard = key[:2]
+	}
+	return filepath.Join(disk.dir, shard, key), nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDisk(t *testing.T) {
+	Convey("Caching on disk", t, func() {
+		dir := t.TempDir()
+		Convey("Should store values across instances", func() {
+			disk, err := NewDisk(dir, 0, 0)
+			So(err, ShouldBeNil)
+			So(disk.Set("abcdef", []byte("value")), ShouldBeNil)
+			_, err = os.Stat(filepath.Join(dir, "ab", "abcdef"))
+			So(err, ShouldBeNil)
+			reopened, _ := NewDisk(dir, 0, 0)
+			value, ok, err := reopened.Get("abcdef")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(string(value), ShouldEqual, "value")
+			So(reopened.size, ShouldEqual, 5)
+		})
+		Convey("Should miss missing values", func() {
+			disk, _ := NewDisk(dir, 0, 0)
+			_, ok, err := disk.Get("missing")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Should leave no temporary files behind", func() {
+			disk, _ := NewDisk(dir, 0, 0)
+			disk.Set("abcdef", []byte("one"))
+			disk.Set("abcdef", []byte("two"))
+			entries, _ := os.ReadDir(filepath.Join(dir, "ab"))
+			So(len(entries), ShouldEqual, 1)
+			So(disk.size, ShouldEqual, 3)
+		})
+		Convey("Should evict the oldest values over the size limit", func() {
+			disk, _ := NewDisk(dir, 10, 0)
+			for i, key := range []string{"key1", "key2", "key3"} {
+				disk.Set(key, []byte("1234"))
+				old := time.Now().Add(time.Duration(i-10) * time.Minute)
+				os.Chtimes(filepath.Join(dir, "ke", key), old, old)
+			}
+			_, ok, _ := disk.Get("key1")
+			So(ok, ShouldBeFalse)
+			_, ok, _ = disk.Get("key3")
+			So(ok, ShouldBeTrue)
+			So(disk.size, ShouldBeLessThanOrEqualTo, 10)
+		})
+		Convey("Should expire values after the TTL", func() {
+			disk, _ := NewDisk(dir, 0, time.Hour)
+			disk.Set("abcdef", []byte("value"))
+			old := time.Now().Add(-2 * time.Hour)
+			os.Chtimes(filepath.Join(dir, "ab", "abcdef"), old, old)
+			_, ok, _ := disk.Get("abcdef")
+			So(ok, ShouldBeFalse)
+			_, err := os.Stat(filepath.Join(dir, "ab", "abcdef"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+		Convey("Should reject keys that escape the directory", func() {
+			disk, _ := NewDisk(dir, 0, 0)
+			So(disk.Set("../x", []byte("x")).Error(), ShouldEqual, "invalid cache key ../x")
+		})
+	})
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory least recently used cache limited by the total size of its values
+type Memory struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// recent orders entries from the most to the least recently used
+	recent *list.List
+}
+
+// entry is a value held by Memory
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a cache holding up to maxBytes of values for ttl, zero meaning no limit
+func NewMemory(maxBytes int64, ttl time.Duration) *Memory {
+	return &Memory{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Get returns a copy of the value for key and marks it as recently used
+func (memory *Memory) Get(key string) ([]byte, bool, error) {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	element, ok := memory.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*entry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		memory.remove(element)
+		return nil, false, nil
+	}
+	memory.recent.MoveToFront(element)
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores a copy of value, evicting the least recently used values to make room
+func (memory *Memory) Set(key string, value []byte) error {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	if element, ok := memory.entries[key]; ok {
+		memory.remove(element)
+	}
+	if memory.maxBytes > 0 && int64(len(value)) > memory.maxBytes {
+		return nil
+	}
+	expires := time.Time{}
+	if memory.ttl > 0 {
+		expires = time.Now().Add(memory.ttl)
+	}
+	value = append([]byte(nil), value...)
+	memory.entries[key] = memory.recent.PushFront(&entry{key: key, value: value, expires: expires})
+	memory.size += int64(len(value))
+	for memory.maxBytes > 0 && memory.size > memory.maxBytes {
+		memory.remove(memory.recent.Back())
+	}
+	return nil
+}
+
+// Len returns the number of values held
+func (memory *Memory) Len() int {
+	memory.mu.Lock()
+	defer memory.mu.Unlock()
+	return len(memory.entries)
+}
+
+// remove drops an entry, the caller must hold the lock
+func (memory *Memory) remove(element *list.Element) {
+	entry := memory.recent.Remove(element).(*entry)
+	delete(memory.entries, entry.key)
+	memory.size -= int64(len(entry.value))
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	Convey("Caching in memory", t, func() {
+		Convey("Should evict the least recently used values over the size limit", func() {
+			memory := NewMemory(10, 0)
+			memory.Set("a", []byte("aaaa"))
+			memory.Set("b", []byte("bbbb"))
+			memory.Get("a")
+			memory.Set("c", []byte("cccc"))
+			_, ok, _ := memory.Get("b")
+			So(ok, ShouldBeFalse)
+			_, ok, _ = memory.Get("a")
+			So(ok, ShouldBeTrue)
+			So(memory.Len(), ShouldEqual, 2)
+		})
+		Convey("Should replace values and skip values larger than the limit", func() {
+			memory := NewMemory(10, 0)
+			memory.Set("a", []byte("aaaa"))
+			memory.Set("a", []byte("AAAAAA"))
+			value, _, _ := memory.Get("a")
+			So(string(value), ShouldEqual, "AAAAAA")
+			memory.Set("b", []byte("bbbbbbbbbbbb"))
+			_, ok, _ := memory.Get("b")
+			So(ok, ShouldBeFalse)
+			So(memory.Len(), ShouldEqual, 1)
+		})
+		Convey("Should not share values with callers", func() {
+			memory := NewMemory(0, 0)
+			value := []byte("audio")
+			memory.Set("a", value)
+			value[0] = 'A'
+			got, _, _ := memory.Get("a")
+			So(string(got), ShouldEqual, "audio")
+			got[0] = 'X'
+			got, _, _ = memory.Get("a")
+			So(string(got), ShouldEqual, "audio")
+		})
+		Convey("Should expire values after the TTL", func() {
+			memory := NewMemory(0, 10*time.Millisecond)
+			memory.Set("a", []byte("a"))
+			_, ok, _ := memory.Get("a")
+			So(ok, ShouldBeTrue)
+			time.Sleep(20 * time.Millisecond)
+			_, ok, _ = memory.Get("a")
+			So(ok, ShouldBeFalse)
+			So(memory.Len(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package attspeech
+
+import (
+	"bytes"
+	"context"
+	"github.com/jsgoecke/attspeech/cache"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecognitionCache(t *testing.T) {
+	Convey("Caching recognitions", t, func() {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+			w.Write(recognitionJSON())
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		client.RecognitionCache = cache.NewMemory(0, 0)
+		wav := wavBytes(1, 1, 8000, 16, 800)
+		recognize := func(speechContext string) (*Recognition, error) {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.Data = bytes.NewBuffer(wav)
+			apiRequest.XSpeechContext = speechContext
+			return client.SpeechToText(apiRequest)
+		}
+
+		Convey("Should recognize identical audio and parameters once", func() {
+			first, err := recognize("Generic")
+			So(err, ShouldBeNil)
+			second, err := recognize("Generic")
+			So(err, ShouldBeNil)
+			So(second, ShouldResemble, first)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+			So(client.RecognitionCacheStats(), ShouldResemble, CacheStats{Hits: 1, Misses: 1})
+		})
+		Convey("Should miss when the parameters differ", func() {
+			recognize("Generic")
+			recognize("Voicemail")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+		})
+		Convey("Should key on the content type and X-Arg options", func() {
+			request := func(contentType string, xArg string) *APIRequest {
+				apiRequest := client.NewAPIRequest(client.STTResource)
+				apiRequest.Data = bytes.NewBuffer(wav)
+				apiRequest.ContentType = contentType
+				apiRequest.XArg = xArg
+				return apiRequest
+			}
+			key := RecognitionCacheKey(request("audio/wav", ""), "", "")
+			So(key, ShouldEqual, RecognitionCacheKey(request("audio/wav", ""), "", ""))
+			So(key, ShouldNotEqual, RecognitionCacheKey(request("audio/x-wav", ""), "", ""))
+			So(key, ShouldNotEqual, RecognitionCacheKey(request("audio/wav", "ShowWordTokens=true"), "", ""))
+		})
+		Convey("Should key custom recognitions on the grammar and dictionary", func() {
+			custom := func(grammar string) {
+				apiRequest := client.NewAPIRequest(client.STTCResource)
+				apiRequest.Data = bytes.NewBuffer(wav)
+				apiRequest.Filename = "test.wav"
+				_, err := client.SpeechToTextCustom(apiRequest, grammar, "")
+				So(err, ShouldBeNil)
+			}
+			custom("<grammar/>")
+			custom("<grammar/>")
+			custom("<grammar root=\"other\"/>")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+			So(client.RecognitionCacheStats().Hits, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestTTSCache(t *testing.T) {
+	Convey("Caching Text to Speech audio", t, func() {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+			data, _ := ioutil.ReadAll(req.Body)
+			if string(data) == "fail" {
+				w.WriteHeader(400)
+				w.Write(contentTypeErrorJSON())
+				return
+			}
+			w.Write([]byte("RIFF" + string(data)))
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		client.TTSCache = cache.NewMemory(0, 0)
+		prompt := func(text string, voice string) *APIRequest {
+			apiRequest := client.NewAPIRequest(TTSResource)
+			apiRequest.Text = text
+			apiRequest.VoiceName = voice
+			return apiRequest
+		}
+
+		Convey("Should synthesize identical requests once", func() {
+			first, err := client.TextToSpeech(prompt("Welcome", "crystal"))
+			So(err, ShouldBeNil)
+			second, err := client.TextToSpeech(prompt("Welcome", "crystal"))
+			So(err, ShouldBeNil)
+			So(string(second), ShouldEqual, string(first))
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+			So(client.TTSCacheStats(), ShouldResemble, CacheStats{Hits: 1, Misses: 1})
+		})
+		Convey("Should key on every parameter that changes the audio", func() {
+			So(TTSCacheKey(prompt("Welcome", "crystal"), nil), ShouldNotEqual, TTSCacheKey(prompt("Welcome", "mike"), nil))
+			slow := prompt("Welcome", "crystal")
+			slow.Tempo = "-5"
+			So(TTSCacheKey(slow, nil), ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), nil))
+			amr := prompt("Welcome", "crystal")
+			amr.Accept = "audio/amr"
+			So(TTSCacheKey(amr, nil), ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), nil))
+			args := prompt("Welcome", "crystal")
+			args.XArg += ",Pitch=20"
+			So(TTSCacheKey(args, nil), ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), nil))
+			chunked := TTSCacheKey(prompt("Welcome", "crystal"), &ChunkOptions{Silence: 200 * time.Millisecond})
+			So(chunked, ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), nil))
+			So(chunked, ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), &ChunkOptions{Silence: 500 * time.Millisecond}))
+			So(chunked, ShouldNotEqual, TTSCacheKey(prompt("Welcome", "crystal"), &ChunkOptions{MaxCharacters: 100, Silence: 200 * time.Millisecond}))
+			So(chunked, ShouldEqual, TTSCacheKey(prompt("Welcome", "crystal"), &ChunkOptions{MaxCharacters: DefaultMaxCharacters, Workers: 8, Silence: 200 * time.Millisecond}))
+		})
+		Convey("Should not cache failures", func() {
+			client.TextToSpeech(prompt("fail", ""))
+			client.TextToSpeech(prompt("fail", ""))
+			So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+		})
+		Convey("Should warm the cache with the prompts not already cached", func() {
+			client.TextToSpeech(prompt("Welcome", "crystal"))
+			synthesized, err := client.WarmTTSCache(context.Background(), []*APIRequest{
+				prompt("Welcome", "crystal"), prompt("Please hold", "crystal"), prompt("Goodbye", "crystal"), prompt("fail", ""),
+			})
+			So(synthesized, ShouldEqual, 2)
+			So(err.Error(), ShouldEqual, "SVC0002 - Invalid input value for message part %1 - Content-Type")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 4)
+			client.TextToSpeech(prompt("Goodbye", "crystal"))
+			So(atomic.LoadInt32(&requests), ShouldEqual, 4)
+		})
+	})
+}
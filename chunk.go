@@ -0,0 +1,105 @@
+package attspeech
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech/audio"
+	"github.com/jsgoecke/attspeech/ssml"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxCharacters is the most text sent in one Text to Speech request when ChunkOptions.MaxCharacters is not set
+const DefaultMaxCharacters = 4000
+
+/*
+ChunkOptions configures how Text to Speech splits long text. Text is split
+at sentence, then clause, then word boundaries, SSML between elements as
+well, and the chunks are synthesized concurrently and joined into a single
+WAV or AMR file.
+
+	client.TextChunking = &attspeech.ChunkOptions{Silence: 200 * time.Millisecond}
+	data, err := client.TextToSpeech(apiRequest)
+*/
+type ChunkOptions struct {
+	// MaxCharacters is the most characters of text, or SSML markup, sent in one request
+	MaxCharacters int
+	// Workers is the number of chunks synthesized concurrently
+	Workers int
+	// Silence is inserted between chunks
+	Silence time.Duration
+}
+
+// textToSpeechChunks synthesizes text over the character limit in chunks and joins the audio
+func (client *Client) textToSpeechChunks(ctx context.Context, apiRequest *APIRequest, options *ChunkOptions) ([]byte, error) {
+	max := options.MaxCharacters
+	if max <= 0 {
+		max = DefaultMaxCharacters
+	}
+	if utf8.RuneCountInString(apiRequest.Text) <= max {
+		return client.textToSpeech(ctx, apiRequest)
+	}
+	chunks, err := splitText(apiRequest, max)
+	if err != nil {
+		return nil, err
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = DefaultSegmentWorkers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	parts := make([][]byte, len(chunks))
+	errs := make([]error, len(chunks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				request := *apiRequest
+				request.Text = chunks[index]
+				parts[index], errs[index] = client.textToSpeech(ctx, &request)
+				if errs[index] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	for index := range chunks {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Report the failure that caused the cancellation rather than the chunks it cancelled
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return audio.Concat(parts, options.Silence)
+}
+
+// splitText splits plain text or SSML into chunks of at most max characters
+func splitText(apiRequest *APIRequest, max int) ([]string, error) {
+	if strings.HasPrefix(strings.ToLower(apiRequest.ContentType), ssml.ContentType) {
+		return ssml.Split(apiRequest.Text, max)
+	}
+	chunks := []string{}
+	for _, chunk := range ssml.SplitText(apiRequest.Text, max) {
+		if chunk = strings.TrimSpace(chunk); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
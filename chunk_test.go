@@ -0,0 +1,76 @@
+package attspeech
+
+import (
+	"github.com/jsgoecke/attspeech/audio"
+	"github.com/jsgoecke/attspeech/ssml"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTextToSpeechChunks(t *testing.T) {
+	Convey("Synthesizing long text in chunks", t, func() {
+		var mu sync.Mutex
+		texts := []string{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			data, _ := ioutil.ReadAll(req.Body)
+			mu.Lock()
+			texts = append(texts, string(data))
+			mu.Unlock()
+			if strings.Contains(string(data), "fail") {
+				w.WriteHeader(400)
+				w.Write(contentTypeErrorJSON())
+				return
+			}
+			// A tenth of a second of audio per character
+			w.Write(wavBytes(1, 1, 8000, 16, len(data)*800))
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		client.TextChunking = &ChunkOptions{MaxCharacters: 20, Workers: 2, Silence: 500 * time.Millisecond}
+		apiRequest := client.NewAPIRequest(TTSResource)
+
+		Convey("Should split text at sentences and join the audio with silence", func() {
+			apiRequest.Text = "This is one. This is two. This is three."
+			data, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(texts, ShouldHaveLength, 3)
+			So(texts, ShouldContain, "This is one.")
+			So(texts, ShouldContain, "This is three.")
+			header, err := audio.ParseWAVHeader(data)
+			So(err, ShouldBeNil)
+			So(header.Duration(), ShouldEqual, (12+12+14)*100*time.Millisecond+2*500*time.Millisecond)
+		})
+		Convey("Should send short text in one request", func() {
+			apiRequest.Text = "Hello."
+			_, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(texts, ShouldResemble, []string{"Hello."})
+		})
+		Convey("Should split SSML into valid documents", func() {
+			client.TextChunking.MaxCharacters = 60
+			apiRequest.Text = `<speak version="1.0"><s>First sentence.</s><s>Second sentence.</s></speak>`
+			_, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(texts, ShouldHaveLength, 2)
+			for _, text := range texts {
+				So(ssml.Validate(text), ShouldBeNil)
+			}
+		})
+		Convey("Should fail when any chunk fails", func() {
+			apiRequest.Text = "This will fail. This is fine."
+			_, err := client.TextToSpeech(apiRequest)
+			So(err.Error(), ShouldEqual, "SVC0002 - Invalid input value for message part %1 - Content-Type")
+		})
+	})
+}
@@ -0,0 +1,150 @@
+/*
+attspeech-gateway holds the AT&T Speech API credentials and tokens for a
+group of internal services, exposing the client as a local REST service.
+
+	attspeech-gateway [-addr :8080] [-keys file] [-max-bytes n] [-refresh d] [-origins list] [-metrics] [-v]
+
+Endpoints:
+
+	POST /stt   recognize the audio in the request body
+	POST /sttc  recognize the "audio" part of a multipart form with its "grammar" and optional "dictionary"
+	POST /tts   synthesize the JSON {"text", "voice", "tempo", "volume", "language", "accept"}
+	GET /stream recognize audio streamed over a WebSocket
+	GET /healthz
+
+Callers authenticate with one of the API keys as an X-API-Key header, as
+a bearer token or, for browsers opening a stream, as the key query
+parameter. Keys are read from the -keys file, one per line, and from the
+comma separated ATTSPEECH_GATEWAY_KEYS environment variable. Credentials are read from ATT_APP_KEY, ATT_APP_SECRET and
+ATT_API_BASE.
+
+	curl -H "X-API-Key: $KEY" -H "Content-Type: audio/wav" --data-binary @test.wav localhost:8080/stt
+*/
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/metrics"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultMaxBytes is the default limit on request bodies, the largest audio file the API accepts
+const defaultMaxBytes = 10 << 20
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run parses the flags, fetches the tokens and serves until interrupted
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("attspeech-gateway", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	keysPath := flags.String("keys", "", "file of API keys accepted from callers, one per line")
+	maxBytes := flags.Int64("max-bytes", defaultMaxBytes, "largest request body accepted")
+	origins := flags.String("origins", "", "comma separated browser origins allowed to open streams, besides the gateway's own")
+	refresh := flags.Duration("refresh", 0, "how often to refresh the OAuth tokens, half their lifetime by default")
+	exportMetrics := flags.Bool("metrics", false, "serve Prometheus metrics at /metrics")
+	verbose := flags.Bool("v", false, "log API requests and responses, with credentials redacted")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	keys, err := loadKeys(*keysPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "attspeech-gateway:", err)
+		return 1
+	}
+	id, secret := os.Getenv("ATT_APP_KEY"), os.Getenv("ATT_APP_SECRET")
+	if id == "" || secret == "" {
+		fmt.Fprintln(stderr, "attspeech-gateway: credentials must be provided via ATT_APP_KEY and ATT_APP_SECRET")
+		return 1
+	}
+	client := attspeech.New(id, secret, os.Getenv("ATT_API_BASE"))
+	if *verbose {
+		client.Logging = &attspeech.LogOptions{
+			Logger:       slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			RequestLevel: slog.LevelDebug,
+			ErrorLevel:   slog.LevelWarn,
+		}
+	}
+	srv := newServer(client, keys, *maxBytes)
+	if *origins != "" {
+		srv.origins = strings.Split(*origins, ",")
+	}
+	if *exportMetrics {
+		exporter := metrics.NewPrometheus()
+		client.Metrics = exporter
+		srv.mux.Handle("GET /metrics", exporter)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := client.SetAuthTokensContext(ctx); err != nil {
+		fmt.Fprintln(stderr, "attspeech-gateway: could not fetch tokens:", err)
+		return 1
+	}
+	go srv.refreshTokens(ctx, *refresh, logger)
+
+	httpServer := &http.Server{Addr: *addr, Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+	logger.Info("listening", "addr", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(stderr, "attspeech-gateway:", err)
+		return 1
+	}
+	return 0
+}
+
+/*
+loadKeys reads the API keys from the file at path, if one is given, and
+the ATTSPEECH_GATEWAY_KEYS environment variable. Blank lines and lines
+starting with '#' are ignored.
+*/
+func loadKeys(path string) ([]string, error) {
+	keys := []string{}
+	for _, key := range strings.Split(os.Getenv("ATTSPEECH_GATEWAY_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key := strings.TrimSpace(scanner.Text())
+			if key != "" && !strings.HasPrefix(key, "#") {
+				keys = append(keys, key)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("at least one API key must be provided with -keys or ATTSPEECH_GATEWAY_KEYS")
+	}
+	return keys, nil
+}
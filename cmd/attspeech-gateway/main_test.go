@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeys(t *testing.T) {
+	Convey("Loading API keys", t, func() {
+		t.Setenv("ATTSPEECH_GATEWAY_KEYS", "")
+		path := filepath.Join(t.TempDir(), "keys")
+		os.WriteFile(path, []byte("# billing\nabc\n\n  def  \n"), 0600)
+
+		Convey("Should read one key per line, skipping blanks and comments", func() {
+			keys, err := loadKeys(path)
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"abc", "def"})
+		})
+		Convey("Should add the keys from the environment", func() {
+			t.Setenv("ATTSPEECH_GATEWAY_KEYS", "ghi, jkl")
+			keys, err := loadKeys(path)
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"ghi", "jkl", "abc", "def"})
+		})
+		Convey("Should require at least one key", func() {
+			_, err := loadKeys("")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRun(t *testing.T) {
+	Convey("Running the gateway", t, func() {
+		stderr := &bytes.Buffer{}
+
+		Convey("Should fail without API keys", func() {
+			t.Setenv("ATTSPEECH_GATEWAY_KEYS", "")
+			So(run([]string{}, stderr), ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "at least one API key must be provided")
+		})
+		Convey("Should fail without credentials", func() {
+			t.Setenv("ATTSPEECH_GATEWAY_KEYS", "abc")
+			t.Setenv("ATT_APP_KEY", "")
+			So(run([]string{}, stderr), ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "credentials must be provided")
+		})
+		Convey("Should reject unknown flags", func() {
+			So(run([]string{"-foo"}, stderr), ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRefresh is how often tokens are refreshed when they do not say when they expire
+const defaultRefresh = time.Hour
+
+// server exposes a client to internal callers over HTTP
+type server struct {
+	client   *attspeech.Client
+	keys     [][]byte
+	maxBytes int64
+	// origins are the browser origins, besides the gateway's own, allowed to open streams
+	origins []string
+	mux     *http.ServeMux
+
+	// mu guards client.Tokens, which are replaced when they are refreshed
+	mu sync.RWMutex
+}
+
+// ttsRequest is the JSON body of a POST /tts
+type ttsRequest struct {
+	Text     string `json:"text"`
+	Voice    string `json:"voice"`
+	Tempo    string `json:"tempo"`
+	Volume   string `json:"volume"`
+	Language string `json:"language"`
+	Accept   string `json:"accept"`
+}
+
+// errorResponse is the JSON body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+	// MessageID and Status are set when the error came from the AT&T Speech API
+	MessageID string `json:"message_id,omitempty"`
+	Status    int    `json:"status,omitempty"`
+}
+
+// newServer creates a server accepting keys that limits request bodies to maxBytes
+func newServer(client *attspeech.Client, keys []string, maxBytes int64) *server {
+	srv := &server{client: client, maxBytes: maxBytes, mux: http.NewServeMux()}
+	for _, key := range keys {
+		srv.keys = append(srv.keys, []byte(key))
+	}
+	srv.mux.HandleFunc("POST /stt", srv.authorized(srv.handleSTT))
+	srv.mux.HandleFunc("POST /sttc", srv.authorized(srv.handleSTTC))
+	srv.mux.HandleFunc("POST /tts", srv.authorized(srv.handleTTS))
+	srv.mux.HandleFunc("GET /stream", srv.authorized(srv.handleStream))
+	srv.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	return srv
+}
+
+// ServeHTTP routes a request to its handler
+func (srv *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	srv.mux.ServeHTTP(w, req)
+}
+
+/*
+authorized rejects requests without a known API key and limits the size of
+their bodies. Browsers cannot set headers on WebSockets, so the key may
+also be given as the key query parameter.
+*/
+func (srv *server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("X-API-Key")
+		if key == "" {
+			key = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		}
+		if key == "" {
+			key = req.URL.Query().Get("key")
+		}
+		if !srv.validKey(key) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("a valid API key must be provided"))
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, srv.maxBytes)
+		handler(w, req)
+	}
+}
+
+// validKey compares key to every accepted key in constant time
+func (srv *server) validKey(key string) bool {
+	valid := 0
+	for _, accepted := range srv.keys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), accepted)
+	}
+	return key != "" && valid == 1
+}
+
+// newAPIRequest creates a request holding the current token for resource
+func (srv *server) newAPIRequest(resource string) *attspeech.APIRequest {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return srv.client.NewAPIRequest(resource)
+}
+
+// handleSTT recognizes the audio in the request body
+func (srv *server) handleSTT(w http.ResponseWriter, req *http.Request) {
+	data := &bytes.Buffer{}
+	if _, err := io.Copy(data, req.Body); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	query := req.URL.Query()
+	apiRequest := srv.newAPIRequest(srv.client.STTResource)
+	apiRequest.Data = data
+	apiRequest.ContentType = audioContentType(req.Header.Get("Content-Type"))
+	apiRequest.XSpeechContext = query.Get("context")
+	apiRequest.XSpeechSubContext = query.Get("subcontext")
+	apiRequest.ContentLanguage = query.Get("language")
+	recognition, err := srv.client.SpeechToTextContext(req.Context(), apiRequest)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, recognition)
+}
+
+/*
+handleSTTC recognizes the "audio" part of a multipart form using the
+"grammar" part, and the "dictionary" part when there is one. The grammar
+and dictionary may be files or plain form values.
+*/
+func (srv *server) handleSTTC(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseMultipartForm(srv.maxBytes); err != nil {
+		writeError(w, errorStatus(err), errors.New("invalid multipart form: "+err.Error()))
+		return
+	}
+	defer req.MultipartForm.RemoveAll()
+	file, header, err := req.FormFile("audio")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("an audio file must be provided in the audio part"))
+		return
+	}
+	defer file.Close()
+	data := &bytes.Buffer{}
+	if _, err := io.Copy(data, file); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	grammar, err := formText(req.MultipartForm, "grammar")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if grammar == "" {
+		writeError(w, http.StatusBadRequest, errors.New("a grammar must be provided in the grammar part"))
+		return
+	}
+	dictionary, err := formText(req.MultipartForm, "dictionary")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	apiRequest := srv.newAPIRequest(srv.client.STTCResource)
+	apiRequest.Data = data
+	apiRequest.Filename = header.Filename
+	apiRequest.ContentType = audioContentType(header.Header.Get("Content-Type"))
+	apiRequest.XSpeechContext = req.FormValue("context")
+	recognition, err := srv.client.SpeechToTextCustomContext(req.Context(), apiRequest, grammar, dictionary)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, recognition)
+}
+
+// handleTTS synthesizes the text of a JSON request, responding with the audio
+func (srv *server) handleTTS(w http.ResponseWriter, req *http.Request) {
+	body := &ttsRequest{}
+	if err := json.NewDecoder(req.Body).Decode(body); err != nil {
+		writeError(w, errorStatus(err), errors.New("invalid JSON: "+err.Error()))
+		return
+	}
+	apiRequest := srv.newAPIRequest(srv.client.TTSResource)
+	apiRequest.Text = body.Text
+	apiRequest.VoiceName = body.Voice
+	apiRequest.Tempo = body.Tempo
+	apiRequest.Volume = body.Volume
+	apiRequest.ContentLanguage = body.Language
+	apiRequest.Accept = body.Accept
+	if apiRequest.Accept == "" {
+		apiRequest.Accept = attspeech.AcceptWAV
+	}
+	speech, err := srv.client.Synthesize(req.Context(), apiRequest)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	contentType := speech.ContentType
+	if contentType == "" {
+		contentType = apiRequest.Accept
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(speech.Data)
+}
+
+// refreshTokens replaces the client's tokens before they expire, until ctx is done
+func (srv *server) refreshTokens(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	for {
+		wait := interval
+		if wait <= 0 {
+			wait = srv.tokenLifetime() / 2
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// Fetch without the lock so requests keep using the old tokens meanwhile
+		tokens, err := srv.client.FetchAuthTokens(ctx)
+		if err != nil {
+			// The old tokens are kept, so requests continue until they expire. The error has the client secret redacted.
+			logger.Warn("could not refresh tokens", "error", err)
+			continue
+		}
+		srv.mu.Lock()
+		srv.client.Tokens = tokens
+		srv.mu.Unlock()
+	}
+}
+
+// tokenLifetime returns the shortest lifetime of the client's tokens, or defaultRefresh when unknown
+func (srv *server) tokenLifetime() time.Duration {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	lifetime := time.Duration(0)
+	for _, token := range srv.client.Tokens {
+		expiresIn := time.Duration(token.ExpiresIn) * time.Second
+		if expiresIn > 0 && (lifetime == 0 || expiresIn < lifetime) {
+			lifetime = expiresIn
+		}
+	}
+	if lifetime == 0 {
+		return defaultRefresh * 2
+	}
+	return lifetime
+}
+
+// formText returns a form value, or the contents of a file uploaded in its place
+func formText(form *multipart.Form, name string) (string, error) {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0], nil
+	}
+	files := form.File[name]
+	if len(files) == 0 {
+		return "", nil
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return string(data), err
+}
+
+// audioContentType drops generic content types, so the client detects the type from the audio
+func audioContentType(contentType string) string {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "", "application/octet-stream":
+		return ""
+	}
+	return contentType
+}
+
+/*
+errorStatus maps an error to the status the gateway responds with: errors
+from the API or reaching it are bad gateway errors, oversized bodies are
+too large, and anything the client rejected before sending is the
+caller's fault
+*/
+func errorStatus(err error) int {
+	var maxBytesError *http.MaxBytesError
+	var serviceError *attspeech.ServiceError
+	var urlError *url.Error
+	switch {
+	case errors.As(err, &maxBytesError):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &serviceError), errors.As(err, &urlError):
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadRequest
+}
+
+// writeJSON responds with value as JSON
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError responds with err as JSON
+func writeError(w http.ResponseWriter, status int, err error) {
+	response := errorResponse{Error: err.Error()}
+	var serviceError *attspeech.ServiceError
+	if errors.As(err, &serviceError) {
+		response.MessageID = serviceError.MessageID
+		response.Status = serviceError.StatusCode
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
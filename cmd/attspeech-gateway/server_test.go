@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer logs can be written to while a test reads them
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.String()
+}
+
+func TestServer(t *testing.T) {
+	Convey("Serving the gateway", t, func() {
+		var tokens int32
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case strings.Contains(req.RequestURI, "/oauth/access_token"):
+				atomic.AddInt32(&tokens, 1)
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":2,"refresh_token":"456"}`))
+			case req.Header.Get("X-Speechcontext") == "raise/error":
+				w.WriteHeader(400)
+				w.Write([]byte(`{"RequestError":{"ServiceException":{"MessageId":"SVC0002","Text":"Invalid input value for message part %1","Variables":"X-SpeechContext"}}}`))
+			case strings.Contains(req.RequestURI, "/speech/v3/textToSpeech"):
+				wav, _ := (&audio.Buffer{SampleRate: 16000, Channels: 1, Samples: make([]int16, 1600)}).WAV(audio.PCM)
+				w.Write(wav)
+			default:
+				w.Write([]byte(`{"Recognition":{"Status":"OK","ResponseId":"abc","NBest":[{"ResultText":"hello world","Confidence":0.9}]}}`))
+			}
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+		srv := httptest.NewServer(newServer(client, []string{"secret-key", "other-key"}, 64<<10))
+		defer srv.Close()
+		wav, _ := (&audio.Buffer{SampleRate: 8000, Channels: 1, Samples: make([]int16, 800)}).WAV(audio.PCM)
+
+		post := func(path string, contentType string, body io.Reader, key string) *http.Response {
+			req, _ := http.NewRequest("POST", srv.URL+path, body)
+			req.Header.Set("Content-Type", contentType)
+			if key != "" {
+				req.Header.Set("X-API-Key", key)
+			}
+			res, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			return res
+		}
+		decode := func(res *http.Response, value interface{}) {
+			defer res.Body.Close()
+			So(json.NewDecoder(res.Body).Decode(value), ShouldBeNil)
+		}
+
+		Convey("Should reject requests without a valid API key", func() {
+			res := post("/stt", "audio/wav", bytes.NewReader(wav), "")
+			So(res.StatusCode, ShouldEqual, 401)
+			res = post("/stt", "audio/wav", bytes.NewReader(wav), "secret-kez")
+			So(res.StatusCode, ShouldEqual, 401)
+			response := &errorResponse{}
+			decode(res, response)
+			So(response.Error, ShouldEqual, "a valid API key must be provided")
+		})
+		Convey("Should accept keys as bearer tokens", func() {
+			req, _ := http.NewRequest("POST", srv.URL+"/stt", bytes.NewReader(wav))
+			req.Header.Set("Authorization", "Bearer other-key")
+			res, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			So(res.StatusCode, ShouldEqual, 200)
+		})
+		Convey("Should recognize audio and respond with the Recognition", func() {
+			res := post("/stt?context=Generic", "application/octet-stream", bytes.NewReader(wav), "secret-key")
+			So(res.StatusCode, ShouldEqual, 200)
+			So(res.Header.Get("Content-Type"), ShouldEqual, "application/json")
+			recognition := &attspeech.Recognition{}
+			decode(res, recognition)
+			So(recognition.Recognition.ResponseID, ShouldEqual, "abc")
+			So(recognition.Recognition.NBest[0].ResultText, ShouldEqual, "hello world")
+		})
+		Convey("Should report API errors as bad gateway errors with their MessageId", func() {
+			res := post("/stt?context=raise/error", "audio/wav", bytes.NewReader(wav), "secret-key")
+			So(res.StatusCode, ShouldEqual, 502)
+			response := &errorResponse{}
+			decode(res, response)
+			So(response.MessageID, ShouldEqual, "SVC0002")
+			So(response.Status, ShouldEqual, 400)
+		})
+		Convey("Should report requests the client rejects as bad requests", func() {
+			res := post("/stt", "", strings.NewReader("foobar"), "secret-key")
+			So(res.StatusCode, ShouldEqual, 400)
+			response := &errorResponse{}
+			decode(res, response)
+			So(response.Error, ShouldEqual, "a content type must be provided")
+		})
+		Convey("Should reject bodies over the size limit", func() {
+			res := post("/stt", "audio/wav", bytes.NewReader(make([]byte, 65<<10)), "secret-key")
+			So(res.StatusCode, ShouldEqual, 413)
+		})
+		Convey("Should recognize audio with a grammar from a multipart form", func() {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="audio"; filename="test.wav"`)
+			header.Set("Content-Type", "audio/wav")
+			part, _ := writer.CreatePart(header)
+			part.Write(wav)
+			grammar, _ := writer.CreateFormFile("grammar", "grammar.srgs")
+			grammar.Write([]byte("<grammar/>"))
+			writer.WriteField("context", "GrammarList")
+			writer.Close()
+			res := post("/sttc", writer.FormDataContentType(), body, "secret-key")
+			So(res.StatusCode, ShouldEqual, 200)
+			recognition := &attspeech.Recognition{}
+			decode(res, recognition)
+			So(recognition.Recognition.Status, ShouldEqual, "OK")
+		})
+		Convey("Should require a grammar for custom recognition", func() {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("audio", "test.wav")
+			part.Write(wav)
+			writer.Close()
+			res := post("/sttc", writer.FormDataContentType(), body, "secret-key")
+			So(res.StatusCode, ShouldEqual, 400)
+			response := &errorResponse{}
+			decode(res, response)
+			So(response.Error, ShouldEqual, "a grammar must be provided in the grammar part")
+		})
+		Convey("Should synthesize text and respond with the audio", func() {
+			res := post("/tts", "application/json", strings.NewReader(`{"text":"hello","voice":"crystal"}`), "secret-key")
+			So(res.StatusCode, ShouldEqual, 200)
+			So(res.Header.Get("Content-Type"), ShouldEqual, audio.ContentTypeWAV)
+			data, _ := io.ReadAll(res.Body)
+			So(len(data), ShouldEqual, 44+3200)
+		})
+		Convey("Should reject unknown voices", func() {
+			res := post("/tts", "application/json", strings.NewReader(`{"text":"hello","voice":"crystl"}`), "secret-key")
+			So(res.StatusCode, ShouldEqual, 400)
+		})
+		Convey("Should refresh the tokens before they expire", func() {
+			srv := newServer(client, []string{"secret-key"}, 1024)
+			So(srv.tokenLifetime(), ShouldEqual, 2*time.Second)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go srv.refreshTokens(ctx, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			time.Sleep(50 * time.Millisecond)
+			So(atomic.LoadInt32(&tokens), ShouldBeGreaterThan, 3)
+		})
+		Convey("Should log failed refreshes without the client secret", func() {
+			unreachable := httptest.NewServer(http.NotFoundHandler())
+			unreachable.Close()
+			logs := &syncBuffer{}
+			srv := newServer(attspeech.New("foo", "s3cret", unreachable.URL), []string{"secret-key"}, 1024)
+			ctx, cancel := context.WithCancel(context.Background())
+			go srv.refreshTokens(ctx, time.Millisecond, slog.New(slog.NewTextHandler(logs, nil)))
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+			So(logs.String(), ShouldContainSubstring, "could not refresh tokens")
+			So(logs.String(), ShouldContainSubstring, "client_secret=[REDACTED]")
+			So(logs.String(), ShouldNotContainSubstring, "s3cret")
+		})
+		Convey("Should keep serving requests while tokens are refreshed", func() {
+			release := make(chan struct{})
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				<-release
+				w.Write([]byte(`{"access_token":"789","token_type":"bearer","expires_in":2,"refresh_token":"456"}`))
+			}))
+			defer slow.Close()
+			defer close(release)
+			slowClient := attspeech.New("foo", "bar", slow.URL)
+			slowClient.Tokens = client.Tokens
+			srv := newServer(slowClient, []string{"secret-key"}, 1024)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go srv.refreshTokens(ctx, time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			time.Sleep(20 * time.Millisecond)
+
+			done := make(chan *attspeech.APIRequest)
+			go func() { done <- srv.newAPIRequest(slowClient.TTSResource) }()
+			select {
+			case apiRequest := <-done:
+				So(apiRequest.Authorization, ShouldEqual, "Bearer 123")
+			case <-time.After(time.Second):
+				So("the request waited for the refresh", ShouldBeEmpty)
+			}
+		})
+		Convey("Should report its health without a key", func() {
+			res, err := http.Get(srv.URL + "/healthz")
+			So(err, ShouldBeNil)
+			So(res.StatusCode, ShouldEqual, 200)
+		})
+	})
+}
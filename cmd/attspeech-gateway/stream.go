@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/jsgoecke/attspeech"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// startTimeout is how long a client has to send the start message after connecting
+	startTimeout = 10 * time.Second
+	// idleTimeout is how long a stream may go without a message before it is abandoned
+	idleTimeout = 30 * time.Second
+	// writeTimeout is how long sending a message to the client may take
+	writeTimeout = 10 * time.Second
+	// statusInterval is how often interim status is sent while audio is streamed
+	statusInterval = 500 * time.Millisecond
+)
+
+/*
+startMessage is the JSON text message that begins a stream. Format is the
+content type of the audio frames that follow, and a Grammar selects
+custom recognition.
+*/
+type startMessage struct {
+	Type       string `json:"type"`
+	Format     string `json:"format"`
+	Context    string `json:"context"`
+	SubContext string `json:"subcontext"`
+	Language   string `json:"language"`
+	Grammar    string `json:"grammar"`
+	Dictionary string `json:"dictionary"`
+}
+
+// streamMessage is a JSON text message sent to the client
+type streamMessage struct {
+	// Type is started, status, result or error
+	Type string `json:"type"`
+	// State is streaming while audio is received and recognizing once it has ended
+	State       string                 `json:"state,omitempty"`
+	Bytes       int64                  `json:"bytes,omitempty"`
+	Recognition *attspeech.Recognition `json:"recognition,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	MessageID   string                 `json:"message_id,omitempty"`
+}
+
+// streamResult is the outcome of the recognition running alongside a stream
+type streamResult struct {
+	recognition *attspeech.Recognition
+	err         error
+}
+
+/*
+handleStream recognizes audio streamed over a WebSocket. The client sends
+a start message, then the audio as binary messages, then {"type": "stop"}.
+The audio is uploaded to the API as it arrives; the client is sent
+status messages while it streams and finally the Recognition or an error.
+
+	→ {"type": "start", "format": "audio/amr", "context": "Generic"}
+	→ binary audio frames
+	← {"type": "status", "state": "streaming", "bytes": 3200}
+	→ {"type": "stop"}
+	← {"type": "status", "state": "recognizing", "bytes": 6400}
+	← {"type": "result", "recognition": {"Recognition": {...}}}
+*/
+func (srv *server) handleStream(w http.ResponseWriter, req *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: srv.checkOrigin}
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(srv.maxBytes)
+
+	conn.SetReadDeadline(time.Now().Add(startTimeout))
+	start := &startMessage{}
+	if err := conn.ReadJSON(start); err != nil || start.Type != "start" {
+		sendError(conn, errors.New("the first message must be a JSON start message"))
+		return
+	}
+	if start.Format == "" {
+		sendError(conn, errors.New("the audio format must be given in the start message"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	reader, writer := io.Pipe()
+	results := make(chan streamResult, 1)
+	go func() {
+		recognition, err := srv.recognizeStream(ctx, start, reader)
+		// Unblock any frame still being written once the upload has ended
+		reader.CloseWithError(io.ErrClosedPipe)
+		results <- streamResult{recognition, err}
+	}()
+	send(conn, &streamMessage{Type: "started"})
+
+	var received int64
+	lastStatus := time.Now()
+	for streaming := true; streaming; {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			// The client has gone, so there is no one to send the result to
+			writer.CloseWithError(err)
+			return
+		}
+		switch kind {
+		case websocket.BinaryMessage:
+			received += int64(len(data))
+			if received > srv.maxBytes {
+				writer.CloseWithError(errors.New("stream too large"))
+				cancel()
+				sendError(conn, errors.New("the audio exceeds the limit of "+strconv.FormatInt(srv.maxBytes, 10)+" bytes"))
+				return
+			}
+			if _, err := writer.Write(data); err != nil {
+				// The upload ended early, the result has the reason
+				streaming = false
+				break
+			}
+			if time.Since(lastStatus) >= statusInterval {
+				send(conn, &streamMessage{Type: "status", State: "streaming", Bytes: received})
+				lastStatus = time.Now()
+			}
+		case websocket.TextMessage:
+			message := &startMessage{}
+			if json.Unmarshal(data, message) == nil && message.Type == "stop" {
+				writer.Close()
+				streaming = false
+			}
+		}
+	}
+
+	send(conn, &streamMessage{Type: "status", State: "recognizing", Bytes: received})
+	result := <-results
+	if result.err != nil {
+		sendError(conn, result.err)
+		return
+	}
+	send(conn, &streamMessage{Type: "result", Recognition: result.recognition})
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeTimeout))
+}
+
+// recognizeStream uploads the audio read from reader with the parameters of the start message
+func (srv *server) recognizeStream(ctx context.Context, start *startMessage, reader io.Reader) (*attspeech.Recognition, error) {
+	if start.Grammar != "" {
+		apiRequest := srv.newAPIRequest(srv.client.STTCResource)
+		apiRequest.ContentType = start.Format
+		apiRequest.XSpeechContext = start.Context
+		return srv.client.SpeechToTextCustomStream(ctx, apiRequest, reader, start.Grammar, start.Dictionary)
+	}
+	apiRequest := srv.newAPIRequest(srv.client.STTResource)
+	apiRequest.ContentType = start.Format
+	apiRequest.XSpeechContext = start.Context
+	apiRequest.XSpeechSubContext = start.SubContext
+	apiRequest.ContentLanguage = start.Language
+	return srv.client.SpeechToTextStream(ctx, apiRequest, reader)
+}
+
+/*
+checkOrigin allows browsers on the origins given with -origins to connect,
+or only those on the gateway's own origin when none were given
+*/
+func (srv *server) checkOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range srv.origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+// send writes a message to the client
+func send(conn *websocket.Conn, message *streamMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteJSON(message)
+}
+
+// sendError writes an error message to the client, with the MessageId of errors from the API
+func sendError(conn *websocket.Conn, err error) error {
+	message := &streamMessage{Type: "error", Error: err.Error()}
+	var serviceError *attspeech.ServiceError
+	if errors.As(err, &serviceError) {
+		message.MessageID = serviceError.MessageID
+	}
+	return send(conn, message)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/jsgoecke/attspeech"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	Convey("Streaming audio over a WebSocket", t, func() {
+		uploads := make(chan string, 1)
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, "/oauth/access_token") {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			uploads <- req.URL.Path + " " + req.Header.Get("Content-Type") + " " + string(body)
+			if req.Header.Get("X-Speechcontext") == "raise/error" {
+				w.WriteHeader(400)
+				w.Write([]byte(`{"RequestError":{"ServiceException":{"MessageId":"SVC0002","Text":"Invalid input","Variables":""}}}`))
+				return
+			}
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"hello world"}]}}`))
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+		srv := httptest.NewServer(newServer(client, []string{"secret-key"}, 1024))
+		defer srv.Close()
+		endpoint := "ws" + strings.TrimPrefix(srv.URL, "http") + "/stream?key=secret-key"
+		dial := func(url string) *websocket.Conn {
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			So(err, ShouldBeNil)
+			return conn
+		}
+		// receive reads messages until one of the given type arrives
+		receive := func(conn *websocket.Conn, kind string) *streamMessage {
+			for {
+				message := &streamMessage{}
+				So(conn.ReadJSON(message), ShouldBeNil)
+				if message.Type == kind || message.Type == "error" {
+					return message
+				}
+			}
+		}
+
+		Convey("Should upload the frames and send back the Recognition", func() {
+			conn := dial(endpoint)
+			defer conn.Close()
+			conn.WriteJSON(map[string]string{"type": "start", "format": "audio/amr", "context": "Generic"})
+			So(receive(conn, "started").Type, ShouldEqual, "started")
+			conn.WriteMessage(websocket.BinaryMessage, []byte("#!AMR\n"))
+			conn.WriteMessage(websocket.BinaryMessage, []byte("frames"))
+			conn.WriteJSON(map[string]string{"type": "stop"})
+			status := receive(conn, "status")
+			So(status.State, ShouldEqual, "recognizing")
+			So(status.Bytes, ShouldEqual, 12)
+			result := receive(conn, "result")
+			So(result.Recognition.Recognition.NBest[0].ResultText, ShouldEqual, "hello world")
+			So(<-uploads, ShouldEqual, "/speech/v3/speechToText audio/amr #!AMR\nframes")
+			_, _, err := conn.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseNormalClosure), ShouldBeTrue)
+		})
+		Convey("Should use custom recognition when a grammar is given", func() {
+			conn := dial(endpoint)
+			defer conn.Close()
+			conn.WriteJSON(map[string]string{"type": "start", "format": "audio/amr", "grammar": "<grammar/>"})
+			conn.WriteMessage(websocket.BinaryMessage, []byte("frames"))
+			conn.WriteJSON(map[string]string{"type": "stop"})
+			So(receive(conn, "result").Type, ShouldEqual, "result")
+			upload := <-uploads
+			So(upload, ShouldStartWith, "/speech/v3/speechToTextCustom multipart/x-srgs-audio")
+			So(upload, ShouldContainSubstring, "<grammar/>")
+		})
+		Convey("Should send API errors with their MessageId", func() {
+			conn := dial(endpoint)
+			defer conn.Close()
+			conn.WriteJSON(map[string]string{"type": "start", "format": "audio/amr", "context": "raise/error"})
+			conn.WriteMessage(websocket.BinaryMessage, []byte("frames"))
+			conn.WriteJSON(map[string]string{"type": "stop"})
+			message := receive(conn, "result")
+			So(message.Type, ShouldEqual, "error")
+			So(message.MessageID, ShouldEqual, "SVC0002")
+		})
+		Convey("Should require a start message with a format", func() {
+			conn := dial(endpoint)
+			defer conn.Close()
+			conn.WriteJSON(map[string]string{"type": "start"})
+			message := receive(conn, "started")
+			So(message.Error, ShouldEqual, "the audio format must be given in the start message")
+		})
+		Convey("Should stop streams over the size limit", func() {
+			conn := dial(endpoint)
+			defer conn.Close()
+			conn.WriteJSON(map[string]string{"type": "start", "format": "audio/amr"})
+			conn.WriteMessage(websocket.BinaryMessage, make([]byte, 800))
+			conn.WriteMessage(websocket.BinaryMessage, make([]byte, 800))
+			message := receive(conn, "result")
+			So(message.Error, ShouldEqual, "the audio exceeds the limit of 1024 bytes")
+		})
+		Convey("Should reject connections without a key", func() {
+			_, res, err := websocket.DefaultDialer.Dial(strings.TrimSuffix(endpoint, "?key=secret-key"), nil)
+			So(err, ShouldNotBeNil)
+			So(res.StatusCode, ShouldEqual, 401)
+		})
+		Convey("Should reject connections from other origins", func() {
+			header := http.Header{"Origin": []string{"https://example.com"}}
+			_, res, err := websocket.DefaultDialer.Dial(endpoint, header)
+			So(err, ShouldNotBeNil)
+			So(res.StatusCode, ShouldEqual, 403)
+		})
+	})
+}
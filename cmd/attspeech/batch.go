@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/batch"
+	"github.com/jsgoecke/attspeech/cache"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+)
+
+// runBatch implements the batch subcommand
+func runBatch(env *environment, args []string) error {
+	flags := env.newFlagSet("batch", "[-manifest file | dir]")
+	workers := flags.Int("workers", batch.DefaultWorkers, "number of concurrent recognitions")
+	checkpoint := flags.String("checkpoint", "", "file used to record progress and resume an interrupted run")
+	outputDir := flags.String("out", "", "directory to write a JSON result per file and summary.json to")
+	manifest := flags.String("manifest", "", "file listing one audio path per line, '-' for stdin")
+	speechContext := flags.String("context", "", "speech context, e.g. Generic or Voicemail")
+	transcode := flags.Bool("transcode", false, "convert WAV audio the API does not accept to a supported format")
+	cacheDir := flags.String("cache", "", "directory to cache recognitions in, so reprocessed audio is not sent again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	jobs, err := env.batchJobs(*manifest, flags.Args())
+	if err != nil {
+		return err
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+	client.TranscodeAudio = *transcode
+	if *cacheDir != "" {
+		disk, err := cache.NewDisk(*cacheDir, 0, 0)
+		if err != nil {
+			return err
+		}
+		client.RecognitionCache = disk
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	options := &batch.Options{
+		Workers:    *workers,
+		Checkpoint: *checkpoint,
+		OutputDir:  *outputDir,
+		Prepare: func(apiRequest *attspeech.APIRequest) {
+			apiRequest.XSpeechContext = *speechContext
+		},
+	}
+	if env.format != formatJSON {
+		options.OnResult = func(result batch.Result) {
+			env.writeOne(result, batchResultText(result))
+		}
+	}
+	summary, err := batch.Run(ctx, client, jobs, options)
+	if summary != nil {
+		if env.format == formatJSON {
+			env.writeOne(summary, nil)
+		} else {
+			fmt.Fprintf(env.stderr, "%d succeeded, %d failed, %d skipped of %d\n", summary.Succeeded, summary.Failed, summary.Skipped, summary.Total)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return errors.New(strconv.Itoa(summary.Failed) + " files failed")
+	}
+	return nil
+}
+
+// batchJobs builds the job list from a manifest or a directory argument
+func (env *environment) batchJobs(manifest string, args []string) ([]batch.Job, error) {
+	switch {
+	case manifest == "-":
+		return batch.Manifest(env.stdin)
+	case manifest != "":
+		file, err := os.Open(manifest)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return batch.Manifest(file)
+	case len(args) == 1:
+		return batch.Dir(args[0])
+	}
+	return nil, errors.New("either -manifest or a single directory must be provided")
+}
+
+// batchResultText renders a batch result as a single line
+func batchResultText(result batch.Result) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if result.Error != "" {
+			_, err := fmt.Fprintf(w, "%s: error: %s\n", result.Name, result.Error)
+			return err
+		}
+		fmt.Fprintf(w, "%s: ", result.Name)
+		return recognitionText(result.Recognition)(w)
+	}
+}
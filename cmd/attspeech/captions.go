@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/subtitle"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+)
+
+// runCaptions implements the captions subcommand
+func runCaptions(env *environment, args []string) error {
+	flags := env.newFlagSet("captions", "[file|-]")
+	captionType := flags.String("type", "", "srt or vtt (from the -o extension if empty, otherwise srt)")
+	maxLineLength := flags.Int("max-line", subtitle.DefaultMaxLineLength, "most characters on a caption line")
+	maxLines := flags.Int("max-lines", subtitle.DefaultMaxLines, "most lines in a caption")
+	maxDuration := flags.Duration("max-duration", subtitle.DefaultMaxCueDuration, "longest a caption stays on screen")
+	workers := flags.Int("workers", attspeech.DefaultSegmentWorkers, "number of segments recognized concurrently")
+	speechContext := flags.String("context", "", "speech context, e.g. Generic")
+	output := flags.String("o", "-", "file to write the captions to, '-' for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *captionType == "" {
+		*captionType = "srt"
+		if filepath.Ext(*output) == ".vtt" {
+			*captionType = "vtt"
+		}
+	}
+	write := map[string]func(io.Writer, []subtitle.Cue) error{
+		"srt": subtitle.WriteSRT,
+		"vtt": subtitle.WriteWebVTT,
+	}[*captionType]
+	if write == nil {
+		return errors.New("unknown caption type " + *captionType)
+	}
+
+	data, _, err := env.readAudio(flags.Args())
+	if err != nil {
+		return err
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	apiRequest := client.NewAPIRequest(client.STTResource)
+	apiRequest.Data = data
+	apiRequest.XSpeechContext = *speechContext
+	transcript, err := client.SpeechToTextLong(ctx, apiRequest, &attspeech.SegmentOptions{Workers: *workers})
+	if transcript == nil {
+		return err
+	}
+	cues := subtitle.Cues(transcript, &subtitle.Options{
+		MaxLineLength:  *maxLineLength,
+		MaxLines:       *maxLines,
+		MaxCueDuration: *maxDuration,
+	})
+
+	out := env.stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if writeErr := write(out, cues); writeErr != nil {
+		return writeErr
+	}
+	// Captions are still written for the segments that succeeded before reporting the failures
+	return err
+}
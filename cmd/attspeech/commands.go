@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	"github.com/jsgoecke/attspeech/cache"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// audioExtensions maps audio content types to the file extensions used to name uploads read from stdin
+var audioExtensions = map[string]string{
+	audio.ContentTypeWAV:   ".wav",
+	audio.ContentTypeAMR:   ".amr",
+	audio.ContentTypeAMRWB: ".awb",
+	audio.ContentTypeSpeex: ".spx",
+	audio.ContentTypeOpus:  ".opus",
+	audio.ContentTypeFLAC:  ".flac",
+	audio.ContentTypeMuLaw: ".au",
+}
+
+// newClient creates a client from the configured credentials and fetches its tokens
+func (env *environment) newClient() (*attspeech.Client, error) {
+	if err := env.config.validate(); err != nil {
+		return nil, err
+	}
+	client := attspeech.New(env.config.ID, env.config.Secret, env.config.APIBase)
+	if env.verbose {
+		client.Logging = &attspeech.LogOptions{
+			Logger:       slog.New(slog.NewTextHandler(env.stderr, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			RequestLevel: slog.LevelDebug,
+			ErrorLevel:   slog.LevelWarn,
+		}
+	}
+	if err := client.SetAuthTokens(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors to stderr
+func (env *environment) newFlagSet(name string, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: attspeech %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+/*
+readAudio reads the audio named by the first argument, or stdin when
+there is no argument or the argument is '-'
+*/
+func (env *environment) readAudio(args []string) (*bytes.Buffer, string, error) {
+	if len(args) > 1 {
+		return nil, "", errors.New("only one audio file may be provided")
+	}
+	data := &bytes.Buffer{}
+	if len(args) == 0 || args[0] == "-" {
+		if _, err := io.Copy(data, env.stdin); err != nil {
+			return nil, "", err
+		}
+		return data, "", nil
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(data, file); err != nil {
+		return nil, "", err
+	}
+	return data, filepath.Base(args[0]), nil
+}
+
+// recognitionText renders the best hypothesis of a recognition
+func recognitionText(recognition *attspeech.Recognition) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if len(recognition.Recognition.NBest) == 0 {
+			_, err := fmt.Fprintln(w, recognition.Recognition.Status)
+			return err
+		}
+		_, err := fmt.Fprintln(w, recognition.Recognition.NBest[0].ResultText)
+		return err
+	}
+}
+
+// runSTT implements the stt subcommand
+func runSTT(env *environment, args []string) error {
+	flags := env.newFlagSet("stt", "[file|-]")
+	contentType := flags.String("content-type", "", "audio content type (detected from the audio if empty)")
+	speechContext := flags.String("context", "", "speech context, e.g. Generic or BusinessSearch")
+	subContext := flags.String("subcontext", "", "speech sub context")
+	language := flags.String("language", "", "content language, e.g. en-US")
+	transcode := flags.Bool("transcode", false, "convert WAV audio the API does not accept to a supported format")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	data, _, err := env.readAudio(flags.Args())
+	if err != nil {
+		return err
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+	client.TranscodeAudio = *transcode
+
+	apiRequest := client.NewAPIRequest(client.STTResource)
+	apiRequest.Data = data
+	apiRequest.ContentType = *contentType
+	apiRequest.XSpeechContext = *speechContext
+	apiRequest.XSpeechSubContext = *subContext
+	apiRequest.ContentLanguage = *language
+	recognition, err := client.SpeechToText(apiRequest)
+	if err != nil {
+		return err
+	}
+	return env.writeOne(recognition, recognitionText(recognition))
+}
+
+// runSTTC implements the sttc subcommand
+func runSTTC(env *environment, args []string) error {
+	flags := env.newFlagSet("sttc", "-grammar file [file|-]")
+	contentType := flags.String("content-type", "", "audio content type (detected from the audio if empty)")
+	grammarPath := flags.String("grammar", "", "path to the SRGS grammar (required)")
+	dictionaryPath := flags.String("dictionary", "", "path to the PLS dictionary")
+	speechContext := flags.String("context", "", "speech context")
+	transcode := flags.Bool("transcode", false, "convert WAV audio the API does not accept to a supported format")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *grammarPath == "" {
+		return errors.New("a grammar must be provided with -grammar")
+	}
+
+	grammar, err := os.ReadFile(*grammarPath)
+	if err != nil {
+		return err
+	}
+	dictionary := []byte{}
+	if *dictionaryPath != "" {
+		dictionary, err = os.ReadFile(*dictionaryPath)
+		if err != nil {
+			return err
+		}
+	}
+	data, filename, err := env.readAudio(flags.Args())
+	if err != nil {
+		return err
+	}
+	if *contentType == "" {
+		*contentType = audio.Sniff(data.Bytes())
+	}
+	if filename == "" {
+		filename = "audio" + extensionFor(*contentType)
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+	client.TranscodeAudio = *transcode
+
+	apiRequest := client.NewAPIRequest(client.STTCResource)
+	apiRequest.Data = data
+	apiRequest.Filename = filename
+	apiRequest.ContentType = *contentType
+	apiRequest.XSpeechContext = *speechContext
+	recognition, err := client.SpeechToTextCustom(apiRequest, string(grammar), string(dictionary))
+	if err != nil {
+		return err
+	}
+	return env.writeOne(recognition, recognitionText(recognition))
+}
+
+// extensionFor returns the file extension matching an audio content type
+func extensionFor(contentType string) string {
+	if extension, ok := audioExtensions[contentType]; ok {
+		return extension
+	}
+	return ".wav"
+}
+
+// runTTS implements the tts subcommand
+func runTTS(env *environment, args []string) error {
+	flags := env.newFlagSet("tts", "[text...]")
+	accept := flags.String("accept", "audio/x-wav", "audio content type to return, e.g. audio/x-wav or audio/amr")
+	voice := flags.String("voice", "", "voice name, e.g. crystal or mike")
+	tempo := flags.String("tempo", "", "speaking tempo, from -18 (slowest) to 18 (fastest)")
+	volume := flags.String("volume", "", "speaking volume, from 0 to 500 percent of normal")
+	language := flags.String("language", "", "content language, e.g. en-US")
+	output := flags.String("o", "-", "file to write the audio to, '-' for stdout")
+	maxCharacters := flags.Int("max-chars", 0, "split longer text into chunks synthesized concurrently, 0 to send it whole")
+	silence := flags.Duration("silence", 0, "silence to insert between chunks")
+	cacheDir := flags.String("cache", "", "directory to cache synthesized audio in")
+	encoding := flags.String("encoding", "", "convert WAV audio to pcm, mulaw or alaw locally")
+	rate := flags.Int("rate", 8000, "sample rate to convert to with -encoding, 8000 or 16000")
+	raw := flags.Bool("raw", false, "write headerless samples rather than a WAV file with -encoding")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		data, err := io.ReadAll(env.stdin)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(string(data))
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+
+	if *cacheDir != "" {
+		disk, err := cache.NewDisk(*cacheDir, 0, 0)
+		if err != nil {
+			return err
+		}
+		client.TTSCache = disk
+	}
+	if *maxCharacters > 0 {
+		client.TextChunking = &attspeech.ChunkOptions{MaxCharacters: *maxCharacters, Silence: *silence}
+	}
+
+	apiRequest := client.NewAPIRequest(client.TTSResource)
+	apiRequest.Accept = *accept
+	apiRequest.Text = text
+	apiRequest.VoiceName = *voice
+	apiRequest.Tempo = *tempo
+	apiRequest.Volume = *volume
+	apiRequest.ContentLanguage = *language
+	speech, err := client.Synthesize(context.Background(), apiRequest)
+	if err != nil {
+		return err
+	}
+	data, contentType := speech.Data, speech.ContentType
+	if *encoding != "" {
+		data, contentType, err = convertSpeech(speech, *encoding, *rate, *raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *output == "-" {
+		_, err = env.stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	if env.format == formatText {
+		return nil
+	}
+	summary := map[string]interface{}{
+		"file":         *output,
+		"content_type": contentType,
+		"bytes":        len(data),
+	}
+	return env.writeOne(summary, nil)
+}
+
+// speechEncodings maps the -encoding names to audio encodings and the content types of their raw samples
+var speechEncodings = map[string]struct {
+	encoding    audio.Encoding
+	contentType string
+}{
+	"pcm":   {audio.PCM, "audio/L16"},
+	"mulaw": {audio.MuLaw, "audio/basic"},
+	"alaw":  {audio.ALaw, "audio/x-alaw-basic"},
+}
+
+// convertSpeech converts synthesized speech for the tts -encoding, -rate and -raw flags
+func convertSpeech(speech *attspeech.Speech, encoding string, rate int, raw bool) ([]byte, string, error) {
+	target, ok := speechEncodings[encoding]
+	if !ok {
+		return nil, "", errors.New("unknown encoding " + encoding + ", expected pcm, mulaw or alaw")
+	}
+	if rate != 8000 && rate != 16000 {
+		return nil, "", errors.New("the sample rate must be 8000 or 16000 Hz")
+	}
+	format := audio.Format{Encoding: target.encoding, SampleRate: rate, Channels: 1, BitsPerSample: 8}
+	if target.encoding == audio.PCM {
+		format.BitsPerSample = 16
+	}
+	if raw {
+		data, err := speech.Raw(format)
+		return data, target.contentType, err
+	}
+	data, err := speech.WAV(format)
+	return data, audio.ContentTypeWAV, err
+}
+
+// runToken implements the token subcommand
+func runToken(env *environment, args []string) error {
+	flags := env.newFlagSet("token", "")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+
+	tokens := []interface{}{}
+	for _, scope := range client.Scope {
+		tokens = append(tokens, struct {
+			Scope string `json:"scope"`
+			*attspeech.Token
+		}{scope, client.Tokens[scope]})
+	}
+	return env.writeMany(tokens, func(w io.Writer) error {
+		for _, scope := range client.Scope {
+			token := client.Tokens[scope]
+			_, err := fmt.Fprintf(w, "%-6s %s expires_in=%d refresh_token=%s\n", scope, token.AccessToken, token.ExpiresIn, token.RefreshToken)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
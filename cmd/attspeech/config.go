@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// config holds the credentials used to talk to the AT&T Speech API
+type config struct {
+	ID      string `json:"id"`
+	Secret  string `json:"secret"`
+	APIBase string `json:"api_base"`
+}
+
+// defaultConfigPath returns ~/.attspeech.json, or an empty string if there is no home directory
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".attspeech.json")
+}
+
+/*
+loadConfig reads the config file at path, if it exists, and then
+overrides its values with the ATT_APP_KEY, ATT_APP_SECRET and
+ATT_API_BASE environment variables
+*/
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, cfg); err != nil {
+				return nil, errors.New("could not parse config file " + path + ": " + err.Error())
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+	if id := os.Getenv("ATT_APP_KEY"); id != "" {
+		cfg.ID = id
+	}
+	if secret := os.Getenv("ATT_APP_SECRET"); secret != "" {
+		cfg.Secret = secret
+	}
+	if apiBase := os.Getenv("ATT_API_BASE"); apiBase != "" {
+		cfg.APIBase = apiBase
+	}
+	return cfg, nil
+}
+
+// validate ensures the credentials required to get tokens are present
+func (cfg *config) validate() error {
+	if cfg.ID == "" || cfg.Secret == "" {
+		return errors.New("credentials must be provided via ATT_APP_KEY and ATT_APP_SECRET or the config file")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/eval"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+)
+
+// runEval implements the eval subcommand
+func runEval(env *environment, args []string) error {
+	flags := env.newFlagSet("eval", "[-manifest file | dir]")
+	manifest := flags.String("manifest", "", "file listing an audio path, a tab and its reference transcript per line, '-' for stdin")
+	workers := flags.Int("workers", eval.DefaultWorkers, "number of concurrent recognitions")
+	grammarPath := flags.String("grammar", "", "path to an SRGS grammar to recognize with SpeechToTextCustom")
+	dictionaryPath := flags.String("dictionary", "", "path to a PLS dictionary sent with the grammar")
+	speechContext := flags.String("context", "", "speech context, e.g. Generic or Voicemail")
+	htmlPath := flags.String("html", "", "file to write the HTML report to")
+	jsonPath := flags.String("json", "", "file to write the JSON report to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := env.evalEntries(*manifest, flags.Args())
+	if err != nil {
+		return err
+	}
+	options := &eval.Options{
+		Workers: *workers,
+		Prepare: func(apiRequest *attspeech.APIRequest) {
+			apiRequest.XSpeechContext = *speechContext
+		},
+	}
+	if *grammarPath != "" {
+		grammar, err := os.ReadFile(*grammarPath)
+		if err != nil {
+			return err
+		}
+		options.Grammar = string(grammar)
+	}
+	if *dictionaryPath != "" {
+		if options.Grammar == "" {
+			return errors.New("a dictionary is only sent with a grammar, provide one with -grammar")
+		}
+		dictionary, err := os.ReadFile(*dictionaryPath)
+		if err != nil {
+			return err
+		}
+		options.Dictionary = string(dictionary)
+	}
+	client, err := env.newClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if env.format != formatJSON {
+		options.OnResult = func(result eval.Result) {
+			env.writeOne(result, evalResultText(result))
+		}
+	}
+	report, err := eval.Run(ctx, client, entries, options)
+	if err != nil {
+		return err
+	}
+	if *htmlPath != "" {
+		if err := writeReport(*htmlPath, report.WriteHTML); err != nil {
+			return err
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeReport(*jsonPath, report.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if env.format == formatJSON {
+		env.writeOne(report, nil)
+	} else {
+		fmt.Fprintf(env.stderr, "WER %.1f%% (%d substitutions, %d insertions, %d deletions in %d words), SER %.1f%% (%d of %d), %d failed\n",
+			report.WER*100, report.Total.Substitutions, report.Total.Insertions, report.Total.Deletions, report.Total.Words,
+			report.SER*100, report.SentenceErrors, report.Sentences, report.Failed)
+	}
+	if report.Failed > 0 {
+		return errors.New(strconv.Itoa(report.Failed) + " files failed")
+	}
+	return nil
+}
+
+// evalEntries builds the corpus from a manifest or a directory argument
+func (env *environment) evalEntries(manifest string, args []string) ([]eval.Entry, error) {
+	switch {
+	case manifest == "-":
+		return eval.Manifest(env.stdin)
+	case manifest != "":
+		file, err := os.Open(manifest)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return eval.Manifest(file)
+	case len(args) == 1:
+		return eval.Dir(args[0])
+	}
+	return nil, errors.New("either -manifest or a single directory must be provided")
+}
+
+// evalResultText renders an evaluation result as a single line
+func evalResultText(result eval.Result) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if result.Error != "" {
+			_, err := fmt.Fprintf(w, "%s: error: %s\n", result.Name, result.Error)
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s: WER %.1f%% (S%d I%d D%d) %q\n", result.Name, result.WER*100,
+			result.Counts.Substitutions, result.Counts.Insertions, result.Counts.Deletions, result.Hypothesis)
+		return err
+	}
+}
+
+// writeReport creates path and writes a report to it
+func writeReport(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
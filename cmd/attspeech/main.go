@@ -0,0 +1,127 @@
+/*
+attspeech is a command line client for the AT&T Speech API.
+
+	attspeech [-config file] [-format text|json|ndjson] [-v] <command> [flags] [args]
+
+Commands:
+
+	stt      convert an audio file (or stdin) to text
+	sttc     convert an audio file (or stdin) to text using a custom grammar
+	tts      convert text (or stdin) to an audio file (or stdout)
+	batch    transcribe a directory or manifest of audio files concurrently
+	captions transcribe long audio to SRT or WebVTT captions
+	eval     measure the word error rate of a corpus with reference transcripts
+	token    fetch and print the OAuth tokens for each scope
+	voices   list the known TTS voices
+
+Credentials are read from the ATT_APP_KEY and ATT_APP_SECRET environment
+variables, falling back to a JSON config file (~/.attspeech.json by default):
+
+	{"id": "<id>", "secret": "<secret>", "api_base": "https://api.att.com"}
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a single attspeech subcommand
+type command struct {
+	name  string
+	usage string
+	run   func(env *environment, args []string) error
+}
+
+// environment carries the global options shared by every subcommand
+type environment struct {
+	config *config
+	format string
+	// verbose logs the client's requests and responses to stderr
+	verbose bool
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+var commands = []*command{
+	{"stt", "convert an audio file (or stdin) to text", runSTT},
+	{"sttc", "convert an audio file (or stdin) to text using a custom grammar", runSTTC},
+	{"tts", "convert text (or stdin) to an audio file (or stdout)", runTTS},
+	{"batch", "transcribe a directory or manifest of audio files concurrently", runBatch},
+	{"captions", "transcribe long audio to SRT or WebVTT captions", runCaptions},
+	{"eval", "measure the word error rate of a corpus with reference transcripts", runEval},
+	{"token", "fetch and print the OAuth tokens for each scope", runToken},
+	{"voices", "list the known TTS voices", runVoices},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses the global flags and dispatches to the requested subcommand
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("attspeech", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", defaultConfigPath(), "path to the JSON config file")
+	format := flags.String("format", "text", "output format: text, json or ndjson")
+	verbose := flags.Bool("v", false, "log API requests and responses to stderr, with credentials redacted")
+	flags.Usage = func() { usage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		usage(flags, stderr)
+		return 2
+	}
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "attspeech: unknown format %q\n", *format)
+		return 2
+	}
+
+	name := flags.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(stderr, "attspeech:", err)
+			return 1
+		}
+		env := &environment{
+			config:  cfg,
+			format:  *format,
+			verbose: *verbose,
+			stdin:   stdin,
+			stdout:  stdout,
+			stderr:  stderr,
+		}
+		if err := cmd.run(env, flags.Args()[1:]); err != nil {
+			if err == flag.ErrHelp {
+				return 2
+			}
+			fmt.Fprintf(stderr, "attspeech %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(stderr, "attspeech: unknown command %q\n", name)
+	usage(flags, stderr)
+	return 2
+}
+
+// usage prints the global usage message
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "usage: attspeech [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "flags:")
+	flags.PrintDefaults()
+}
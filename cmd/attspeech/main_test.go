@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	Convey("Loading the config", t, func() {
+		path := filepath.Join(t.TempDir(), "attspeech.json")
+		os.WriteFile(path, []byte(`{"id": "foo", "secret": "bar", "api_base": "http://foobar.com"}`), 0600)
+
+		Convey("Should read the config file", func() {
+			cfg, err := loadConfig(path)
+			So(err, ShouldBeNil)
+			So(cfg.ID, ShouldEqual, "foo")
+			So(cfg.Secret, ShouldEqual, "bar")
+			So(cfg.APIBase, ShouldEqual, "http://foobar.com")
+		})
+		Convey("Should let the environment override the config file", func() {
+			t.Setenv("ATT_APP_KEY", "baz")
+			cfg, err := loadConfig(path)
+			So(err, ShouldBeNil)
+			So(cfg.ID, ShouldEqual, "baz")
+			So(cfg.Secret, ShouldEqual, "bar")
+		})
+		Convey("Should ignore a missing config file", func() {
+			cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+			So(err, ShouldBeNil)
+			So(cfg.validate(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRun(t *testing.T) {
+	Convey("Running subcommands", t, func() {
+		ts := serveHTTP()
+		defer ts.Close()
+		t.Setenv("ATT_APP_KEY", "foo")
+		t.Setenv("ATT_APP_SECRET", "bar")
+		t.Setenv("ATT_API_BASE", ts.URL)
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		config := filepath.Join(t.TempDir(), "missing.json")
+		wav, _ := os.ReadFile("../../test/test.wav")
+
+		Convey("stt should print the best hypothesis", func() {
+			code := run([]string{"-config", config, "stt", "-content-type", "audio/wav"}, bytes.NewReader(wav), stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldEqual, "hello world\n")
+		})
+		Convey("stt should detect the content type", func() {
+			code := run([]string{"-config", config, "stt"}, bytes.NewReader(wav), stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldEqual, "hello world\n")
+		})
+		Convey("stt should fail when the content type cannot be detected", func() {
+			code := run([]string{"-config", config, "stt"}, strings.NewReader("foobar"), stdout, stderr)
+			So(code, ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "a content type must be provided")
+		})
+		Convey("captions should write WebVTT for the recognized segments", func() {
+			code := run([]string{"-config", config, "captions", "-type", "vtt"}, bytes.NewReader(wav), stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldStartWith, "WEBVTT\n\n00:00:0")
+			So(stdout.String(), ShouldEndWith, " --> 00:00:11.560\nworld\n\n")
+		})
+		Convey("captions should reject unknown caption types", func() {
+			code := run([]string{"-config", config, "captions", "-type", "ass"}, bytes.NewReader(wav), stdout, stderr)
+			So(code, ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "unknown caption type ass")
+		})
+		Convey("tts should write the audio to stdout", func() {
+			code := run([]string{"-config", config, "tts", "hello"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldStartWith, "RIFF")
+			So(stdout.Len(), ShouldEqual, 44+32000)
+		})
+		Convey("token should print one JSON line per scope", func() {
+			code := run([]string{"-config", config, "-format", "ndjson", "token"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			So(len(lines), ShouldEqual, 3)
+			token := map[string]interface{}{}
+			So(json.Unmarshal([]byte(lines[0]), &token), ShouldBeNil)
+			So(token["scope"], ShouldEqual, "SPEECH")
+			So(token["access_token"], ShouldEqual, "123")
+		})
+		Convey("voices should print a JSON array", func() {
+			code := run([]string{"-config", config, "-format", "json", "voices"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			list := []map[string]interface{}{}
+			So(json.Unmarshal(stdout.Bytes(), &list), ShouldBeNil)
+			So(len(list), ShouldEqual, 7)
+			So(list[0]["name"], ShouldEqual, "claire")
+			So(list[0]["sample_rates"], ShouldResemble, []interface{}{8000.0, 16000.0})
+		})
+		Convey("voices should filter by language", func() {
+			code := run([]string{"-config", config, "voices", "-language", "es-US"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldStartWith, "alberto  es-US  male   8000,16000  audio/x-wav,audio/amr,audio/amr-wb\nrosa ")
+		})
+		Convey("tts should convert the audio to raw µ-law", func() {
+			code := run([]string{"-config", config, "tts", "-encoding", "mulaw", "-raw", "hello"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.Len(), ShouldEqual, 8000)
+		})
+		Convey("tts should reject unknown encodings", func() {
+			code := run([]string{"-config", config, "tts", "-encoding", "gsm", "hello"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "unknown encoding gsm, expected pcm, mulaw or alaw")
+		})
+		Convey("tts should reject unknown voices before sending", func() {
+			code := run([]string{"-config", config, "tts", "-voice", "crystl", "hello"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "unknown voice crystl")
+		})
+		Convey("-v should log requests to stderr without credentials", func() {
+			code := run([]string{"-config", config, "-v", "stt"}, bytes.NewReader(wav), stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stderr.String(), ShouldContainSubstring, "client_secret=[REDACTED]")
+			So(stderr.String(), ShouldContainSubstring, "msg=\"attspeech response\" resource=/speech/v3/speechToText")
+			So(stderr.String(), ShouldNotContainSubstring, "client_secret=bar")
+		})
+		Convey("eval should score a corpus and write the reports", func() {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "hello.wav"), wav, 0644)
+			os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("Hello, world!\n"), 0644)
+			os.WriteFile(filepath.Join(dir, "cruel.wav"), wav, 0644)
+			os.WriteFile(filepath.Join(dir, "cruel.txt"), []byte("hello cruel world"), 0644)
+			html := filepath.Join(dir, "report.html")
+			code := run([]string{"-config", config, "eval", "-html", html, dir}, nil, stdout, stderr)
+			So(code, ShouldEqual, 0)
+			So(stdout.String(), ShouldContainSubstring, `cruel.wav: WER 33.3% (S0 I0 D1) "hello world"`)
+			So(stdout.String(), ShouldContainSubstring, `hello.wav: WER 0.0% (S0 I0 D0) "hello world"`)
+			So(stderr.String(), ShouldEqual, "WER 20.0% (0 substitutions, 0 insertions, 1 deletions in 5 words), SER 50.0% (1 of 2), 0 failed\n")
+			report, _ := os.ReadFile(html)
+			So(string(report), ShouldContainSubstring, `<span class="deletion">cruel</span>`)
+		})
+		Convey("eval should print the report as JSON", func() {
+			manifest := "../../test/test.wav\thello world\nmissing.wav\thello\n"
+			code := run([]string{"-config", config, "-format", "json", "eval", "-manifest", "-"}, strings.NewReader(manifest), stdout, stderr)
+			So(code, ShouldEqual, 1)
+			So(stderr.String(), ShouldContainSubstring, "1 files failed")
+			report := map[string]interface{}{}
+			So(json.Unmarshal(stdout.Bytes(), &report), ShouldBeNil)
+			So(report["wer"], ShouldEqual, 0)
+			So(report["failed"], ShouldEqual, 1)
+		})
+		Convey("An unknown command should fail", func() {
+			code := run([]string{"foo"}, nil, stdout, stderr)
+			So(code, ShouldEqual, 2)
+			So(stderr.String(), ShouldContainSubstring, `unknown command "foo"`)
+		})
+	})
+}
+
+func serveHTTP() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.Contains(req.RequestURI, "/oauth/access_token"):
+			w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+		case strings.Contains(req.RequestURI, "/speech/v3/speechToText"):
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"hello world"}]}}`))
+		case strings.Contains(req.RequestURI, "/speech/v3/textToSpeech"):
+			// A second of 16 kHz silence
+			wav, _ := (&audio.Buffer{SampleRate: 16000, Channels: 1, Samples: make([]int16, 16000)}).WAV(audio.PCM)
+			w.Write(wav)
+		}
+	}))
+}
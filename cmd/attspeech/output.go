@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// validFormat reports whether format is a supported output format
+func validFormat(format string) bool {
+	return format == formatText || format == formatJSON || format == formatNDJSON
+}
+
+/*
+writeOne writes a single result in the selected output format, using
+text to render it when the format is text
+*/
+func (env *environment) writeOne(value interface{}, text func(w io.Writer) error) error {
+	switch env.format {
+	case formatJSON:
+		encoder := json.NewEncoder(env.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case formatNDJSON:
+		return json.NewEncoder(env.stdout).Encode(value)
+	}
+	return text(env.stdout)
+}
+
+/*
+writeMany writes a list of results in the selected output format. JSON
+output is a single array while NDJSON output has one result per line.
+*/
+func (env *environment) writeMany(values []interface{}, text func(w io.Writer) error) error {
+	switch env.format {
+	case formatJSON:
+		encoder := json.NewEncoder(env.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(values)
+	case formatNDJSON:
+		encoder := json.NewEncoder(env.stdout)
+		for _, value := range values {
+			if err := encoder.Encode(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return text(env.stdout)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsgoecke/attspeech"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// runVoices implements the voices subcommand
+func runVoices(env *environment, args []string) error {
+	flags := env.newFlagSet("voices", "")
+	language := flags.String("language", "", "only list voices for this language, e.g. es-US")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	voices := attspeech.Voices(*language)
+	values := []interface{}{}
+	for _, voice := range voices {
+		values = append(values, voice)
+	}
+	return env.writeMany(values, func(w io.Writer) error {
+		for _, voice := range voices {
+			rates := []string{}
+			for _, rate := range voice.SampleRates {
+				rates = append(rates, strconv.Itoa(rate))
+			}
+			_, err := fmt.Fprintf(w, "%-8s %-6s %-6s %-11s %s\n", voice.Name, voice.Language, voice.Gender,
+				strings.Join(rates, ","), strings.Join(voice.Formats, ","))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package dialog
+
+import (
+	"bytes"
+	"context"
+	"github.com/jsgoecke/attspeech"
+	"time"
+)
+
+// DefaultTimeout is how long ClientRecognizer waits for the caller when its Timeout is not set
+const DefaultTimeout = 5 * time.Second
+
+/*
+IO plays audio to the caller and records their answers. Record returns
+no audio when the caller says nothing within timeout.
+*/
+type IO interface {
+	Play(ctx context.Context, wav []byte) error
+	Record(ctx context.Context, timeout time.Duration) (audio []byte, contentType string, err error)
+}
+
+// ClientSynthesizer is a Synthesizer that speaks with TextToSpeech and plays the audio through IO
+type ClientSynthesizer struct {
+	Client *attspeech.Client
+	IO     IO
+	// Voice is the voice prompts are spoken with, the API's default when empty
+	Voice string
+}
+
+// Say synthesizes text as WAV and plays it
+func (synthesizer *ClientSynthesizer) Say(ctx context.Context, text string) error {
+	client := synthesizer.Client
+	apiRequest := client.NewAPIRequest(client.TTSResource)
+	apiRequest.Text = text
+	apiRequest.VoiceName = synthesizer.Voice
+	apiRequest.Accept = attspeech.AcceptWAV
+	speech, err := client.Synthesize(ctx, apiRequest)
+	if err != nil {
+		return err
+	}
+	return synthesizer.IO.Play(ctx, speech.Data)
+}
+
+// ClientRecognizer is a Recognizer that records through IO and recognizes with SpeechToTextCustom, or SpeechToText for free speech
+type ClientRecognizer struct {
+	Client *attspeech.Client
+	IO     IO
+	// Timeout is how long to wait for the caller, DefaultTimeout when 0
+	Timeout time.Duration
+	// Filename names the recording uploaded with a grammar, answer when empty
+	Filename string
+}
+
+// Recognize records the caller and recognizes the recording against grammar
+func (recognizer *ClientRecognizer) Recognize(ctx context.Context, grammar string) (*attspeech.Recognition, error) {
+	timeout := recognizer.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	audio, contentType, err := recognizer.IO.Record(ctx, timeout)
+	if err != nil || len(audio) == 0 {
+		return nil, err
+	}
+	client := recognizer.Client
+	if grammar == "" {
+		apiRequest := client.NewAPIRequest(client.STTResource)
+		apiRequest.Data = bytes.NewBuffer(audio)
+		apiRequest.ContentType = contentType
+		return client.SpeechToTextContext(ctx, apiRequest)
+	}
+	apiRequest := client.NewAPIRequest(client.STTCResource)
+	apiRequest.Data = bytes.NewBuffer(audio)
+	apiRequest.ContentType = contentType
+	apiRequest.Filename = recognizer.Filename
+	if apiRequest.Filename == "" {
+		apiRequest.Filename = "answer"
+	}
+	return client.SpeechToTextCustomContext(ctx, apiRequest, grammar, "")
+}
@@ -0,0 +1,96 @@
+package dialog
+
+import (
+	"context"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIO plays nothing and records its answer
+type fakeIO struct {
+	played  [][]byte
+	answer  []byte
+	timeout time.Duration
+}
+
+func (io *fakeIO) Play(ctx context.Context, wav []byte) error {
+	io.played = append(io.played, wav)
+	return nil
+}
+
+func (io *fakeIO) Record(ctx context.Context, timeout time.Duration) ([]byte, string, error) {
+	io.timeout = timeout
+	return io.answer, audio.ContentTypeAMR, nil
+}
+
+func TestClient(t *testing.T) {
+	Convey("Speaking and listening with the API", t, func() {
+		requests := map[string]string{}
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			requests[req.URL.Path] = string(body)
+			switch req.URL.Path {
+			case attspeech.TTSResource:
+				requests["voice"] = req.Header.Get("X-Arg")
+				buffer := &audio.Buffer{SampleRate: 8000, Channels: 1, Samples: make([]int16, 80)}
+				data, _ := buffer.WAV(audio.PCM)
+				w.Write(data)
+			default:
+				w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"coffee","Confidence":0.9}]}}`))
+			}
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+		call := &fakeIO{answer: []byte("#!AMR\ncoffee")}
+
+		Convey("Should synthesize prompts and play them", func() {
+			synthesizer := &ClientSynthesizer{Client: client, IO: call, Voice: "crystal"}
+			So(synthesizer.Say(context.Background(), "Coffee or tea?"), ShouldBeNil)
+			So(requests[attspeech.TTSResource], ShouldEqual, "Coffee or tea?")
+			So(requests["voice"], ShouldContainSubstring, "VoiceName=crystal")
+			So(len(call.played), ShouldEqual, 1)
+			So(string(call.played[0][:4]), ShouldEqual, "RIFF")
+		})
+		Convey("Should recognize recordings against grammars", func() {
+			recognizer := &ClientRecognizer{Client: client, IO: call}
+			recognition, err := recognizer.Recognize(context.Background(), drinkGrammar)
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.NBest[0].ResultText, ShouldEqual, "coffee")
+			So(requests[attspeech.STTCResource], ShouldContainSubstring, drinkGrammar)
+			So(requests[attspeech.STTCResource], ShouldContainSubstring, `filename="answer"`)
+			So(call.timeout, ShouldEqual, DefaultTimeout)
+
+			recognizer.Filename = "drink"
+			_, err = recognizer.Recognize(context.Background(), drinkGrammar)
+			So(err, ShouldBeNil)
+			So(requests[attspeech.STTCResource], ShouldContainSubstring, `filename="drink"`)
+		})
+		Convey("Should recognize free speech without a grammar", func() {
+			recognizer := &ClientRecognizer{Client: client, IO: call, Timeout: time.Second}
+			recognition, err := recognizer.Recognize(context.Background(), "")
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.Status, ShouldEqual, "OK")
+			So(requests[attspeech.STTResource], ShouldEqual, "#!AMR\ncoffee")
+			So(call.timeout, ShouldEqual, time.Second)
+		})
+		Convey("Should return no recognition when the caller says nothing", func() {
+			call.answer = nil
+			recognition, err := (&ClientRecognizer{Client: client, IO: call}).Recognize(context.Background(), drinkGrammar)
+			So(err, ShouldBeNil)
+			So(recognition, ShouldBeNil)
+			So(len(requests), ShouldEqual, 0)
+		})
+	})
+}
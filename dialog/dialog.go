@@ -0,0 +1,363 @@
+/*
+Package dialog runs multi-turn voice dialogs as state machines. Each
+State prompts the caller, recognizes the answer against its grammar and
+fills a slot, confirming answers recognized with low confidence and
+escalating its prompts on nomatch and noinput until it gives up.
+
+Dialogs speak through a Synthesizer and listen through a Recognizer, so
+they run against the API with ClientSynthesizer and ClientRecognizer
+and against scripted callers with the dialogtest package.
+
+	order := &dialog.Dialog{
+		Synthesizer: &dialog.ClientSynthesizer{Client: client, IO: call},
+		Recognizer:  &dialog.ClientRecognizer{Client: client, IO: call},
+		States: []*dialog.State{
+			{
+				Name:         "drink",
+				Slot:         "drink",
+				Prompts:      []string{"Would you like coffee or tea?", "Please say coffee or tea."},
+				Grammar:      drinkGrammar,
+				NoMatch:      []string{"Sorry, I did not understand."},
+				NoInput:      []string{"I did not hear you."},
+				ConfirmBelow: 0.5,
+				Confirm:      "Did you say {drink}?",
+				Escalate:     "operator",
+			},
+			{Name: "done", Prompts: []string{"One {drink} coming up."}, Next: dialog.End},
+			{Name: "operator", Prompts: []string{"Let me get someone to help you."}},
+		},
+	}
+	slots, err := order.Run(ctx, nil)
+*/
+package dialog
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxAttempts is how many nomatch, noinput and rejected answers a state takes when neither it nor the Dialog sets MaxAttempts
+const DefaultMaxAttempts = 3
+
+// YesNoGrammar is the SRGS grammar confirmations are recognized with when Dialog.ConfirmGrammar is empty
+const YesNoGrammar = `<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="yesno" mode="voice" tag-format="semantics/1.0-literals">` +
+	`<rule id="yesno"><one-of>` +
+	`<item><one-of><item>yes</item><item>yeah</item><item>yep</item><item>correct</item><item>right</item></one-of><tag>yes</tag></item>` +
+	`<item><one-of><item>no</item><item>nope</item><item>wrong</item></one-of><tag>no</tag></item>` +
+	`</one-of></rule></grammar>`
+
+/*
+Synthesizer speaks prompts to the caller. Say returns once the prompt has
+been played.
+*/
+type Synthesizer interface {
+	Say(ctx context.Context, text string) error
+}
+
+/*
+Recognizer listens to the caller and recognizes what they say against an
+SRGS grammar, or as free speech when grammar is empty. Recognize returns
+a nil Recognition when the caller says nothing.
+*/
+type Recognizer interface {
+	Recognize(ctx context.Context, grammar string) (*attspeech.Recognition, error)
+}
+
+// Slots holds the values a dialog has collected, by slot name
+type Slots map[string]string
+
+// Result is the best hypothesis of a recognition
+type Result struct {
+	// Text is what the caller said
+	Text string
+	// Value is the NLU output of the first grammar rule that matched, or Text when there is none
+	Value      string
+	Confidence float32
+	// Outs holds the NLU output of each grammar rule that matched, by grammar
+	Outs map[string]string
+}
+
+// End is a Next function that ends the dialog after its state
+func End(slots Slots) string {
+	return ""
+}
+
+/*
+State is one step of a dialog. A state with a Slot collects an answer
+into it, and is skipped when the slot is already filled, so answers that
+fill several slots at once move the dialog along. A state without a Slot
+only plays its first prompt.
+
+Prompts, NoMatch, NoInput and Confirm may refer to slots as {name}.
+*/
+type State struct {
+	Name string
+	// Prompts are played before each attempt, the nth attempt playing the nth prompt or the last one
+	Prompts []string
+	// Grammar is the SRGS grammar answers are recognized with, free speech when empty
+	Grammar string
+	// Slot is the slot the answer's value fills
+	Slot string
+	// Fill fills slots from an answer in place of setting Slot to its value, returning false to reject it as a nomatch
+	Fill func(result Result, slots Slots) bool
+	// NoMatch and NoInput are played when an answer is not recognized or not heard, escalating like Prompts
+	NoMatch []string
+	NoInput []string
+	// ConfirmBelow asks the caller to confirm answers recognized with a lower confidence using the Confirm prompt
+	ConfirmBelow float32
+	Confirm      string
+	// MaxAttempts is how many nomatch, noinput and rejected answers the state takes, Dialog.MaxAttempts when 0
+	MaxAttempts int
+	// Escalate is the state to go to when the attempts run out, ending the dialog with an error when empty
+	Escalate string
+	// Next returns the state to go to, the following state when nil, ending the dialog when it returns ""
+	Next func(slots Slots) string
+}
+
+// Dialog runs its States from the first one until a state ends it
+type Dialog struct {
+	Synthesizer Synthesizer
+	Recognizer  Recognizer
+	States      []*State
+	// MaxAttempts is the default for states that do not set it, DefaultMaxAttempts when 0
+	MaxAttempts int
+	// ConfirmGrammar recognizes confirmations, YesNoGrammar when empty, its value being "yes" or "no"
+	ConfirmGrammar string
+	// Logger receives the states the dialog goes through and the events in them, slog.Default() when nil
+	Logger *slog.Logger
+}
+
+/*
+Run runs the dialog with the slots already known, which may be nil, and
+returns the slots once a state ends it.
+
+	slots, err := order.Run(ctx, dialog.Slots{"size": "large"})
+	fmt.Println(slots["drink"])
+*/
+func (d *Dialog) Run(ctx context.Context, slots Slots) (Slots, error) {
+	if slots == nil {
+		slots = Slots{}
+	}
+	if len(d.States) == 0 {
+		return slots, errors.New("a dialog must have at least one state")
+	}
+	names := map[string]bool{}
+	for _, state := range d.States {
+		if state.Name == "" || names[state.Name] {
+			return slots, errors.New("every state must have a unique name, " + strconv.Quote(state.Name) + " is not")
+		}
+		names[state.Name] = true
+	}
+	state := d.States[0]
+	for state != nil {
+		if err := ctx.Err(); err != nil {
+			return slots, err
+		}
+		d.logger().Debug("dialog state", "state", state.Name)
+		next, err := d.visit(ctx, state, slots)
+		if err != nil {
+			return slots, err
+		}
+		if state, err = d.next(state, next); err != nil {
+			return slots, err
+		}
+	}
+	return slots, nil
+}
+
+// visit runs a state and returns the name of the state to go to, or "" to end
+func (d *Dialog) visit(ctx context.Context, state *State, slots Slots) (string, error) {
+	if state.Slot == "" {
+		if len(state.Prompts) > 0 {
+			if err := d.say(ctx, state.Prompts[0], slots); err != nil {
+				return "", err
+			}
+		}
+		return d.following(state, slots), nil
+	}
+	if _, ok := slots[state.Slot]; ok {
+		return d.following(state, slots), nil
+	}
+
+	maxAttempts := state.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = d.MaxAttempts
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	attempts, noMatches, noInputs := 0, 0, 0
+	for {
+		if err := d.say(ctx, escalated(state.Prompts, attempts), slots); err != nil {
+			return "", err
+		}
+		result, heard, err := d.recognize(ctx, state.Grammar)
+		if err != nil {
+			return "", err
+		}
+
+		before := slots.clone()
+		event := ""
+		switch {
+		case !heard:
+			noInputs++
+			event = escalated(state.NoInput, noInputs-1)
+		case result == nil || !d.fill(state, *result, slots):
+			noMatches++
+			event = escalated(state.NoMatch, noMatches-1)
+		case state.ConfirmBelow > 0 && result.Confidence < state.ConfirmBelow:
+			confirmed, err := d.confirm(ctx, state, slots)
+			if err != nil {
+				return "", err
+			}
+			if confirmed {
+				return d.following(state, slots), nil
+			}
+		default:
+			return d.following(state, slots), nil
+		}
+
+		for name := range slots {
+			if _, ok := before[name]; !ok {
+				delete(slots, name)
+			}
+		}
+		for name, value := range before {
+			slots[name] = value
+		}
+		attempts++
+		d.logger().Debug("dialog attempt failed", "state", state.Name, "attempts", attempts, "noMatches", noMatches, "noInputs", noInputs)
+		if attempts >= maxAttempts {
+			if state.Escalate == "" {
+				return "", errors.New("state " + state.Name + " was not completed after " + strconv.Itoa(maxAttempts) + " attempts")
+			}
+			d.logger().Debug("dialog escalated", "state", state.Name, "to", state.Escalate)
+			return state.Escalate, nil
+		}
+		if err := d.say(ctx, event, slots); err != nil {
+			return "", err
+		}
+	}
+}
+
+// clone copies the slots, so the ones a rejected answer filled can be undone
+func (slots Slots) clone() Slots {
+	clone := Slots{}
+	for name, value := range slots {
+		clone[name] = value
+	}
+	return clone
+}
+
+// fill fills slots from an answer with the state's Fill or its Slot
+func (d *Dialog) fill(state *State, result Result, slots Slots) bool {
+	if state.Fill != nil {
+		return state.Fill(result, slots)
+	}
+	slots[state.Slot] = result.Value
+	return true
+}
+
+// confirm asks the caller to confirm the slots an answer filled, anything but yes rejecting them
+func (d *Dialog) confirm(ctx context.Context, state *State, slots Slots) (bool, error) {
+	if err := d.say(ctx, state.Confirm, slots); err != nil {
+		return false, err
+	}
+	grammar := d.ConfirmGrammar
+	if grammar == "" {
+		grammar = YesNoGrammar
+	}
+	result, _, err := d.recognize(ctx, grammar)
+	if err != nil || result == nil {
+		return false, err
+	}
+	return strings.EqualFold(result.Value, "yes"), nil
+}
+
+/*
+recognize listens for an answer, returning whether the caller said
+anything and, when it was recognized, its best hypothesis
+*/
+func (d *Dialog) recognize(ctx context.Context, grammar string) (*Result, bool, error) {
+	recognition, err := d.Recognizer.Recognize(ctx, grammar)
+	if err != nil || recognition == nil {
+		return nil, false, err
+	}
+	recognized := recognition.Recognition
+	if recognized.Status != "OK" || len(recognized.NBest) == 0 {
+		return nil, true, nil
+	}
+	best := recognized.NBest[0]
+	result := &Result{Text: best.ResultText, Value: best.ResultText, Confidence: best.Confidence, Outs: map[string]string{}}
+	for _, out := range best.NluHypothesis.OutComposite {
+		if out.Out == "" {
+			continue
+		}
+		if len(result.Outs) == 0 {
+			result.Value = out.Out
+		}
+		result.Outs[out.Grammar] = out.Out
+	}
+	return result, true, nil
+}
+
+// say speaks text with its {slot} references replaced, saying nothing when it is empty
+func (d *Dialog) say(ctx context.Context, text string, slots Slots) error {
+	if text == "" {
+		return nil
+	}
+	pairs := []string{}
+	for name, value := range slots {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return d.Synthesizer.Say(ctx, strings.NewReplacer(pairs...).Replace(text))
+}
+
+// following returns the state a completed state goes to
+func (d *Dialog) following(state *State, slots Slots) string {
+	if state.Next != nil {
+		return state.Next(slots)
+	}
+	for i, candidate := range d.States {
+		if candidate == state && i+1 < len(d.States) {
+			return d.States[i+1].Name
+		}
+	}
+	return ""
+}
+
+// next looks a state up by name, returning nil for ""
+func (d *Dialog) next(from *State, name string) (*State, error) {
+	if name == "" {
+		return nil, nil
+	}
+	for _, state := range d.States {
+		if state.Name == name {
+			return state, nil
+		}
+	}
+	return nil, errors.New("state " + from.Name + " goes to unknown state " + name)
+}
+
+// escalated returns the nth of messages, or the last one when there are fewer
+func escalated(messages []string, n int) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	if n >= len(messages) {
+		n = len(messages) - 1
+	}
+	return messages[n]
+}
+
+// logger returns the logger for state transitions
+func (d *Dialog) logger() *slog.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,186 @@
+package dialog
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech/dialog/dialogtest"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+const drinkGrammar = `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="drink"><rule id="drink"><one-of><item>coffee</item><item>tea</item></one-of></rule></grammar>`
+
+// newOrder returns a dialog taking a drink order, escalating to an operator
+func newOrder(synthesizer Synthesizer, recognizer Recognizer) *Dialog {
+	return &Dialog{
+		Synthesizer: synthesizer,
+		Recognizer:  recognizer,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		States: []*State{
+			{
+				Name:         "drink",
+				Slot:         "drink",
+				Prompts:      []string{"Would you like coffee or tea?", "Please say coffee or tea."},
+				Grammar:      drinkGrammar,
+				NoMatch:      []string{"Sorry, I did not understand."},
+				NoInput:      []string{"I did not hear you.", "I still did not hear you."},
+				ConfirmBelow: 0.5,
+				Confirm:      "Did you say {drink}?",
+				Escalate:     "operator",
+			},
+			{Name: "done", Prompts: []string{"One {drink} coming up."}, Next: End},
+			{Name: "operator", Prompts: []string{"Let me get someone to help you."}},
+		},
+	}
+}
+
+func TestDialog(t *testing.T) {
+	Convey("Running dialogs", t, func() {
+		synthesizer := &dialogtest.Synthesizer{}
+		recognizer := &dialogtest.Recognizer{}
+		order := newOrder(synthesizer, recognizer)
+
+		Convey("Should prompt, fill the slot and move to the next state", func() {
+			recognizer.Turns = []dialogtest.Turn{dialogtest.Answer("coffee", 0.9)}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots, ShouldResemble, Slots{"drink": "coffee"})
+			So(synthesizer.Said, ShouldResemble, []string{"Would you like coffee or tea?", "One coffee coming up."})
+			So(recognizer.Grammars, ShouldResemble, []string{drinkGrammar})
+		})
+		Convey("Should escalate prompts and messages on noinput and nomatch", func() {
+			order.MaxAttempts = 4
+			recognizer.Turns = []dialogtest.Turn{dialogtest.NoInput, dialogtest.NoMatch, dialogtest.NoInput, dialogtest.Answer("tea", 0.8)}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots["drink"], ShouldEqual, "tea")
+			So(synthesizer.Said, ShouldResemble, []string{
+				"Would you like coffee or tea?",
+				"I did not hear you.",
+				"Please say coffee or tea.",
+				"Sorry, I did not understand.",
+				"Please say coffee or tea.",
+				"I still did not hear you.",
+				"Please say coffee or tea.",
+				"One tea coming up.",
+			})
+		})
+		Convey("Should confirm answers recognized with low confidence", func() {
+			recognizer.Turns = []dialogtest.Turn{
+				dialogtest.Answer("coffee", 0.3),
+				{Text: "yeah", Confidence: 0.9, Outs: map[string]string{"yesno": "yes"}},
+			}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots["drink"], ShouldEqual, "coffee")
+			So(synthesizer.Said, ShouldResemble, []string{"Would you like coffee or tea?", "Did you say coffee?", "One coffee coming up."})
+			So(recognizer.Grammars, ShouldResemble, []string{drinkGrammar, YesNoGrammar})
+		})
+		Convey("Should ask again when a confirmation is rejected", func() {
+			order.ConfirmGrammar = "yes or no"
+			recognizer.Turns = []dialogtest.Turn{
+				dialogtest.Answer("coffee", 0.3),
+				{Text: "nope", Confidence: 0.9, Outs: map[string]string{"yesno": "no"}},
+				dialogtest.Answer("tea", 0.9),
+			}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots["drink"], ShouldEqual, "tea")
+			So(synthesizer.Said, ShouldResemble, []string{"Would you like coffee or tea?", "Did you say coffee?", "Please say coffee or tea.", "One tea coming up."})
+			So(recognizer.Grammars[1], ShouldEqual, "yes or no")
+		})
+		Convey("Should escalate when the attempts run out", func() {
+			recognizer.Turns = []dialogtest.Turn{dialogtest.NoMatch, dialogtest.NoMatch, dialogtest.NoMatch}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots, ShouldResemble, Slots{})
+			So(synthesizer.Said, ShouldResemble, []string{
+				"Would you like coffee or tea?",
+				"Sorry, I did not understand.",
+				"Please say coffee or tea.",
+				"Sorry, I did not understand.",
+				"Please say coffee or tea.",
+				"Let me get someone to help you.",
+			})
+		})
+		Convey("Should fail when the attempts run out without an escalation", func() {
+			order.States[0].Escalate = ""
+			order.States[0].MaxAttempts = 1
+			recognizer.Turns = []dialogtest.Turn{dialogtest.NoInput}
+			_, err := order.Run(context.Background(), nil)
+			So(err.Error(), ShouldEqual, "state drink was not completed after 1 attempts")
+		})
+		Convey("Should fill several slots from one answer and skip the states they fill", func() {
+			order.States = append([]*State{{
+				Name:    "order",
+				Slot:    "size",
+				Prompts: []string{"What can I get you?"},
+				Grammar: "order",
+				Fill: func(result Result, slots Slots) bool {
+					if result.Outs["size"] == "" {
+						return false
+					}
+					slots["size"] = result.Outs["size"]
+					if drink := result.Outs["drink"]; drink != "" {
+						slots["drink"] = drink
+					}
+					return true
+				},
+				NoMatch: []string{"Which size?"},
+			}}, order.States...)
+			order.States[2].Prompts = []string{"One {size} {drink} coming up."}
+			recognizer.Turns = []dialogtest.Turn{
+				dialogtest.Answer("coffee", 0.9),
+				{Text: "a large coffee", Confidence: 0.9, Outs: map[string]string{"drink": "coffee", "size": "large"}},
+			}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots, ShouldResemble, Slots{"drink": "coffee", "size": "large"})
+			So(synthesizer.Said, ShouldResemble, []string{"What can I get you?", "Which size?", "What can I get you?", "One large coffee coming up."})
+		})
+		Convey("Should skip states whose slots are already known", func() {
+			slots, err := order.Run(context.Background(), Slots{"drink": "tea"})
+			So(err, ShouldBeNil)
+			So(slots["drink"], ShouldEqual, "tea")
+			So(synthesizer.Said, ShouldResemble, []string{"One tea coming up."})
+		})
+		Convey("Should recognize free speech without a grammar", func() {
+			order.States[0].Grammar = ""
+			recognizer.Turns = []dialogtest.Turn{{Text: "a flat white", Confidence: 0.9, Outs: map[string]string{"drink": ""}}}
+			slots, err := order.Run(context.Background(), nil)
+			So(err, ShouldBeNil)
+			So(slots["drink"], ShouldEqual, "a flat white")
+			So(recognizer.Grammars, ShouldResemble, []string{""})
+		})
+		Convey("Should return errors", func() {
+			recognizer.Turns = []dialogtest.Turn{{Err: errors.New("hung up")}}
+			_, err := order.Run(context.Background(), nil)
+			So(err.Error(), ShouldEqual, "hung up")
+
+			synthesizer.Err = errors.New("no audio")
+			_, err = order.Run(context.Background(), nil)
+			So(err.Error(), ShouldEqual, "no audio")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = order.Run(ctx, nil)
+			So(err, ShouldEqual, context.Canceled)
+		})
+		Convey("Should reject invalid dialogs", func() {
+			synthesizer.Err = nil
+			_, err := (&Dialog{}).Run(context.Background(), nil)
+			So(err.Error(), ShouldEqual, "a dialog must have at least one state")
+
+			order.States[2].Name = "done"
+			_, err = order.Run(context.Background(), Slots{"drink": "tea"})
+			So(err.Error(), ShouldEqual, `every state must have a unique name, "done" is not`)
+
+			order.States[2].Name = "operator"
+			order.States[1].Next = func(slots Slots) string { return "payment" }
+			_, err = order.Run(context.Background(), Slots{"drink": "tea"})
+			So(err.Error(), ShouldEqual, "state done goes to unknown state payment")
+		})
+	})
+}
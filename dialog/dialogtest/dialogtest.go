@@ -0,0 +1,134 @@
+/*
+Package dialogtest provides a scripted Synthesizer and Recognizer for
+testing dialogs without audio or the API.
+
+	synthesizer := &dialogtest.Synthesizer{}
+	recognizer := &dialogtest.Recognizer{Turns: []dialogtest.Turn{
+		dialogtest.NoInput,
+		dialogtest.NoMatch,
+		dialogtest.Answer("coffee", 0.9),
+	}}
+	order.Synthesizer, order.Recognizer = synthesizer, recognizer
+	slots, err := order.Run(ctx, nil)
+	fmt.Println(synthesizer.Said, slots["drink"])
+*/
+package dialogtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"sort"
+	"sync"
+)
+
+// Turn is what the scripted caller does when the dialog listens
+type Turn struct {
+	// Text is what the caller says
+	Text       string
+	Confidence float32
+	// Outs is the NLU output of the grammar rules Text matches, by grammar, in grammar order
+	Outs map[string]string
+	// NoInput makes the caller say nothing, NoMatch say something the grammar does not match
+	NoInput bool
+	NoMatch bool
+	// Err is returned in place of a recognition
+	Err error
+}
+
+var (
+	// NoInput is a turn where the caller says nothing
+	NoInput = Turn{NoInput: true}
+	// NoMatch is a turn where the caller is not recognized
+	NoMatch = Turn{NoMatch: true}
+)
+
+// Answer is a turn where the caller is recognized as saying text
+func Answer(text string, confidence float32) Turn {
+	return Turn{Text: text, Confidence: confidence}
+}
+
+// Synthesizer records the prompts a dialog says
+type Synthesizer struct {
+	mu   sync.Mutex
+	Said []string
+	// Err is returned from Say when set
+	Err error
+}
+
+// Say records text
+func (synthesizer *Synthesizer) Say(ctx context.Context, text string) error {
+	synthesizer.mu.Lock()
+	defer synthesizer.mu.Unlock()
+	if synthesizer.Err != nil {
+		return synthesizer.Err
+	}
+	synthesizer.Said = append(synthesizer.Said, text)
+	return nil
+}
+
+// Recognizer answers with its Turns in order, recording the grammars it is asked to recognize with
+type Recognizer struct {
+	mu       sync.Mutex
+	Turns    []Turn
+	Grammars []string
+}
+
+// Recognize returns the recognition of the next turn, failing when there are none left
+func (recognizer *Recognizer) Recognize(ctx context.Context, grammar string) (*attspeech.Recognition, error) {
+	recognizer.mu.Lock()
+	defer recognizer.mu.Unlock()
+	recognizer.Grammars = append(recognizer.Grammars, grammar)
+	if len(recognizer.Turns) == 0 {
+		return nil, errors.New("the script has no turns left")
+	}
+	turn := recognizer.Turns[0]
+	recognizer.Turns = recognizer.Turns[1:]
+	switch {
+	case turn.Err != nil:
+		return nil, turn.Err
+	case turn.NoInput:
+		return nil, nil
+	}
+	return Recognition(turn), nil
+}
+
+// Recognition builds the recognition the API returns for a turn
+func Recognition(turn Turn) *attspeech.Recognition {
+	type out struct {
+		Grammar string
+		Out     string
+	}
+	type hypothesis struct {
+		ResultText    string
+		Confidence    float32
+		NluHypothesis struct {
+			OutComposite []out
+		}
+	}
+	response := struct {
+		Recognition struct {
+			Status string
+			NBest  []hypothesis
+		}
+	}{}
+	response.Recognition.Status = "Speech Not Recognized"
+	if !turn.NoMatch {
+		best := hypothesis{ResultText: turn.Text, Confidence: turn.Confidence}
+		grammars := []string{}
+		for grammar := range turn.Outs {
+			grammars = append(grammars, grammar)
+		}
+		sort.Strings(grammars)
+		for _, grammar := range grammars {
+			best.NluHypothesis.OutComposite = append(best.NluHypothesis.OutComposite, out{Grammar: grammar, Out: turn.Outs[grammar]})
+		}
+		response.Recognition.Status = "OK"
+		response.Recognition.NBest = []hypothesis{best}
+	}
+	data, _ := json.Marshal(response)
+	recognition := &attspeech.Recognition{}
+	json.Unmarshal(data, recognition)
+	return recognition
+}
@@ -0,0 +1,55 @@
+package dialogtest
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	Convey("Scripting callers", t, func() {
+		Convey("Should record what is said", func() {
+			synthesizer := &Synthesizer{}
+			So(synthesizer.Say(context.Background(), "Hello"), ShouldBeNil)
+			So(synthesizer.Said, ShouldResemble, []string{"Hello"})
+			synthesizer.Err = errors.New("no audio")
+			So(synthesizer.Say(context.Background(), "Again"), ShouldEqual, synthesizer.Err)
+			So(synthesizer.Said, ShouldResemble, []string{"Hello"})
+		})
+		Convey("Should answer with the turns in order", func() {
+			recognizer := &Recognizer{Turns: []Turn{
+				{Text: "a large coffee", Confidence: 0.7, Outs: map[string]string{"size": "large", "drink": "coffee"}},
+				NoMatch,
+				NoInput,
+				{Err: errors.New("hung up")},
+			}}
+			recognition, err := recognizer.Recognize(context.Background(), "order")
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.Status, ShouldEqual, "OK")
+			best := recognition.Recognition.NBest[0]
+			So(best.ResultText, ShouldEqual, "a large coffee")
+			So(best.Confidence, ShouldEqual, float32(0.7))
+			So(best.NluHypothesis.OutComposite[0].Grammar, ShouldEqual, "drink")
+			So(best.NluHypothesis.OutComposite[1].Out, ShouldEqual, "large")
+
+			recognition, err = recognizer.Recognize(context.Background(), "order")
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.Status, ShouldEqual, "Speech Not Recognized")
+			So(len(recognition.Recognition.NBest), ShouldEqual, 0)
+
+			recognition, err = recognizer.Recognize(context.Background(), "order")
+			So(err, ShouldBeNil)
+			So(recognition, ShouldBeNil)
+
+			_, err = recognizer.Recognize(context.Background(), "order")
+			So(err.Error(), ShouldEqual, "hung up")
+			_, err = recognizer.Recognize(context.Background(), "")
+			So(err.Error(), ShouldEqual, "the script has no turns left")
+			So(recognizer.Grammars, ShouldResemble, []string{"order", "order", "order", "order", ""})
+		})
+		Convey("Should build answers", func() {
+			So(Answer("tea", 0.5), ShouldResemble, Turn{Text: "tea", Confidence: 0.5})
+		})
+	})
+}
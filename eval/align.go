@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Operation is how a reference word aligns with the hypothesis
+type Operation string
+
+const (
+	Correct      Operation = "correct"
+	Substitution Operation = "substitution"
+	Insertion    Operation = "insertion"
+	Deletion     Operation = "deletion"
+)
+
+// Edit is one step of an alignment. Reference is empty for insertions and Hypothesis for deletions.
+type Edit struct {
+	Operation  Operation `json:"op"`
+	Reference  string    `json:"ref,omitempty"`
+	Hypothesis string    `json:"hyp,omitempty"`
+}
+
+// Counts totals the edits of one or more alignments
+type Counts struct {
+	// Words is the number of reference words
+	Words         int `json:"words"`
+	Correct       int `json:"correct"`
+	Substitutions int `json:"substitutions"`
+	Insertions    int `json:"insertions"`
+	Deletions     int `json:"deletions"`
+}
+
+// Errors is the number of substitutions, insertions and deletions
+func (counts Counts) Errors() int {
+	return counts.Substitutions + counts.Insertions + counts.Deletions
+}
+
+/*
+WER is the word error rate, the errors over the reference words. It is 0
+for an empty reference and hypothesis, and 1 for an empty reference with
+insertions.
+*/
+func (counts Counts) WER() float64 {
+	if counts.Words == 0 {
+		if counts.Insertions > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(counts.Errors()) / float64(counts.Words)
+}
+
+// add adds other's counts to counts
+func (counts *Counts) add(other Counts) {
+	counts.Words += other.Words
+	counts.Correct += other.Correct
+	counts.Substitutions += other.Substitutions
+	counts.Insertions += other.Insertions
+	counts.Deletions += other.Deletions
+}
+
+/*
+Normalize lowercases text and splits it into words, dropping punctuation
+other than apostrophes inside words, so "Hello, world!" and "hello world"
+score the same.
+
+	eval.Normalize("It's 5 o'clock.") // [it's 5 o'clock]
+*/
+func Normalize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != '\''
+	})
+	normalized := []string{}
+	for _, word := range words {
+		if word = strings.Trim(word, "'"); word != "" {
+			normalized = append(normalized, word)
+		}
+	}
+	return normalized
+}
+
+/*
+Align aligns hypothesis against reference with the fewest substitutions,
+insertions and deletions, returning the edits in order and their counts.
+Of the alignments with the fewest edits it picks the one matching the
+most words, then prefers substitutions over deletions over insertions,
+so the same words always align the same way.
+
+	edits, counts := eval.Align(eval.Normalize("turn the lights on"), eval.Normalize("turn lights off"))
+	// correct turn, deletion the, correct lights, substitution on/off
+*/
+func Align(reference []string, hypothesis []string) ([]Edit, Counts) {
+	// cells[i][j] is the best alignment of reference[:i] with hypothesis[:j] and its last edit
+	type cell struct {
+		cost      int
+		matches   int
+		operation Operation
+	}
+	cells := make([][]cell, len(reference)+1)
+	for i := range cells {
+		cells[i] = make([]cell, len(hypothesis)+1)
+		cells[i][0] = cell{cost: i, operation: Deletion}
+	}
+	for j := range cells[0] {
+		cells[0][j] = cell{cost: j, operation: Insertion}
+	}
+	better := func(candidate cell, best cell) bool {
+		return candidate.cost < best.cost || candidate.cost == best.cost && candidate.matches > best.matches
+	}
+	for i := 1; i <= len(reference); i++ {
+		for j := 1; j <= len(hypothesis); j++ {
+			diagonal := cells[i-1][j-1]
+			best := cell{cost: diagonal.cost + 1, matches: diagonal.matches, operation: Substitution}
+			if reference[i-1] == hypothesis[j-1] {
+				best = cell{cost: diagonal.cost, matches: diagonal.matches + 1, operation: Correct}
+			}
+			if deletion := (cell{cost: cells[i-1][j].cost + 1, matches: cells[i-1][j].matches, operation: Deletion}); better(deletion, best) {
+				best = deletion
+			}
+			if insertion := (cell{cost: cells[i][j-1].cost + 1, matches: cells[i][j-1].matches, operation: Insertion}); better(insertion, best) {
+				best = insertion
+			}
+			cells[i][j] = best
+		}
+	}
+
+	edits := []Edit{}
+	counts := Counts{Words: len(reference)}
+	for i, j := len(reference), len(hypothesis); i > 0 || j > 0; {
+		switch cells[i][j].operation {
+		case Correct:
+			edits = append(edits, Edit{Operation: Correct, Reference: reference[i-1], Hypothesis: hypothesis[j-1]})
+			counts.Correct++
+			i, j = i-1, j-1
+		case Substitution:
+			edits = append(edits, Edit{Operation: Substitution, Reference: reference[i-1], Hypothesis: hypothesis[j-1]})
+			counts.Substitutions++
+			i, j = i-1, j-1
+		case Deletion:
+			edits = append(edits, Edit{Operation: Deletion, Reference: reference[i-1]})
+			counts.Deletions++
+			i--
+		default:
+			edits = append(edits, Edit{Operation: Insertion, Hypothesis: hypothesis[j-1]})
+			counts.Insertions++
+			j--
+		}
+	}
+	for left, right := 0, len(edits)-1; left < right; left, right = left+1, right-1 {
+		edits[left], edits[right] = edits[right], edits[left]
+	}
+	return edits, counts
+}
@@ -0,0 +1,59 @@
+package eval
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	Convey("Scoring hypotheses", t, func() {
+		Convey("Should normalize case and punctuation", func() {
+			So(Normalize("It's 5 o'clock, 'Bob'!  Hello-world."), ShouldResemble, []string{"it's", "5", "o'clock", "bob", "hello", "world"})
+			So(Normalize(" ... "), ShouldResemble, []string{})
+		})
+		Convey("Should align substitutions, insertions and deletions", func() {
+			edits, counts := Align(Normalize("turn the lights on"), Normalize("please turn lights off"))
+			So(edits, ShouldResemble, []Edit{
+				{Operation: Insertion, Hypothesis: "please"},
+				{Operation: Correct, Reference: "turn", Hypothesis: "turn"},
+				{Operation: Deletion, Reference: "the"},
+				{Operation: Correct, Reference: "lights", Hypothesis: "lights"},
+				{Operation: Substitution, Reference: "on", Hypothesis: "off"},
+			})
+			So(counts, ShouldResemble, Counts{Words: 4, Correct: 2, Substitutions: 1, Insertions: 1, Deletions: 1})
+			So(counts.Errors(), ShouldEqual, 3)
+			So(counts.WER(), ShouldEqual, 0.75)
+		})
+		Convey("Should align words shifted by a deletion and an insertion", func() {
+			edits, _ := Align([]string{"a", "b", "c"}, []string{"b", "c", "d"})
+			So(edits, ShouldResemble, []Edit{
+				{Operation: Deletion, Reference: "a"},
+				{Operation: Correct, Reference: "b", Hypothesis: "b"},
+				{Operation: Correct, Reference: "c", Hypothesis: "c"},
+				{Operation: Insertion, Hypothesis: "d"},
+			})
+		})
+		Convey("Should prefer substitutions to a deletion and an insertion", func() {
+			edits, counts := Align([]string{"a", "b"}, []string{"c", "d"})
+			So(edits, ShouldResemble, []Edit{
+				{Operation: Substitution, Reference: "a", Hypothesis: "c"},
+				{Operation: Substitution, Reference: "b", Hypothesis: "d"},
+			})
+			So(counts.WER(), ShouldEqual, 1)
+		})
+		Convey("Should score empty references and hypotheses", func() {
+			edits, counts := Align([]string{}, []string{})
+			So(edits, ShouldResemble, []Edit{})
+			So(counts.WER(), ShouldEqual, 0)
+			_, counts = Align([]string{}, []string{"uh"})
+			So(counts.WER(), ShouldEqual, 1)
+			_, counts = Align([]string{"hello", "world"}, []string{})
+			So(counts, ShouldResemble, Counts{Words: 2, Deletions: 2})
+			So(counts.WER(), ShouldEqual, 1)
+		})
+		Convey("Should let WER exceed 1 with many insertions", func() {
+			_, counts := Align([]string{"yes"}, []string{"oh", "yes", "yes", "please"})
+			So(counts.WER(), ShouldEqual, 3)
+		})
+	})
+}
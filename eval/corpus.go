@@ -0,0 +1,80 @@
+package eval
+
+import (
+	"bufio"
+	"errors"
+	"github.com/jsgoecke/attspeech/batch"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Entry is an audio file of the corpus with what is said in it
+type Entry struct {
+	// Name identifies the entry in reports
+	Name string `json:"name"`
+	// Path is where the audio is read from
+	Path string `json:"path"`
+	// ContentType of the audio, detected from the audio if empty
+	ContentType string `json:"content_type,omitempty"`
+	// Reference is the transcript the recognition is scored against
+	Reference string `json:"reference"`
+}
+
+/*
+Dir returns an Entry for every audio file under root that batch.Dir
+picks up, with the reference transcript read from the file of the same
+name with a .txt extension beside it.
+
+	/var/lib/corpus/balance.wav
+	/var/lib/corpus/balance.txt   what is my account balance
+*/
+func Dir(root string) ([]Entry, error) {
+	jobs, err := batch.Dir(root)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, job := range jobs {
+		path := strings.TrimSuffix(job.Path, filepath.Ext(job.Path)) + ".txt"
+		reference, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, errors.New("no reference transcript " + path + " for " + job.Path)
+			}
+			return nil, err
+		}
+		entries = append(entries, Entry{Name: job.Name, Path: job.Path, Reference: strings.TrimSpace(string(reference))})
+	}
+	return entries, nil
+}
+
+/*
+Manifest reads one Entry per line from r. Each line holds a path, a tab
+and the reference transcript. Blank lines and lines starting with '#'
+are ignored.
+
+	audio/balance.wav	what is my account balance
+*/
+func Manifest(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 2)
+		if len(fields) != 2 {
+			return nil, errors.New("manifest line " + strconv.Itoa(line) + " must be a path, a tab and the reference transcript")
+		}
+		path := strings.TrimSpace(fields[0])
+		entries = append(entries, Entry{Name: filepath.ToSlash(filepath.Clean(path)), Path: path, Reference: strings.TrimSpace(fields[1])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
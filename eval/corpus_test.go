@@ -0,0 +1,38 @@
+package eval
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCorpus(t *testing.T) {
+	Convey("Loading corpora", t, func() {
+		Convey("Should pair audio files with their transcripts", func() {
+			dir := t.TempDir()
+			os.MkdirAll(filepath.Join(dir, "calls"), 0755)
+			os.WriteFile(filepath.Join(dir, "calls", "balance.wav"), []byte("RIFF"), 0644)
+			os.WriteFile(filepath.Join(dir, "calls", "balance.txt"), []byte("what is my balance\n"), 0644)
+			os.WriteFile(filepath.Join(dir, "notes.md"), []byte("not audio"), 0644)
+			entries, err := Dir(dir)
+			So(err, ShouldBeNil)
+			So(entries, ShouldResemble, []Entry{{Name: "calls/balance.wav", Path: filepath.Join(dir, "calls", "balance.wav"), Reference: "what is my balance"}})
+
+			os.WriteFile(filepath.Join(dir, "orphan.amr"), []byte("#!AMR\n"), 0644)
+			_, err = Dir(dir)
+			So(err.Error(), ShouldEqual, "no reference transcript "+filepath.Join(dir, "orphan.txt")+" for "+filepath.Join(dir, "orphan.amr"))
+		})
+		Convey("Should read manifests", func() {
+			entries, err := Manifest(strings.NewReader("# corpus\n\naudio/../audio/balance.wav\twhat is my balance\nhours.amr\t when are you open \n"))
+			So(err, ShouldBeNil)
+			So(entries, ShouldResemble, []Entry{
+				{Name: "audio/balance.wav", Path: "audio/../audio/balance.wav", Reference: "what is my balance"},
+				{Name: "hours.amr", Path: "hours.amr", Reference: "when are you open"},
+			})
+			_, err = Manifest(strings.NewReader("hours.amr\twhen are you open\nbalance.wav\n"))
+			So(err.Error(), ShouldEqual, "manifest line 2 must be a path, a tab and the reference transcript")
+		})
+	})
+}
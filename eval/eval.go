@@ -0,0 +1,188 @@
+/*
+Package eval measures recognition accuracy. It runs a corpus of audio
+files with reference transcripts through SpeechToText, or
+SpeechToTextCustom when a grammar is given, and reports the word error
+rate (WER) and sentence error rate (SER) of each file and of the corpus,
+with the alignment of every hypothesis against its reference.
+
+	entries, err := eval.Dir("/var/lib/corpus")
+	report, err := eval.Run(ctx, client, entries, &eval.Options{Grammar: grammar})
+	fmt.Printf("WER %.1f%%, SER %.1f%%\n", report.WER*100, report.SER*100)
+	report.WriteHTML(file)
+
+Comparing the reports of runs with different speech contexts, grammars
+or dictionaries shows whether a change improves accuracy.
+*/
+package eval
+
+import (
+	"bytes"
+	"context"
+	"github.com/jsgoecke/attspeech"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of concurrent recognitions used when Options.Workers is not set
+const DefaultWorkers = 4
+
+// Options configures a Run
+type Options struct {
+	// Workers is the maximum number of recognitions in flight
+	Workers int
+	// Grammar and Dictionary are sent with SpeechToTextCustom, SpeechToText being used when Grammar is empty
+	Grammar    string
+	Dictionary string
+	// Prepare is called on each APIRequest before it is sent, e.g. to set XSpeechContext
+	Prepare func(apiRequest *attspeech.APIRequest)
+	// Normalize splits references and hypotheses into the words they are scored by, Normalize when nil
+	Normalize func(text string) []string
+	// OnResult is called with each result as it completes
+	OnResult func(result Result)
+}
+
+// Result is the score of one Entry
+type Result struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Reference  string `json:"reference"`
+	Hypothesis string `json:"hypothesis"`
+	// Confidence is the API's confidence in Hypothesis
+	Confidence float32 `json:"confidence"`
+	Edits      []Edit  `json:"edits"`
+	Counts     Counts  `json:"counts"`
+	WER        float64 `json:"wer"`
+	// Error is why the entry could not be recognized, such entries not being scored
+	Error   string        `json:"error,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Report is the outcome of a Run, in the order of its entries
+type Report struct {
+	Results []Result `json:"results"`
+	// Total sums the counts of the scored entries
+	Total Counts  `json:"total"`
+	WER   float64 `json:"wer"`
+	// Sentences is the number of scored entries and SentenceErrors those with any error
+	Sentences      int     `json:"sentences"`
+	SentenceErrors int     `json:"sentence_errors"`
+	SER            float64 `json:"ser"`
+	// Failed is the number of entries that could not be recognized
+	Failed int `json:"failed"`
+}
+
+/*
+Run recognizes entries using client with at most options.Workers
+concurrent requests and scores each recognition against its reference.
+Entries that fail are reported in their Result and left out of the
+totals rather than failing the Run. When ctx is cancelled Run returns its
+error with the results that completed, without totals.
+*/
+func Run(ctx context.Context, client *attspeech.Client, entries []Entry, options *Options) (*Report, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	normalize := options.Normalize
+	if normalize == nil {
+		normalize = Normalize
+	}
+
+	report := &Report{Results: make([]Result, len(entries))}
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range pending {
+				result := score(ctx, client, entries[index], options, normalize)
+				mu.Lock()
+				report.Results[index] = result
+				if options.OnResult != nil {
+					options.OnResult(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for index := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case pending <- index:
+		case <-ctx.Done():
+		}
+	}
+	close(pending)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	for _, result := range report.Results {
+		if result.Error != "" {
+			report.Failed++
+			continue
+		}
+		report.Total.add(result.Counts)
+		report.Sentences++
+		if result.Counts.Errors() > 0 {
+			report.SentenceErrors++
+		}
+	}
+	report.WER = report.Total.WER()
+	if report.Sentences > 0 {
+		report.SER = float64(report.SentenceErrors) / float64(report.Sentences)
+	}
+	return report, nil
+}
+
+// score recognizes an entry and aligns its hypothesis with its reference
+func score(ctx context.Context, client *attspeech.Client, entry Entry, options *Options, normalize func(string) []string) Result {
+	start := time.Now()
+	result := Result{Name: entry.Name, Path: entry.Path, Reference: entry.Reference, Edits: []Edit{}}
+	recognition, err := recognize(ctx, client, entry, options)
+	result.Elapsed = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if recognized := recognition.Recognition; recognized.Status == "OK" && len(recognized.NBest) > 0 {
+		result.Hypothesis = recognized.NBest[0].ResultText
+		result.Confidence = recognized.NBest[0].Confidence
+	}
+	result.Edits, result.Counts = Align(normalize(entry.Reference), normalize(result.Hypothesis))
+	result.WER = result.Counts.WER()
+	return result
+}
+
+// recognize reads the entry's audio and sends it to the API
+func recognize(ctx context.Context, client *attspeech.Client, entry Entry, options *Options) (*attspeech.Recognition, error) {
+	data, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	resource := client.STTResource
+	if options.Grammar != "" {
+		resource = client.STTCResource
+	}
+	apiRequest := client.NewAPIRequest(resource)
+	apiRequest.Data = bytes.NewBuffer(data)
+	apiRequest.ContentType = entry.ContentType
+	apiRequest.Filename = filepath.Base(entry.Path)
+	if options.Prepare != nil {
+		options.Prepare(apiRequest)
+	}
+	if options.Grammar == "" {
+		return client.SpeechToTextContext(ctx, apiRequest)
+	}
+	return client.SpeechToTextCustomContext(ctx, apiRequest, options.Grammar, options.Dictionary)
+}
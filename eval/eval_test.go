@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"context"
+	"github.com/jsgoecke/attspeech"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newCorpus writes AMR files that the fake API recognizes as the text they carry
+func newCorpus(t *testing.T, hypotheses map[string]string, references map[string]string) []Entry {
+	dir := t.TempDir()
+	entries := []Entry{}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, ok := references[name]; !ok {
+			continue
+		}
+		path := filepath.Join(dir, name+".amr")
+		os.WriteFile(path, []byte("#!AMR\n"+hypotheses[name]), 0644)
+		entries = append(entries, Entry{Name: name, Path: path, Reference: references[name]})
+	}
+	return entries
+}
+
+func TestRun(t *testing.T) {
+	Convey("Evaluating a corpus", t, func() {
+		var mu sync.Mutex
+		requests := []string{}
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			requests = append(requests, req.URL.Path+" "+req.Header.Get("X-SpeechContext"))
+			mu.Unlock()
+			text := string(body[strings.Index(string(body), "#!AMR\n")+6:])
+			text = strings.TrimSpace(strings.Split(text, "\r\n")[0])
+			if req.URL.Path == attspeech.STTCResource && !strings.Contains(string(body), "<grammar") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if text == "" {
+				w.Write([]byte(`{"Recognition":{"Status":"Speech Not Recognized","NBest":[]}}`))
+				return
+			}
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"` + text + `","Confidence":0.8}]}}`))
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+
+		Convey("Should score each file and the corpus", func() {
+			entries := newCorpus(t,
+				map[string]string{"a": "What is my balance?", "b": "when are you opened", "c": ""},
+				map[string]string{"a": "what is my balance", "b": "when are you open", "c": "goodbye"})
+			completed := 0
+			report, err := Run(context.Background(), client, entries, &Options{
+				Workers:  2,
+				Prepare:  func(apiRequest *attspeech.APIRequest) { apiRequest.XSpeechContext = "Generic" },
+				OnResult: func(result Result) { completed++ },
+			})
+			So(err, ShouldBeNil)
+			So(completed, ShouldEqual, 3)
+			So(requests, ShouldContain, attspeech.STTResource+" Generic")
+			So(report.Results[0].Hypothesis, ShouldEqual, "What is my balance?")
+			So(report.Results[0].Confidence, ShouldEqual, float32(0.8))
+			So(report.Results[0].WER, ShouldEqual, 0)
+			So(report.Results[1].Counts, ShouldResemble, Counts{Words: 4, Correct: 3, Substitutions: 1})
+			So(report.Results[1].WER, ShouldEqual, 0.25)
+			So(report.Results[2].Edits, ShouldResemble, []Edit{{Operation: Deletion, Reference: "goodbye"}})
+			So(report.Total, ShouldResemble, Counts{Words: 9, Correct: 7, Substitutions: 1, Deletions: 1})
+			So(report.WER, ShouldAlmostEqual, 2.0/9, 0.0001)
+			So(report.Sentences, ShouldEqual, 3)
+			So(report.SentenceErrors, ShouldEqual, 2)
+			So(report.SER, ShouldAlmostEqual, 2.0/3, 0.0001)
+			So(report.Failed, ShouldEqual, 0)
+		})
+		Convey("Should recognize with a grammar and leave failures out of the totals", func() {
+			entries := newCorpus(t, map[string]string{"a": "yes"}, map[string]string{"a": "yes", "b": "no"})
+			entries[1].Path = filepath.Join(t.TempDir(), "missing.amr")
+			report, err := Run(context.Background(), client, entries, &Options{Grammar: `<grammar root="yesno"/>`, Dictionary: "<lexicon/>"})
+			So(err, ShouldBeNil)
+			So(requests, ShouldResemble, []string{attspeech.STTCResource + " "})
+			So(report.Results[1].Error, ShouldContainSubstring, "missing.amr")
+			So(report.Failed, ShouldEqual, 1)
+			So(report.Sentences, ShouldEqual, 1)
+			So(report.Total.Words, ShouldEqual, 1)
+			So(report.WER, ShouldEqual, 0)
+		})
+		Convey("Should normalize with a custom normalizer", func() {
+			entries := newCorpus(t, map[string]string{"a": "Hello World"}, map[string]string{"a": "hello world"})
+			report, err := Run(context.Background(), client, entries, &Options{Normalize: strings.Fields})
+			So(err, ShouldBeNil)
+			So(report.WER, ShouldEqual, 1)
+		})
+		Convey("Should stop when cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			entries := newCorpus(t, map[string]string{}, map[string]string{"a": "hello"})
+			report, err := Run(ctx, client, entries, nil)
+			So(err, ShouldEqual, context.Canceled)
+			So(report.Sentences, ShouldEqual, 0)
+		})
+	})
+}
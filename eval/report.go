@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"strconv"
+)
+
+// WriteJSON writes the report as indented JSON
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+/*
+WriteHTML writes the report as a standalone HTML page: the corpus totals
+followed by a table of every entry, with its alignment marking
+substitutions, insertions and deletions
+*/
+func (report *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, report)
+}
+
+// percent formats a rate as a percentage with one decimal place
+func percent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"percent": percent}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Recognition accuracy</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.substitution { background: #fde2a7; }
+.insertion { background: #c8e6c9; }
+.deletion { background: #f8bbd0; text-decoration: line-through; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Recognition accuracy</h1>
+<table>
+<tr><th>WER</th><td>{{percent .WER}}</td></tr>
+<tr><th>SER</th><td>{{percent .SER}} ({{.SentenceErrors}} of {{.Sentences}} sentences)</td></tr>
+<tr><th>Words</th><td>{{.Total.Words}}</td></tr>
+<tr><th>Substitutions</th><td>{{.Total.Substitutions}}</td></tr>
+<tr><th>Insertions</th><td>{{.Total.Insertions}}</td></tr>
+<tr><th>Deletions</th><td>{{.Total.Deletions}}</td></tr>
+<tr><th>Failed</th><td>{{.Failed}}</td></tr>
+</table>
+<h2>Files</h2>
+<table>
+<tr><th>File</th><th>WER</th><th>S</th><th>I</th><th>D</th><th>Alignment</th></tr>
+{{range .Results}}<tr>
+<td>{{.Name}}</td>
+{{if .Error}}<td colspan="5" class="error">{{.Error}}</td>
+{{else}}<td>{{percent .WER}}</td><td>{{.Counts.Substitutions}}</td><td>{{.Counts.Insertions}}</td><td>{{.Counts.Deletions}}</td>
+<td>{{range .Edits}}{{if eq .Operation "correct"}}{{.Reference}} {{else if eq .Operation "substitution"}}<span class="substitution" title="{{.Reference}}">{{.Hypothesis}}</span> {{else if eq .Operation "insertion"}}<span class="insertion">{{.Hypothesis}}</span> {{else}}<span class="deletion">{{.Reference}}</span> {{end}}{{end}}</td>
+{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
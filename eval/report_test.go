@@ -0,0 +1,46 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	Convey("Writing reports", t, func() {
+		edits, counts := Align(Normalize("turn the lights on"), Normalize("please turn lights off"))
+		report := &Report{
+			Results: []Result{
+				{Name: "lights.wav", Reference: "turn the lights on", Hypothesis: "please turn lights off", Edits: edits, Counts: counts, WER: counts.WER()},
+				{Name: "<missing>.wav", Error: "open missing.wav: no such file or directory", Edits: []Edit{}},
+			},
+			Total:          counts,
+			WER:            counts.WER(),
+			Sentences:      1,
+			SentenceErrors: 1,
+			SER:            1,
+			Failed:         1,
+		}
+
+		Convey("Should write JSON", func() {
+			buffer := &bytes.Buffer{}
+			So(report.WriteJSON(buffer), ShouldBeNil)
+			decoded := &Report{}
+			So(json.Unmarshal(buffer.Bytes(), decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, report)
+			So(buffer.String(), ShouldContainSubstring, `"op": "deletion"`)
+		})
+		Convey("Should write HTML marking the edits", func() {
+			buffer := &bytes.Buffer{}
+			So(report.WriteHTML(buffer), ShouldBeNil)
+			html := buffer.String()
+			So(html, ShouldStartWith, "<!DOCTYPE html>")
+			So(html, ShouldContainSubstring, "<tr><th>WER</th><td>75.0%</td></tr>")
+			So(html, ShouldContainSubstring, "<td>100.0% (1 of 1 sentences)</td>")
+			So(html, ShouldContainSubstring, `<span class="insertion">please</span> turn <span class="deletion">the</span> lights <span class="substitution" title="on">off</span> `)
+			So(html, ShouldContainSubstring, "<td>&lt;missing&gt;.wav</td>")
+			So(html, ShouldContainSubstring, `<td colspan="5" class="error">open missing.wav: no such file or directory</td>`)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package attspeech
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+Limit configures client side throttling of requests to a resource, so the
+AT&T transactions-per-second quotas are respected before a request is sent
+rather than reported as a PolicyException afterwards
+
+	client := attspeech.New("<id>", "<secret>", "")
+	client.SetLimit(client.STTResource, attspeech.Limit{Rate: 1, Burst: 1, MaxInFlight: 2})
+	client.SetAuthTokens()
+*/
+type Limit struct {
+	// Rate is the sustained number of requests per second, zero means unlimited
+	Rate float64
+	// Burst is the number of requests that may be sent at once before Rate applies, at least 1
+	Burst int
+	// MaxInFlight is the maximum number of concurrent requests, zero means unlimited
+	MaxInFlight int
+}
+
+// LimitStats reports how a resource's requests were throttled
+type LimitStats struct {
+	// Requests is the number of requests that passed through the limiter
+	Requests int64
+	// Queued is the number of requests that had to wait
+	Queued int64
+	// QueuedTime is the total time requests spent waiting
+	QueuedTime time.Duration
+	// MaxQueuedTime is the longest time a single request waited
+	MaxQueuedTime time.Duration
+	// InFlight is the number of requests currently being sent
+	InFlight int
+}
+
+// limiter combines a token bucket with a max-in-flight semaphore
+type limiter struct {
+	limit     Limit
+	semaphore chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  LimitStats
+}
+
+/*
+SetLimit throttles requests to resource, one of the client's STTResource,
+STTCResource, TTSResource or OauthResource. It should be called before
+the client is used, as it is not safe to call concurrently with requests.
+*/
+func (client *Client) SetLimit(resource string, limit Limit) {
+	if client.limiters == nil {
+		client.limiters = make(map[string]*limiter)
+	}
+	client.limiters[resource] = newLimiter(limit)
+}
+
+// LimitStats returns the throttling statistics for resource
+func (client *Client) LimitStats(resource string) LimitStats {
+	l := client.limiters[resource]
+	if l == nil {
+		return LimitStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// newLimiter creates a limiter with a full token bucket
+func newLimiter(limit Limit) *limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	l := &limiter{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+	if limit.MaxInFlight > 0 {
+		l.semaphore = make(chan struct{}, limit.MaxInFlight)
+	}
+	return l
+}
+
+/*
+acquire waits for both a free in-flight slot and a rate token, returning
+the function that releases the slot once the request has completed
+*/
+func (client *Client) acquire(ctx context.Context, resource string) (func(), error) {
+	l := client.limiters[resource]
+	if l == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	if l.semaphore != nil {
+		select {
+		case l.semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.wait(ctx); err != nil {
+		if l.semaphore != nil {
+			<-l.semaphore
+		}
+		return nil, err
+	}
+	l.record(time.Since(start))
+	return func() {
+		l.mu.Lock()
+		l.stats.InFlight--
+		l.mu.Unlock()
+		if l.semaphore != nil {
+			<-l.semaphore
+		}
+	}, nil
+}
+
+// wait blocks until the token bucket has a token to spend or ctx is done
+func (l *limiter) wait(ctx context.Context) error {
+	if l.limit.Rate <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.limit.Rate
+		if l.tokens > float64(l.limit.Burst) {
+			l.tokens = float64(l.limit.Burst)
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.limit.Rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// record updates the statistics for a request that waited for queued
+func (l *limiter) record(queued time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Requests++
+	l.stats.InFlight++
+	// Anything under a millisecond is scheduling noise rather than throttling
+	if queued < time.Millisecond {
+		return
+	}
+	l.stats.Queued++
+	l.stats.QueuedTime += queued
+	if queued > l.stats.MaxQueuedTime {
+		l.stats.MaxQueuedTime = queued
+	}
+}
@@ -0,0 +1,95 @@
+package attspeech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	Convey("Throttling requests per resource", t, func() {
+		var inFlight, maxInFlight int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			w.Write(recognitionJSON())
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+
+		recognize := func(ctx context.Context) error {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.ContentType = "audio/wav"
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 1, 8000, 16, 800))
+			_, err := client.SpeechToTextContext(ctx, apiRequest)
+			return err
+		}
+
+		Convey("Should not exceed MaxInFlight", func() {
+			client.SetLimit(client.STTResource, Limit{MaxInFlight: 2})
+			var wg sync.WaitGroup
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					recognize(context.Background())
+				}()
+			}
+			wg.Wait()
+			So(atomic.LoadInt32(&maxInFlight), ShouldEqual, 2)
+			stats := client.LimitStats(client.STTResource)
+			So(stats.Requests, ShouldEqual, 6)
+			So(stats.Queued, ShouldBeGreaterThan, 0)
+			So(stats.InFlight, ShouldEqual, 0)
+		})
+		Convey("Should pace requests to the Rate once the Burst is spent", func() {
+			client.SetLimit(client.STTResource, Limit{Rate: 20, Burst: 1})
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				So(recognize(context.Background()), ShouldBeNil)
+			}
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 60*time.Millisecond)
+			stats := client.LimitStats(client.STTResource)
+			So(stats.QueuedTime, ShouldBeGreaterThan, 0)
+			So(stats.MaxQueuedTime, ShouldBeLessThanOrEqualTo, stats.QueuedTime)
+		})
+		Convey("Should stop waiting when the context is done", func() {
+			client.SetLimit(client.STTResource, Limit{Rate: 0.1, Burst: 1})
+			So(recognize(context.Background()), ShouldBeNil)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			So(errors.Is(recognize(ctx), context.DeadlineExceeded), ShouldBeTrue)
+		})
+		Convey("Should leave other resources unthrottled", func() {
+			client.SetLimit(client.TTSResource, Limit{Rate: 0.1, Burst: 1})
+			So(recognize(context.Background()), ShouldBeNil)
+			So(recognize(context.Background()), ShouldBeNil)
+			So(client.LimitStats(client.STTResource), ShouldResemble, LimitStats{})
+		})
+		Convey("Should throttle token requests", func() {
+			client.SetLimit(client.OauthResource, Limit{Rate: 1000, Burst: 1})
+			So(client.SetAuthTokens(), ShouldBeNil)
+			So(client.LimitStats(client.OauthResource).Requests, ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package attspeech
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets, and transcripts and text when LogOptions.RedactText is set, in logs
+const Redacted = "[REDACTED]"
+
+// secretKeys are the JSON keys and query parameters whose values are always redacted
+var secretKeys = map[string]bool{
+	"client_secret": true,
+	"access_token":  true,
+	"refresh_token": true,
+}
+
+// textKeys are the JSON keys holding transcripts, redacted when LogOptions.RedactText is set
+var textKeys = map[string]bool{
+	"Hypothesis": true,
+	"ResultText": true,
+	"Words":      true,
+	"Out":        true,
+}
+
+/*
+LogOptions configures logging of the requests the client sends and the
+responses it receives. The client secret, bearer tokens and refresh tokens
+are always redacted; audio is logged by its size only.
+
+	client.Logging = &attspeech.LogOptions{
+		Logger:       slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		RequestLevel: slog.LevelDebug,
+		ErrorLevel:   slog.LevelWarn,
+		RedactText:   true,
+	}
+*/
+type LogOptions struct {
+	// Logger receives the records, slog.Default() when nil
+	Logger *slog.Logger
+	// RequestLevel is the level of requests and successful responses
+	RequestLevel slog.Level
+	// ErrorLevel is the level of failed requests and error responses
+	ErrorLevel slog.Level
+	// RedactText redacts recognized transcripts and the text sent to Text to Speech
+	RedactText bool
+}
+
+// logger returns the logger to use, or nil when logging is off
+func (options *LogOptions) logger() *slog.Logger {
+	if options == nil {
+		return nil
+	}
+	if options.Logger == nil {
+		return slog.Default()
+	}
+	return options.Logger
+}
+
+// logRequest logs a request about to be sent, with its secrets redacted
+func (client *Client) logRequest(ctx context.Context, resource string, req *http.Request, apiRequest *APIRequest, size int64) {
+	logger := client.Logging.logger()
+	if logger == nil || !logger.Enabled(ctx, client.Logging.RequestLevel) {
+		return
+	}
+	attributes := []slog.Attr{
+		slog.String("resource", resource),
+		slog.String("url", redactURL(req.URL)),
+		slog.Any("headers", redactHeaders(req.Header)),
+	}
+	// Streamed uploads have no length until they are complete
+	if size >= 0 {
+		attributes = append(attributes, slog.Int64("bytes", size))
+	}
+	if resource == client.TTSResource {
+		attributes = append(attributes, slog.String("text", client.Logging.redactText(apiRequest.Text)))
+	}
+	logger.LogAttrs(ctx, client.Logging.RequestLevel, "attspeech request", attributes...)
+}
+
+// logResponse logs a response, or the error that prevented one, at the error level unless it succeeded
+func (client *Client) logResponse(ctx context.Context, resource string, statusCode int, body []byte, elapsed time.Duration, err error) {
+	logger := client.Logging.logger()
+	if logger == nil {
+		return
+	}
+	level := client.Logging.RequestLevel
+	if err != nil || statusCode != 200 {
+		level = client.Logging.ErrorLevel
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attributes := []slog.Attr{
+		slog.String("resource", resource),
+		slog.Duration("elapsed", elapsed),
+	}
+	if err != nil {
+		attributes = append(attributes, slog.String("error", redactError(err).Error()))
+		logger.LogAttrs(ctx, level, "attspeech request failed", attributes...)
+		return
+	}
+	attributes = append(attributes, slog.Int("status", statusCode), slog.Int("bytes", len(body)))
+	if redacted, ok := client.Logging.redactJSON(body); ok {
+		attributes = append(attributes, slog.String("body", redacted))
+	}
+	logger.LogAttrs(ctx, level, "attspeech response", attributes...)
+}
+
+// redactText returns text, or Redacted when RedactText is set
+func (options *LogOptions) redactText(text string) string {
+	if options.RedactText {
+		return Redacted
+	}
+	return text
+}
+
+/*
+redactJSON returns a JSON body with its secrets, and its transcripts when
+RedactText is set, replaced by Redacted. Bodies that are not JSON, such as
+audio, are not returned.
+*/
+func (options *LogOptions) redactJSON(body []byte) (string, bool) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", false
+	}
+	redacted, err := json.Marshal(options.redactValue(value))
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+// redactValue walks a decoded JSON value, replacing the values of secret and text keys
+func (options *LogOptions) redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if secretKeys[key] || options.RedactText && textKeys[key] {
+				value[key] = Redacted
+				continue
+			}
+			value[key] = options.redactValue(child)
+		}
+	case []interface{}:
+		for i, child := range value {
+			value[i] = options.redactValue(child)
+		}
+	}
+	return value
+}
+
+// redactURL returns a URL with the values of secret query parameters redacted
+func redactURL(u *url.URL) string {
+	redacted := *u
+	params := strings.Split(redacted.RawQuery, "&")
+	for i, param := range params {
+		if key := strings.SplitN(param, "=", 2)[0]; secretKeys[key] {
+			params[i] = key + "=" + Redacted
+		}
+	}
+	redacted.RawQuery = strings.Join(params, "&")
+	return redacted.String()
+}
+
+/*
+redactError returns err with the secret query parameters of its URL
+redacted when it is a *url.Error, as the transport errors of the OAuth
+request are, since their text includes the whole URL
+*/
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return &url.Error{Op: urlErr.Op, URL: Redacted, Err: urlErr.Err}
+	}
+	return &url.Error{Op: urlErr.Op, URL: redactURL(u), Err: urlErr.Err}
+}
+
+// redactHeaders returns the request headers with the credentials of the Authorization header redacted
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for key, values := range header {
+		value := strings.Join(values, ", ")
+		if key == "Authorization" {
+			scheme := strings.SplitN(value, " ", 2)[0]
+			value = scheme + " " + Redacted
+		}
+		headers[key] = value
+	}
+	return headers
+}
@@ -0,0 +1,95 @@
+package attspeech
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	Convey("Logging requests and responses", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case strings.Contains(req.RequestURI, OauthResource):
+				w.Write(oauthJSON())
+			case strings.Contains(req.RequestURI, TTSResource):
+				w.WriteHeader(400)
+				w.Write(policyErrorJSON())
+			default:
+				w.Write(recognitionJSON())
+			}
+		}))
+		defer ts.Close()
+		logs := &bytes.Buffer{}
+		client := New("foo", "s3cret", ts.URL)
+		client.Logging = &LogOptions{
+			Logger:       slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			RequestLevel: slog.LevelDebug,
+			ErrorLevel:   slog.LevelWarn,
+		}
+		So(client.SetAuthTokens(), ShouldBeNil)
+		recognize := func() {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 1, 8000, 16, 800))
+			_, err := client.SpeechToText(apiRequest)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("Should always redact the client secret and tokens", func() {
+			So(logs.String(), ShouldContainSubstring, "client_secret=[REDACTED]&scope=SPEECH")
+			So(logs.String(), ShouldContainSubstring, `\"access_token\":\"[REDACTED]\"`)
+			So(logs.String(), ShouldContainSubstring, `\"refresh_token\":\"[REDACTED]\"`)
+			So(logs.String(), ShouldNotContainSubstring, "s3cret")
+			recognize()
+			So(logs.String(), ShouldContainSubstring, "Authorization:Bearer [REDACTED]")
+			So(logs.String(), ShouldNotContainSubstring, "Bearer 123")
+		})
+		Convey("Should log requests and responses at the request level", func() {
+			recognize()
+			So(logs.String(), ShouldContainSubstring, "level=DEBUG msg=\"attspeech request\" resource=/speech/v3/speechToText")
+			So(logs.String(), ShouldContainSubstring, "bytes=1644")
+			So(logs.String(), ShouldContainSubstring, "level=DEBUG msg=\"attspeech response\" resource=/speech/v3/speechToText")
+			So(logs.String(), ShouldContainSubstring, `\"ResultText\":\"If you wish`)
+		})
+		Convey("Should log error responses at the error level", func() {
+			apiRequest := client.NewAPIRequest(client.TTSResource)
+			apiRequest.Text = "my password is zanzibar"
+			client.TextToSpeech(apiRequest)
+			So(logs.String(), ShouldContainSubstring, "text=\"my password is zanzibar\"")
+			So(logs.String(), ShouldContainSubstring, "level=WARN msg=\"attspeech response\" resource=/speech/v3/textToSpeech")
+			So(logs.String(), ShouldContainSubstring, "status=400")
+		})
+		Convey("Should redact transcripts and text when asked", func() {
+			client.Logging.RedactText = true
+			recognize()
+			apiRequest := client.NewAPIRequest(client.TTSResource)
+			apiRequest.Text = "my password is zanzibar"
+			client.TextToSpeech(apiRequest)
+			So(logs.String(), ShouldContainSubstring, `\"ResultText\":\"[REDACTED]\"`)
+			So(logs.String(), ShouldNotContainSubstring, "If you wish")
+			So(logs.String(), ShouldNotContainSubstring, "zanzibar")
+		})
+		Convey("Should redact the client secret from transport errors", func() {
+			unreachable := httptest.NewServer(http.NotFoundHandler())
+			unreachable.Close()
+			logs.Reset()
+			client.APIBase = unreachable.URL
+			err := client.SetAuthTokens()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "client_secret=[REDACTED]")
+			So(err.Error(), ShouldNotContainSubstring, "s3cret")
+			So(logs.String(), ShouldContainSubstring, "level=WARN msg=\"attspeech request failed\"")
+			So(logs.String(), ShouldNotContainSubstring, "s3cret")
+		})
+		Convey("Should log nothing below the logger's level", func() {
+			logs.Reset()
+			client.Logging.Logger = slog.New(slog.NewTextHandler(logs, nil))
+			recognize()
+			So(logs.String(), ShouldBeBlank)
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package attspeech
+
+import (
+	"context"
+	"github.com/jsgoecke/attspeech/audio"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+Metrics receives measurements of the work done by a client. Set
+client.Metrics to collect them, for example with the Prometheus exporter
+in the metrics package:
+
+	exporter := metrics.NewPrometheus()
+	client.Metrics = exporter
+	http.Handle("/metrics", exporter)
+
+Implementations must be safe for concurrent use.
+*/
+type Metrics interface {
+	// ObserveRequest records a request to resource, status is zero when no response was received
+	ObserveRequest(resource string, status int, elapsed time.Duration)
+	// ObserveAPIError records an error returned by the API by its MessageId, e.g. SVC0001 or POL0001
+	ObserveAPIError(resource string, messageID string)
+	// ObserveTokenRefresh records fetching the token for an OAuth scope, err is nil when it succeeded
+	ObserveTokenRefresh(scope string, err error)
+	// ObserveAudioUploaded records the bytes of audio sent for recognition
+	ObserveAudioUploaded(resource string, bytes int)
+	// ObserveAudioProcessed records the length of audio recognized or synthesized, when it is known
+	ObserveAudioProcessed(resource string, duration time.Duration)
+	// ObserveCharactersSynthesized records the characters of text sent to Text to Speech
+	ObserveCharactersSynthesized(characters int)
+}
+
+// observeRequest records a request when client.Metrics is set
+func (client *Client) observeRequest(resource string, status int, start time.Time) {
+	if client.Metrics != nil {
+		client.Metrics.ObserveRequest(resource, status, time.Since(start))
+	}
+}
+
+// observeAPIError records the MessageId of an error returned by the API, and returns it
+func (client *Client) observeAPIError(ctx context.Context, resource string, apiError *APIError) string {
+	messageID := apiError.RequestError.ServiceException.MessageID
+	if messageID == "" {
+		messageID = apiError.RequestError.PolicyException.MessageID
+	}
+	if messageID == "" {
+		messageID = "unknown"
+	}
+	trace.SpanFromContext(ctx).SetAttributes(MessageIDKey.String(messageID))
+	if client.Metrics != nil {
+		client.Metrics.ObserveAPIError(resource, messageID)
+	}
+	return messageID
+}
+
+// observeTokenRefresh records fetching the token for scope
+func (client *Client) observeTokenRefresh(scope string, err error) {
+	if client.Metrics != nil {
+		client.Metrics.ObserveTokenRefresh(scope, err)
+	}
+}
+
+/*
+observeUpload records the size of audio about to be recognized, and its
+length when it is WAV, in the metrics and on the span in ctx
+*/
+func (client *Client) observeUpload(ctx context.Context, resource string, apiRequest *APIRequest) {
+	trace.SpanFromContext(ctx).SetAttributes(ContentTypeKey.String(apiRequest.ContentType))
+	duration, known := time.Duration(0), false
+	if isWAV(apiRequest.ContentType) {
+		duration, known = wavDuration(apiRequest.Data.Bytes())
+	}
+	if known {
+		setAudioDuration(ctx, duration)
+	}
+	if client.Metrics == nil {
+		return
+	}
+	client.Metrics.ObserveAudioUploaded(resource, apiRequest.Data.Len())
+	if known {
+		client.Metrics.ObserveAudioProcessed(resource, duration)
+	}
+}
+
+// observeSynthesis records the text synthesized and the length of the audio returned
+func (client *Client) observeSynthesis(ctx context.Context, resource string, text string, data []byte) {
+	duration, known := time.Duration(0), false
+	if audio.Sniff(data) == audio.ContentTypeWAV {
+		duration, known = wavDuration(data)
+	}
+	if known {
+		setAudioDuration(ctx, duration)
+	}
+	if client.Metrics == nil {
+		return
+	}
+	client.Metrics.ObserveCharactersSynthesized(utf8.RuneCountInString(text))
+	if known {
+		client.Metrics.ObserveAudioProcessed(resource, duration)
+	}
+}
+
+// wavDuration returns the length of WAV audio, and false when its header cannot be parsed
+func wavDuration(data []byte) (time.Duration, bool) {
+	header, err := audio.ParseWAVHeader(data)
+	if err != nil {
+		return 0, false
+	}
+	return header.Duration(), true
+}
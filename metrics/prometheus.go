@@ -0,0 +1,249 @@
+/*
+Package metrics exports the measurements of an attspeech client in the
+Prometheus text exposition format, without depending on the Prometheus
+client library.
+
+	exporter := metrics.NewPrometheus()
+	client.Metrics = exporter
+	http.Handle("/metrics", exporter)
+*/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the request latency histogram
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// families describes each metric in the order they are written
+var families = []struct {
+	name, kind, help string
+}{
+	{"attspeech_requests_total", "counter", "Requests sent to the AT&T Speech API by resource and HTTP status, 0 when no response was received."},
+	{"attspeech_request_duration_seconds", "histogram", "Latency of requests to the AT&T Speech API by resource and HTTP status."},
+	{"attspeech_api_errors_total", "counter", "Errors returned by the AT&T Speech API by resource and MessageId."},
+	{"attspeech_token_refreshes_total", "counter", "OAuth tokens fetched by scope and result."},
+	{"attspeech_audio_uploaded_bytes_total", "counter", "Bytes of audio uploaded for recognition by resource."},
+	{"attspeech_audio_processed_seconds_total", "counter", "Seconds of WAV audio recognized or synthesized by resource."},
+	{"attspeech_characters_synthesized_total", "counter", "Characters of text sent to Text to Speech."},
+}
+
+// series identifies a metric and its label values
+type series struct {
+	name   string
+	labels string
+}
+
+// histogram counts observations into cumulative buckets
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+/*
+Prometheus collects the measurements of a client and serves them to a
+Prometheus scraper. It implements attspeech.Metrics and http.Handler, and
+is safe for concurrent use.
+*/
+type Prometheus struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	counters   map[series]float64
+	histograms map[series]*histogram
+}
+
+// NewPrometheus creates an exporter with the DefaultBuckets
+func NewPrometheus() *Prometheus {
+	return NewPrometheusBuckets(DefaultBuckets)
+}
+
+// NewPrometheusBuckets creates an exporter with the given latency buckets, in seconds
+func NewPrometheusBuckets(buckets []float64) *Prometheus {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &Prometheus{
+		buckets:    sorted,
+		counters:   make(map[series]float64),
+		histograms: make(map[series]*histogram),
+	}
+}
+
+// ObserveRequest counts a request and records its latency
+func (p *Prometheus) ObserveRequest(resource string, status int, elapsed time.Duration) {
+	labels := labelPairs("resource", resource, "status", strconv.Itoa(status))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counters[series{"attspeech_requests_total", labels}]++
+	key := series{"attspeech_request_duration_seconds", labels}
+	h := p.histograms[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.histograms[key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveAPIError counts an error returned by the API
+func (p *Prometheus) ObserveAPIError(resource string, messageID string) {
+	p.add("attspeech_api_errors_total", labelPairs("resource", resource, "message_id", messageID), 1)
+}
+
+// ObserveTokenRefresh counts fetching a token, by whether it succeeded
+func (p *Prometheus) ObserveTokenRefresh(scope string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	p.add("attspeech_token_refreshes_total", labelPairs("scope", scope, "result", result), 1)
+}
+
+// ObserveAudioUploaded adds to the bytes of audio uploaded
+func (p *Prometheus) ObserveAudioUploaded(resource string, bytes int) {
+	p.add("attspeech_audio_uploaded_bytes_total", labelPairs("resource", resource), float64(bytes))
+}
+
+// ObserveAudioProcessed adds to the seconds of audio processed
+func (p *Prometheus) ObserveAudioProcessed(resource string, duration time.Duration) {
+	p.add("attspeech_audio_processed_seconds_total", labelPairs("resource", resource), duration.Seconds())
+}
+
+// ObserveCharactersSynthesized adds to the characters synthesized
+func (p *Prometheus) ObserveCharactersSynthesized(characters int) {
+	p.add("attspeech_characters_synthesized_total", "", float64(characters))
+}
+
+// add increases a counter
+func (p *Prometheus) add(name string, labels string, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counters[series{name, labels}] += value
+}
+
+// ServeHTTP writes the metrics for a Prometheus scrape
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	out := bufio.NewWriter(counter)
+	p.mu.Lock()
+	for _, family := range families {
+		keys := p.seriesOf(family.name)
+		if len(keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+		for _, key := range keys {
+			if family.kind != "histogram" {
+				fmt.Fprintf(out, "%s%s %s\n", key.name, braces(key.labels), formatValue(p.counters[key]))
+				continue
+			}
+			h := p.histograms[key]
+			for i, bound := range p.buckets {
+				fmt.Fprintf(out, "%s_bucket%s %d\n", key.name, braces(joinLabels(key.labels, labelPairs("le", formatValue(bound)))), h.counts[i])
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", key.name, braces(joinLabels(key.labels, `le="+Inf"`)), h.count)
+			fmt.Fprintf(out, "%s_sum%s %s\n", key.name, braces(key.labels), formatValue(h.sum))
+			fmt.Fprintf(out, "%s_count%s %d\n", key.name, braces(key.labels), h.count)
+		}
+	}
+	p.mu.Unlock()
+	err := out.Flush()
+	return counter.n, err
+}
+
+// seriesOf returns the series of a metric sorted by their labels
+func (p *Prometheus) seriesOf(name string) []series {
+	keys := []series{}
+	for key := range p.counters {
+		if key.name == name {
+			keys = append(keys, key)
+		}
+	}
+	for key := range p.histograms {
+		if key.name == name {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].labels < keys[j].labels })
+	return keys
+}
+
+// labelPairs formats alternating label names and values as name="value" pairs
+func labelPairs(pairs ...string) string {
+	formatted := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		formatted = append(formatted, pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`)
+	}
+	return strings.Join(formatted, ",")
+}
+
+// joinLabels joins two formatted label lists
+func joinLabels(a string, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+// braces wraps formatted labels in braces, or returns nothing when there are none
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// escapeLabel escapes a label value as the exposition format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatValue formats a sample value, using the exposition format's spelling of infinity
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written through it for WriteTo
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes to the underlying writer and counts the bytes written
+func (writer *countingWriter) Write(data []byte) (int, error) {
+	n, err := writer.w.Write(data)
+	writer.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	Convey("Exporting metrics to Prometheus", t, func() {
+		p := NewPrometheusBuckets([]float64{1, 0.1})
+		output := func() string {
+			buffer := &bytes.Buffer{}
+			n, err := p.WriteTo(buffer)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, buffer.Len())
+			return buffer.String()
+		}
+
+		Convey("Should write nothing before anything is observed", func() {
+			So(output(), ShouldBeBlank)
+		})
+		Convey("Should count requests and record their latency in cumulative buckets", func() {
+			p.ObserveRequest("/speech/v3/speechToText", 200, 50*time.Millisecond)
+			p.ObserveRequest("/speech/v3/speechToText", 200, 500*time.Millisecond)
+			p.ObserveRequest("/speech/v3/speechToText", 400, 2*time.Second)
+			text := output()
+			So(text, ShouldContainSubstring, "# TYPE attspeech_requests_total counter\n"+
+				`attspeech_requests_total{resource="/speech/v3/speechToText",status="200"} 2`+"\n"+
+				`attspeech_requests_total{resource="/speech/v3/speechToText",status="400"} 1`+"\n")
+			So(text, ShouldContainSubstring, "# TYPE attspeech_request_duration_seconds histogram\n"+
+				`attspeech_request_duration_seconds_bucket{resource="/speech/v3/speechToText",status="200",le="0.1"} 1`+"\n"+
+				`attspeech_request_duration_seconds_bucket{resource="/speech/v3/speechToText",status="200",le="1"} 2`+"\n"+
+				`attspeech_request_duration_seconds_bucket{resource="/speech/v3/speechToText",status="200",le="+Inf"} 2`+"\n"+
+				`attspeech_request_duration_seconds_sum{resource="/speech/v3/speechToText",status="200"} 0.55`+"\n"+
+				`attspeech_request_duration_seconds_count{resource="/speech/v3/speechToText",status="200"} 2`+"\n")
+			So(text, ShouldContainSubstring, `attspeech_request_duration_seconds_bucket{resource="/speech/v3/speechToText",status="400",le="1"} 0`)
+		})
+		Convey("Should count errors, tokens and audio", func() {
+			p.ObserveAPIError("/speech/v3/textToSpeech", "POL0001")
+			p.ObserveTokenRefresh("TTS", nil)
+			p.ObserveTokenRefresh("TTS", errors.New("foo"))
+			p.ObserveAudioUploaded("/speech/v3/speechToText", 16044)
+			p.ObserveAudioProcessed("/speech/v3/speechToText", 1500*time.Millisecond)
+			p.ObserveCharactersSynthesized(12)
+			p.ObserveCharactersSynthesized(30)
+			text := output()
+			So(text, ShouldContainSubstring, `attspeech_api_errors_total{resource="/speech/v3/textToSpeech",message_id="POL0001"} 1`)
+			So(text, ShouldContainSubstring, `attspeech_token_refreshes_total{scope="TTS",result="failure"} 1`+"\n"+
+				`attspeech_token_refreshes_total{scope="TTS",result="success"} 1`)
+			So(text, ShouldContainSubstring, `attspeech_audio_uploaded_bytes_total{resource="/speech/v3/speechToText"} 16044`)
+			So(text, ShouldContainSubstring, `attspeech_audio_processed_seconds_total{resource="/speech/v3/speechToText"} 1.5`)
+			So(text, ShouldContainSubstring, "# HELP attspeech_characters_synthesized_total ")
+			So(text, ShouldContainSubstring, "\nattspeech_characters_synthesized_total 42\n")
+		})
+		Convey("Should escape label values", func() {
+			p.ObserveAPIError("/speech", "a\"b\\c\nd")
+			So(output(), ShouldContainSubstring, `message_id="a\"b\\c\nd"`)
+		})
+		Convey("Should serve the metrics over HTTP", func() {
+			p.ObserveCharactersSynthesized(1)
+			recorder := httptest.NewRecorder()
+			p.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			So(recorder.Header().Get("Content-Type"), ShouldEqual, ContentType)
+			So(strings.HasSuffix(recorder.Body.String(), "attspeech_characters_synthesized_total 1\n"), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+package attspeech
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordedMetrics keeps every observation made through the Metrics interface
+type recordedMetrics struct {
+	mu         sync.Mutex
+	requests   []string
+	errors     []string
+	tokens     []string
+	uploaded   int
+	processed  time.Duration
+	characters int
+}
+
+func (m *recordedMetrics) ObserveRequest(resource string, status int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, resource+" "+http.StatusText(status))
+}
+
+func (m *recordedMetrics) ObserveAPIError(resource string, messageID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, resource+" "+messageID)
+}
+
+func (m *recordedMetrics) ObserveTokenRefresh(scope string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = append(m.tokens, scope)
+}
+
+func (m *recordedMetrics) ObserveAudioUploaded(resource string, bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploaded += bytes
+}
+
+func (m *recordedMetrics) ObserveAudioProcessed(resource string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed += duration
+}
+
+func (m *recordedMetrics) ObserveCharactersSynthesized(characters int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.characters += characters
+}
+
+func TestMetrics(t *testing.T) {
+	Convey("Recording metrics", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			switch {
+			case strings.Contains(req.RequestURI, OauthResource):
+				w.Write(oauthJSON())
+			case req.Header.Get("X-Speechcontext") == "raise/error":
+				w.WriteHeader(400)
+				w.Write(contentTypeErrorJSON())
+			case strings.Contains(req.RequestURI, TTSResource):
+				w.Write(wavBytes(1, 1, 8000, 16, 4000))
+			default:
+				w.Write(recognitionJSON())
+			}
+		}))
+		defer ts.Close()
+		metrics := &recordedMetrics{}
+		client := New("foo", "bar", ts.URL)
+		client.Metrics = metrics
+		So(client.SetAuthTokens(), ShouldBeNil)
+
+		Convey("Should record token refreshes", func() {
+			So(metrics.tokens, ShouldResemble, []string{"SPEECH", "STTC", "TTS"})
+			So(metrics.requests, ShouldResemble, []string{OauthResource + " OK", OauthResource + " OK", OauthResource + " OK"})
+		})
+		Convey("Should record recognitions and the audio uploaded", func() {
+			wav := wavBytes(1, 1, 8000, 16, 16000)
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.Data = bytes.NewBuffer(wav)
+			_, err := client.SpeechToText(apiRequest)
+			So(err, ShouldBeNil)
+			So(metrics.requests[3], ShouldEqual, STTResource+" OK")
+			So(metrics.uploaded, ShouldEqual, len(wav))
+			So(metrics.processed, ShouldEqual, 2*time.Second)
+		})
+		Convey("Should record synthesis and the audio returned", func() {
+			apiRequest := client.NewAPIRequest(client.TTSResource)
+			apiRequest.Text = "héllo world"
+			_, err := client.TextToSpeech(apiRequest)
+			So(err, ShouldBeNil)
+			So(metrics.requests[3], ShouldEqual, TTSResource+" OK")
+			So(metrics.characters, ShouldEqual, 11)
+			So(metrics.processed, ShouldEqual, 500*time.Millisecond)
+		})
+		Convey("Should record errors by MessageId", func() {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 1, 8000, 16, 800))
+			apiRequest.XSpeechContext = "raise/error"
+			_, err := client.SpeechToText(apiRequest)
+			So(err, ShouldNotBeNil)
+			So(metrics.requests[3], ShouldEqual, STTResource+" Bad Request")
+			So(metrics.errors, ShouldResemble, []string{STTResource + " SVC0002"})
+			serviceError := &ServiceError{}
+			So(errors.As(err, &serviceError), ShouldBeTrue)
+			So(serviceError.StatusCode, ShouldEqual, 400)
+			So(serviceError.MessageID, ShouldEqual, "SVC0002")
+		})
+		Convey("Should record requests that received no response", func() {
+			ts.Close()
+			So(client.SetAuthTokens(), ShouldNotBeNil)
+			So(metrics.requests[3], ShouldEqual, OauthResource+" ")
+			So(metrics.tokens[3], ShouldEqual, "SPEECH")
+		})
+	})
+}
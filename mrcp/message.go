@@ -0,0 +1,262 @@
+/*
+Package mrcp is an MRCPv2 (RFC 6787) speech resource server, letting IVR
+platforms use the AT&T Speech API as their speechsynth and speechrecog
+resources. SPEAK is synthesized with TextToSpeech and RECOGNIZE is
+recognized with SpeechToTextCustom against the grammars defined for it.
+
+Only the MRCPv2 control channel is implemented. Platforms set channels up
+with SIP and carry their audio over RTP, so the server asks the platform
+for each channel's Media to play and record through:
+
+	server := &mrcp.Server{Client: client, Media: rtpSessions.Lookup}
+	log.Fatal(server.ListenAndServe(":1544"))
+*/
+package mrcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the protocol version every message starts with
+const Version = "MRCP/2.0"
+
+// Kind is the kind of an MRCPv2 message
+type Kind int
+
+const (
+	// Request is a method sent by the client
+	Request Kind = iota
+	// Response answers a request
+	Response
+	// Event reports progress on a request in progress
+	Event
+)
+
+// Request states
+const (
+	Complete   = "COMPLETE"
+	InProgress = "IN-PROGRESS"
+	Pending    = "PENDING"
+)
+
+// Status codes used in responses
+const (
+	StatusSuccess              = 200
+	StatusMethodNotAllowed     = 401
+	StatusInvalidState         = 402
+	StatusIllegalHeaderValue   = 404
+	StatusResourceNotAllocated = 405
+	StatusMissingHeader        = 406
+	StatusFailed               = 407
+	StatusUnsupportedEntity    = 408
+)
+
+// Header fields the server reads or sets
+const (
+	HeaderChannelIdentifier  = "Channel-Identifier"
+	HeaderContentType        = "Content-Type"
+	HeaderContentID          = "Content-Id"
+	HeaderContentLength      = "Content-Length"
+	HeaderCompletionCause    = "Completion-Cause"
+	HeaderCompletionReason   = "Completion-Reason"
+	HeaderActiveRequestIDs   = "Active-Request-Id-List"
+	HeaderVoiceName          = "Voice-Name"
+	HeaderSpeechLanguage     = "Speech-Language"
+	HeaderNoInputTimeout     = "No-Input-Timeout"
+	HeaderRecognitionTimeout = "Recognition-Timeout"
+)
+
+/*
+Message is an MRCPv2 request, response or event. Name is the method of a
+request or the name of an event; StatusCode is only set on responses and
+State only on responses and events.
+*/
+type Message struct {
+	Kind       Kind
+	Name       string
+	RequestID  uint32
+	StatusCode int
+	State      string
+	Header     textproto.MIMEHeader
+	Body       []byte
+}
+
+// ChannelID returns the Channel-Identifier header, e.g. 32AECB23433801@speechsynth
+func (message *Message) ChannelID() string {
+	return message.Header.Get(HeaderChannelIdentifier)
+}
+
+// Resource returns the resource type of the channel, e.g. speechsynth
+func (message *Message) Resource() string {
+	_, resource, _ := strings.Cut(message.ChannelID(), "@")
+	return resource
+}
+
+// DefaultMaxMessageSize is the largest message ReadMessage accepts when given no limit
+const DefaultMaxMessageSize = 1 << 20
+
+/*
+ReadMessage reads the next message from r. The headers and body are read
+within the start line's message-length, using Content-Length for the
+body. Messages whose start line or message-length is over maxSize are
+rejected before the rest is read, DefaultMaxMessageSize being the limit
+when maxSize is not positive.
+
+	message, err := mrcp.ReadMessage(reader, 64<<10)
+*/
+func ReadMessage(r *bufio.Reader, maxSize int) (*Message, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	line, err := readLine(r, maxSize)
+	if err != nil {
+		if err == errLineTooLong {
+			return nil, errors.New("mrcp: start line is over the limit of " + strconv.Itoa(maxSize))
+		}
+		if err == io.EOF && line == "" {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields) > 5 || fields[0] != Version {
+		return nil, errors.New("mrcp: invalid start line " + strconv.Quote(strings.TrimSpace(line)))
+	}
+	length, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, errors.New("mrcp: invalid message length " + strconv.Quote(fields[1]))
+	}
+	if length > maxSize {
+		return nil, errors.New("mrcp: message length " + fields[1] + " is over the limit of " + strconv.Itoa(maxSize))
+	}
+	if length < len(line) {
+		return nil, errors.New("mrcp: message length " + fields[1] + " is shorter than the start line")
+	}
+	// The headers and body cannot be read past the declared length, however long the peer makes them
+	rest := bufio.NewReader(io.LimitReader(r, int64(length-len(line))))
+
+	message := &Message{}
+	id := fields[3]
+	switch {
+	case len(fields) == 4:
+		message.Kind = Request
+		message.Name = fields[2]
+	case isDigits(fields[2]):
+		message.Kind = Response
+		id = fields[2]
+		if message.StatusCode, err = strconv.Atoi(fields[3]); err != nil {
+			return nil, errors.New("mrcp: invalid status code " + strconv.Quote(fields[3]))
+		}
+		message.State = fields[4]
+	default:
+		message.Kind = Event
+		message.Name = fields[2]
+		message.State = fields[4]
+	}
+	requestID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errors.New("mrcp: invalid request id " + strconv.Quote(id))
+	}
+	message.RequestID = uint32(requestID)
+
+	if message.Header, err = textproto.NewReader(rest).ReadMIMEHeader(); err != nil {
+		return nil, errors.New("mrcp: invalid header: " + err.Error())
+	}
+	if value := message.Header.Get(HeaderContentLength); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 || size > length {
+			return nil, errors.New("mrcp: invalid content length " + strconv.Quote(value))
+		}
+		message.Body = make([]byte, size)
+		if _, err := io.ReadFull(rest, message.Body); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	return message, nil
+}
+
+// errLineTooLong is returned by readLine for lines over its limit
+var errLineTooLong = errors.New("mrcp: line too long")
+
+// readLine reads a line of at most maxSize bytes, including its line break
+func readLine(r *bufio.Reader, maxSize int) (string, error) {
+	line := []byte{}
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxSize {
+			return "", errLineTooLong
+		}
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
+// lineBreaks replaces the line breaks header values cannot hold
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// Bytes encodes the message, setting Content-Length and the start line's message-length
+func (message *Message) Bytes() []byte {
+	var rest string
+	id := strconv.FormatUint(uint64(message.RequestID), 10)
+	switch message.Kind {
+	case Request:
+		rest = message.Name + " " + id
+	case Response:
+		rest = id + " " + strconv.Itoa(message.StatusCode) + " " + message.State
+	case Event:
+		rest = message.Name + " " + id + " " + message.State
+	}
+
+	headers := &bytes.Buffer{}
+	if channelID := message.ChannelID(); channelID != "" {
+		headers.WriteString(HeaderChannelIdentifier + ": " + channelID + "\r\n")
+	}
+	names := make([]string, 0, len(message.Header))
+	for name := range message.Header {
+		if name != HeaderChannelIdentifier && name != HeaderContentLength {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range message.Header[name] {
+			// A line break in a value, e.g. from an error's text, would end the header early
+			headers.WriteString(name + ": " + lineBreaks.Replace(value) + "\r\n")
+		}
+	}
+	if len(message.Body) > 0 {
+		headers.WriteString(HeaderContentLength + ": " + strconv.Itoa(len(message.Body)) + "\r\n")
+	}
+	headers.WriteString("\r\n")
+
+	// message-length counts the whole message, including its own digits
+	size := len(Version) + len(rest) + 4 + headers.Len() + len(message.Body)
+	length := size
+	for length != size+len(strconv.Itoa(length)) {
+		length = size + len(strconv.Itoa(length))
+	}
+	encoded := &bytes.Buffer{}
+	encoded.WriteString(Version + " " + strconv.Itoa(length) + " " + rest + "\r\n")
+	encoded.Write(headers.Bytes())
+	encoded.Write(message.Body)
+	return encoded.Bytes()
+}
+
+// isDigits reports whether value is a non-empty string of digits
+func isDigits(value string) bool {
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
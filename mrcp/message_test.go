@@ -0,0 +1,101 @@
+package mrcp
+
+import (
+	"bufio"
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	Convey("Encoding and decoding MRCPv2 messages", t, func() {
+		Convey("Should encode requests with their message length", func() {
+			request := &Message{Kind: Request, Name: "SPEAK", RequestID: 543257, Body: []byte("Hello"), Header: textproto.MIMEHeader{
+				HeaderChannelIdentifier: {"32AECB23433802@speechsynth"},
+				HeaderContentType:       {"text/plain"},
+			}}
+			encoded := request.Bytes()
+			So(string(encoded), ShouldEqual, "MRCP/2.0 127 SPEAK 543257\r\n"+
+				"Channel-Identifier: 32AECB23433802@speechsynth\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nHello")
+			So(len(encoded), ShouldEqual, 127)
+
+			decoded, err := ReadMessage(bufio.NewReader(bytes.NewReader(encoded)), 0)
+			So(err, ShouldBeNil)
+			So(decoded.Kind, ShouldEqual, Request)
+			So(decoded.Name, ShouldEqual, "SPEAK")
+			So(decoded.RequestID, ShouldEqual, 543257)
+			So(decoded.Resource(), ShouldEqual, Synthesizer)
+			So(string(decoded.Body), ShouldEqual, "Hello")
+		})
+		Convey("Should count the digits of the message length", func() {
+			for size := 80; size < 120; size++ {
+				message := &Message{Kind: Event, Name: "SPEAK-COMPLETE", RequestID: 1, State: Complete, Body: bytes.Repeat([]byte("a"), size)}
+				encoded := message.Bytes()
+				length := strings.Fields(string(encoded))[1]
+				So(length, ShouldEqual, strconv.Itoa(len(encoded)))
+			}
+		})
+		Convey("Should decode responses and events", func() {
+			reader := bufio.NewReader(strings.NewReader("MRCP/2.0 86 543257 200 IN-PROGRESS\r\nChannel-Identifier: 32AECB23433802@speechsynth\r\n\r\n" +
+				"MRCP/2.0 125 SPEAK-COMPLETE 543257 COMPLETE\r\nChannel-Identifier: 32AECB23433802@speechsynth\r\nCompletion-Cause: 000 normal\r\n\r\n"))
+			response, err := ReadMessage(reader, 0)
+			So(err, ShouldBeNil)
+			So(response.Kind, ShouldEqual, Response)
+			So(response.RequestID, ShouldEqual, 543257)
+			So(response.StatusCode, ShouldEqual, 200)
+			So(response.State, ShouldEqual, InProgress)
+			event, err := ReadMessage(reader, 0)
+			So(err, ShouldBeNil)
+			So(event.Kind, ShouldEqual, Event)
+			So(event.Name, ShouldEqual, "SPEAK-COMPLETE")
+			So(event.Header.Get(HeaderCompletionCause), ShouldEqual, "000 normal")
+			_, err = ReadMessage(reader, 0)
+			So(err, ShouldEqual, io.EOF)
+		})
+		Convey("Should reject invalid messages", func() {
+			_, err := ReadMessage(bufio.NewReader(strings.NewReader("SIP/2.0 200 OK\r\n\r\n")), 0)
+			So(err.Error(), ShouldEqual, `mrcp: invalid start line "SIP/2.0 200 OK"`)
+			_, err = ReadMessage(bufio.NewReader(strings.NewReader("MRCP/2.0 60 SPEAK 1\r\nContent-Length: 100\r\n\r\n")), 0)
+			So(err.Error(), ShouldEqual, `mrcp: invalid content length "100"`)
+			_, err = ReadMessage(bufio.NewReader(strings.NewReader("MRCP/2.0 60 SPEAK 1\r\nContent-Length: 10\r\n\r\nabc")), 0)
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		})
+		Convey("Should reject messages over the size limit before reading them", func() {
+			_, err := ReadMessage(bufio.NewReader(strings.NewReader("MRCP/2.0 2000000000 SPEAK 1\r\nContent-Length: 1999999000\r\n\r\n")), 0)
+			So(err.Error(), ShouldEqual, "mrcp: message length 2000000000 is over the limit of 1048576")
+			encoded := (&Message{Kind: Request, Name: "SPEAK", RequestID: 1, Body: []byte("Hello")}).Bytes()
+			_, err = ReadMessage(bufio.NewReader(bytes.NewReader(encoded)), len(encoded)-1)
+			So(err.Error(), ShouldContainSubstring, "is over the limit of")
+			_, err = ReadMessage(bufio.NewReader(bytes.NewReader(encoded)), len(encoded))
+			So(err, ShouldBeNil)
+		})
+		Convey("Should not read headers or start lines past the size limit", func() {
+			big := strings.NewReader("MRCP/2.0 90 SPEAK 1\r\nX-Big: " + strings.Repeat("a", 20<<20) + "\r\n\r\n")
+			_, err := ReadMessage(bufio.NewReader(big), 100)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "mrcp: invalid header")
+			So(big.Len(), ShouldBeGreaterThan, 20<<20-8192)
+
+			long := strings.NewReader("MRCP/2.0 " + strings.Repeat("9", 20<<20) + " SPEAK 1\r\n\r\n")
+			_, err = ReadMessage(bufio.NewReader(long), 100)
+			So(err.Error(), ShouldEqual, "mrcp: start line is over the limit of 100")
+			So(long.Len(), ShouldBeGreaterThan, 20<<20-8192)
+
+			_, err = ReadMessage(bufio.NewReader(strings.NewReader("MRCP/2.0 10 SPEAK 1\r\n\r\n")), 100)
+			So(err.Error(), ShouldEqual, "mrcp: message length 10 is shorter than the start line")
+		})
+		Convey("Should replace line breaks in header values", func() {
+			message := &Message{Kind: Response, RequestID: 1, StatusCode: 407, State: Complete, Header: textproto.MIMEHeader{
+				HeaderCompletionReason: {"bad grammar\r\nChannel-Identifier: other@speechsynth\nline"},
+			}}
+			decoded, err := ReadMessage(bufio.NewReader(bytes.NewReader(message.Bytes())), 0)
+			So(err, ShouldBeNil)
+			So(decoded.Header.Get(HeaderCompletionReason), ShouldEqual, "bad grammar Channel-Identifier: other@speechsynth line")
+			So(decoded.ChannelID(), ShouldBeBlank)
+		})
+	})
+}
@@ -0,0 +1,250 @@
+package mrcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"github.com/jsgoecke/attspeech"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Completion causes of DEFINE-GRAMMAR and RECOGNITION-COMPLETE
+const (
+	RecognitionSuccess        = "000 success"
+	RecognitionNoMatch        = "001 no-match"
+	RecognitionNoInputTimeout = "002 no-input-timeout"
+	RecognitionGrammarFailure = "004 grammar-load-failure"
+	RecognitionError          = "006 recognizer-error"
+)
+
+// Content types of grammars and results
+const (
+	ContentTypeSRGS    = "application/srgs+xml"
+	ContentTypeURIList = "text/uri-list"
+	ContentTypeNLSML   = "application/nlsml+xml"
+)
+
+// nlsmlResult is the NLSML body of a RECOGNITION-COMPLETE event
+type nlsmlResult struct {
+	XMLName        xml.Name              `xml:"result"`
+	Interpretation []nlsmlInterpretation `xml:"interpretation"`
+}
+
+// nlsmlInterpretation is one hypothesis of a recognition
+type nlsmlInterpretation struct {
+	Grammar    string     `xml:"grammar,attr,omitempty"`
+	Confidence string     `xml:"confidence,attr"`
+	Instance   string     `xml:"instance"`
+	Input      nlsmlInput `xml:"input"`
+}
+
+// nlsmlInput is the text recognized for a hypothesis
+type nlsmlInput struct {
+	Mode string `xml:"mode,attr"`
+	Text string `xml:",chardata"`
+}
+
+// recognizeSettings holds what RECOGNIZE needs once it is running
+type recognizeSettings struct {
+	grammarID          string
+	grammar            string
+	noInputTimeout     time.Duration
+	recognitionTimeout time.Duration
+}
+
+// handleRecognizer handles the methods of a speechrecog channel
+func (c *conn) handleRecognizer(ch *channel, request *Message) {
+	switch request.Name {
+	case "DEFINE-GRAMMAR":
+		c.defineGrammar(ch, request)
+	case "RECOGNIZE":
+		c.recognize(ch, request)
+	case "START-INPUT-TIMERS":
+		// The no input timer always starts with the recording
+		c.respond(request, StatusSuccess, Complete, nil)
+	default:
+		c.respond(request, StatusMethodNotAllowed, Complete, nil)
+	}
+}
+
+// defineGrammar stores an SRGS grammar under its Content-Id, for RECOGNIZE to refer to as session:<id>
+func (c *conn) defineGrammar(ch *channel, request *Message) {
+	id := request.Header.Get(HeaderContentID)
+	if id == "" || len(request.Body) == 0 {
+		c.respond(request, StatusMissingHeader, Complete, textproto.MIMEHeader{HeaderCompletionReason: {"DEFINE-GRAMMAR requires a grammar with a Content-Id"}})
+		return
+	}
+	if mediaType(request.Header.Get(HeaderContentType)) != ContentTypeSRGS {
+		c.respond(request, StatusUnsupportedEntity, Complete, textproto.MIMEHeader{HeaderCompletionCause: {RecognitionGrammarFailure}})
+		return
+	}
+	ch.grammars[id] = string(request.Body)
+	c.respond(request, StatusSuccess, Complete, textproto.MIMEHeader{HeaderCompletionCause: {RecognitionSuccess}})
+}
+
+/*
+recognize records the caller and recognizes the recording with the
+request's grammar: an inline SRGS body, defined for later requests when it
+has a Content-Id, or a text/uri-list of session:<id> grammars defined
+earlier, of which the first is used. Only one RECOGNIZE may be in progress
+on a channel.
+*/
+func (c *conn) recognize(ch *channel, request *Message) {
+	settings := &recognizeSettings{}
+	switch mediaType(request.Header.Get(HeaderContentType)) {
+	case ContentTypeSRGS:
+		settings.grammar = string(request.Body)
+		if settings.grammarID = request.Header.Get(HeaderContentID); settings.grammarID != "" {
+			ch.grammars[settings.grammarID] = settings.grammar
+		}
+	case ContentTypeURIList:
+		for _, uri := range strings.Fields(string(request.Body)) {
+			if grammar, ok := ch.grammars[strings.TrimPrefix(uri, "session:")]; ok {
+				settings.grammarID, settings.grammar = strings.TrimPrefix(uri, "session:"), grammar
+				break
+			}
+		}
+	}
+	if settings.grammar == "" {
+		c.respond(request, StatusSuccess, Complete, textproto.MIMEHeader{
+			HeaderCompletionCause:  {RecognitionGrammarFailure},
+			HeaderCompletionReason: {"RECOGNIZE requires an SRGS grammar or the session URI of one defined"},
+		})
+		return
+	}
+	var err error
+	if settings.noInputTimeout, err = ch.timeout(request, HeaderNoInputTimeout, DefaultNoInputTimeout); err == nil {
+		settings.recognitionTimeout, err = ch.timeout(request, HeaderRecognitionTimeout, DefaultRecognitionTimeout)
+	}
+	if err != nil {
+		c.respond(request, StatusIllegalHeaderValue, Complete, textproto.MIMEHeader{HeaderCompletionReason: {err.Error()}})
+		return
+	}
+
+	active, _, ok := ch.start(c.ctx, request.RequestID, false)
+	if !ok {
+		c.respond(request, StatusInvalidState, Complete, nil)
+		return
+	}
+	c.respond(request, StatusSuccess, InProgress, nil)
+	go func() {
+		defer ch.finish(active)
+		cause, result, err := c.recognizeRecording(ch, active, settings)
+		if stopped, _ := ch.stopped(active); stopped {
+			return
+		}
+		header := textproto.MIMEHeader{HeaderCompletionCause: {cause}}
+		if err != nil {
+			header.Set(HeaderCompletionReason, err.Error())
+		}
+		if result != nil {
+			header.Set(HeaderContentType, ContentTypeNLSML)
+		}
+		c.event(request, "RECOGNITION-COMPLETE", Complete, header, result)
+	}()
+}
+
+/*
+recognizeRecording streams the channel's recording to the API. No input
+for noInputTimeout completes without uploading anything, and the
+recording is cut off after recognitionTimeout.
+*/
+func (c *conn) recognizeRecording(ch *channel, active *activeRequest, settings *recognizeSettings) (string, []byte, error) {
+	recording, contentType, err := ch.media.Record(active.ctx)
+	if err != nil {
+		return RecognitionError, nil, err
+	}
+	defer recording.Close()
+
+	// The recording is copied through a pipe, so a timeout can end it without waiting on a blocked read
+	reader, writer := io.Pipe()
+	var received atomic.Bool
+	noInput := time.AfterFunc(settings.noInputTimeout, func() {
+		if !received.Load() {
+			writer.Close()
+		}
+	})
+	defer noInput.Stop()
+	timeout := time.AfterFunc(settings.recognitionTimeout, func() { writer.Close() })
+	defer timeout.Stop()
+	stop := context.AfterFunc(active.ctx, func() { writer.CloseWithError(active.ctx.Err()) })
+	defer stop()
+	go func() {
+		_, err := io.Copy(writer, &notifyingReader{r: recording, received: &received})
+		writer.CloseWithError(err)
+	}()
+	defer reader.Close()
+
+	audio := bufio.NewReader(reader)
+	if _, err := audio.Peek(1); err != nil {
+		if err == io.EOF {
+			return RecognitionNoInputTimeout, nil, nil
+		}
+		return RecognitionError, nil, err
+	}
+	noInput.Stop()
+
+	apiRequest := c.server.Client.NewAPIRequest(c.server.Client.STTCResource)
+	apiRequest.ContentType = contentType
+	result, err := c.server.Client.SpeechToTextCustomStream(active.ctx, apiRequest, audio, settings.grammar, "")
+	if err != nil {
+		return RecognitionError, nil, err
+	}
+	body, ok := nlsml(result, settings.grammarID)
+	if !ok {
+		return RecognitionNoMatch, nil, nil
+	}
+	return RecognitionSuccess, body, nil
+}
+
+// nlsml returns the hypotheses of a recognition as NLSML, and false when nothing was recognized
+func nlsml(recognition *attspeech.Recognition, grammarID string) ([]byte, bool) {
+	if recognition.Recognition.Status != "OK" || len(recognition.Recognition.NBest) == 0 {
+		return nil, false
+	}
+	result := &nlsmlResult{}
+	for _, hypothesis := range recognition.Recognition.NBest {
+		interpretation := nlsmlInterpretation{
+			Confidence: strconv.FormatFloat(float64(hypothesis.Confidence), 'f', 2, 32),
+			Instance:   hypothesis.ResultText,
+			Input:      nlsmlInput{Mode: "speech", Text: hypothesis.ResultText},
+		}
+		if grammarID != "" {
+			interpretation.Grammar = "session:" + grammarID
+		}
+		if outs := hypothesis.NluHypothesis.OutComposite; len(outs) > 0 {
+			interpretation.Instance = outs[0].Out
+		}
+		result.Interpretation = append(result.Interpretation, interpretation)
+	}
+	body, err := xml.Marshal(result)
+	if err != nil {
+		return nil, false
+	}
+	return append([]byte(xml.Header), body...), true
+}
+
+// notifyingReader records when the first audio is read
+type notifyingReader struct {
+	r        io.Reader
+	received *atomic.Bool
+}
+
+// Read reads from the underlying reader, noting any audio read
+func (reader *notifyingReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+	if n > 0 {
+		reader.received.Store(true)
+	}
+	return n, err
+}
+
+// mediaType returns a content type without its parameters, in lower case
+func mediaType(contentType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
@@ -0,0 +1,397 @@
+package mrcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource types served
+const (
+	Synthesizer = "speechsynth"
+	Recognizer  = "speechrecog"
+)
+
+const (
+	// DefaultNoInputTimeout is how long RECOGNIZE waits for audio when No-Input-Timeout is not set
+	DefaultNoInputTimeout = 5 * time.Second
+	// DefaultRecognitionTimeout is how long RECOGNIZE records for when Recognition-Timeout is not set
+	DefaultRecognitionTimeout = 10 * time.Second
+)
+
+// PlayFormat is the format of the WAV audio given to Media.Play, 8 kHz signed linear
+var PlayFormat = audio.Format{Encoding: audio.PCM, SampleRate: 8000, Channels: 1, BitsPerSample: 16}
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close
+var ErrServerClosed = errors.New("mrcp: server closed")
+
+/*
+Media carries a channel's audio, set up by the platform's SIP and RTP
+stack. Play sends synthesized speech to the caller; Record returns the
+caller's audio from the moment recognition starts, in a format the API
+accepts such as audio/wav or audio/amr. The recording ends when the
+reader returns io.EOF, or when the server closes it after a timeout.
+*/
+type Media interface {
+	Play(ctx context.Context, wav []byte) error
+	Record(ctx context.Context) (recording io.ReadCloser, contentType string, err error)
+}
+
+// Server accepts MRCPv2 control connections
+type Server struct {
+	Client *attspeech.Client
+	// Media returns the media of a channel, by its Channel-Identifier
+	Media func(channelID string) (Media, error)
+	// Voice is the voice SPEAK uses when no Voice-Name is given, the API's default when empty
+	Voice string
+	// MaxMessageSize is the largest request accepted, DefaultMaxMessageSize when 0
+	MaxMessageSize int
+	// Logger receives the errors connections end with, slog.Default() when nil
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	listeners []net.Listener
+}
+
+// conn is a control connection, carrying any number of channels
+type conn struct {
+	server   *Server
+	ctx      context.Context
+	netConn  net.Conn
+	writeMu  sync.Mutex
+	channels map[string]*channel
+}
+
+// channel is the state of a synthesizer or recognizer resource
+type channel struct {
+	id    string
+	media Media
+	// params are the defaults set with SET-PARAMS
+	params textproto.MIMEHeader
+	// grammars are the grammars defined with DEFINE-GRAMMAR, by Content-Id
+	grammars map[string]string
+
+	mu sync.Mutex
+	// requests are the SPEAK or RECOGNIZE requests in progress or queued, in order
+	requests []*activeRequest
+}
+
+// activeRequest is a SPEAK or RECOGNIZE request that has not completed
+type activeRequest struct {
+	id     uint32
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// cause replaces the completion cause when the request was interrupted, e.g. by barge-in
+	cause string
+}
+
+// ListenAndServe listens on the TCP address addr and serves until Close
+func (server *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// Serve handles the connections accepted from listener, each in its own goroutine, until Close
+func (server *Server) Serve(listener net.Listener) error {
+	ctx := server.context()
+	server.mu.Lock()
+	server.listeners = append(server.listeners, listener)
+	server.mu.Unlock()
+	if ctx.Err() != nil {
+		listener.Close()
+		return ErrServerClosed
+	}
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			return err
+		}
+		c := &conn{server: server, ctx: ctx, netConn: netConn, channels: make(map[string]*channel)}
+		go c.serve()
+	}
+}
+
+// Close stops the listeners and ends every connection
+func (server *Server) Close() error {
+	server.context()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.cancel()
+	var err error
+	for _, listener := range server.listeners {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	server.listeners = nil
+	return err
+}
+
+// context returns the context connections run in, canceled by Close
+func (server *Server) context() context.Context {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.ctx == nil {
+		server.ctx, server.cancel = context.WithCancel(context.Background())
+	}
+	return server.ctx
+}
+
+// logger returns the logger for connection errors
+func (server *Server) logger() *slog.Logger {
+	if server.Logger != nil {
+		return server.Logger
+	}
+	return slog.Default()
+}
+
+// serve reads requests until the connection closes, then stops the requests still in progress
+func (c *conn) serve() {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.ctx = ctx
+	defer func() {
+		cancel()
+		c.netConn.Close()
+		for _, ch := range c.channels {
+			ch.wait(ch.stop())
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() { c.netConn.Close() })
+	defer stop()
+
+	reader := bufio.NewReader(c.netConn)
+	for {
+		request, err := ReadMessage(reader, c.server.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				c.server.logger().Warn("MRCP connection failed", "remote", c.netConn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		if request.Kind != Request {
+			continue
+		}
+		c.handle(request)
+	}
+}
+
+// handle dispatches a request to its resource
+func (c *conn) handle(request *Message) {
+	channelID := request.ChannelID()
+	if channelID == "" {
+		c.respond(request, StatusMissingHeader, Complete, nil)
+		return
+	}
+	ch, err := c.channel(channelID)
+	if err != nil {
+		c.respond(request, StatusResourceNotAllocated, Complete, textproto.MIMEHeader{HeaderCompletionReason: {err.Error()}})
+		return
+	}
+	switch request.Name {
+	case "SET-PARAMS":
+		c.setParams(ch, request)
+		return
+	case "GET-PARAMS":
+		c.getParams(ch, request)
+		return
+	case "STOP":
+		stopped := ch.stop()
+		ch.wait(stopped)
+		c.respond(request, StatusSuccess, Complete, activeRequestIDs(stopped))
+		return
+	}
+	switch request.Resource() {
+	case Synthesizer:
+		c.handleSynthesizer(ch, request)
+	case Recognizer:
+		c.handleRecognizer(ch, request)
+	}
+}
+
+// channel returns the state of a channel, fetching its media the first time it is used
+func (c *conn) channel(channelID string) (*channel, error) {
+	if ch, ok := c.channels[channelID]; ok {
+		return ch, nil
+	}
+	_, resource, _ := strings.Cut(channelID, "@")
+	if resource != Synthesizer && resource != Recognizer {
+		return nil, errors.New("unsupported resource " + strconv.Quote(resource))
+	}
+	if c.server.Media == nil {
+		return nil, errors.New("no media for channel " + channelID)
+	}
+	media, err := c.server.Media(channelID)
+	if err != nil {
+		return nil, err
+	}
+	ch := &channel{id: channelID, media: media, params: textproto.MIMEHeader{}, grammars: make(map[string]string)}
+	c.channels[channelID] = ch
+	return ch, nil
+}
+
+// setParams stores the request's header fields as the channel's defaults
+func (c *conn) setParams(ch *channel, request *Message) {
+	for name, values := range request.Header {
+		if name != HeaderChannelIdentifier && !strings.HasPrefix(name, "Content-") {
+			ch.params[name] = values
+		}
+	}
+	c.respond(request, StatusSuccess, Complete, nil)
+}
+
+// getParams returns the channel's defaults for the header fields named in the request, or all of them
+func (c *conn) getParams(ch *channel, request *Message) {
+	header := textproto.MIMEHeader{}
+	for name, values := range ch.params {
+		if _, ok := request.Header[name]; ok || len(request.Header) == 1 {
+			header[name] = values
+		}
+	}
+	c.respond(request, StatusSuccess, Complete, header)
+}
+
+// param returns a header field of the request, or the channel's default for it
+func (ch *channel) param(request *Message, name string) string {
+	if value := request.Header.Get(name); value != "" {
+		return value
+	}
+	return ch.params.Get(name)
+}
+
+// timeout returns a timeout header field in milliseconds, or fallback when it is not set
+func (ch *channel) timeout(request *Message, name string, fallback time.Duration) (time.Duration, error) {
+	value := ch.param(request, name)
+	if value == "" {
+		return fallback, nil
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		return 0, errors.New("invalid " + name + " " + strconv.Quote(value))
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+/*
+start queues a request on the channel, returning it with the request it
+must wait for when one is already in progress, and whether it may
+start at all: when queue is false a request already in progress refuses it.
+*/
+func (ch *channel) start(ctx context.Context, id uint32, queue bool) (*activeRequest, *activeRequest, bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	var previous *activeRequest
+	if len(ch.requests) > 0 {
+		if !queue {
+			return nil, nil, false
+		}
+		previous = ch.requests[len(ch.requests)-1]
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	active := &activeRequest{id: id, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	ch.requests = append(ch.requests, active)
+	return active, previous, true
+}
+
+// finish removes a request that has completed or been stopped
+func (ch *channel) finish(active *activeRequest) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	for i, request := range ch.requests {
+		if request == active {
+			ch.requests = append(ch.requests[:i], ch.requests[i+1:]...)
+			break
+		}
+	}
+	active.cancel()
+	close(active.done)
+}
+
+// stop cancels every request in progress or queued, returning them
+func (ch *channel) stop() []*activeRequest {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	stopped := append([]*activeRequest{}, ch.requests...)
+	for _, request := range stopped {
+		request.cancel()
+	}
+	return stopped
+}
+
+// interrupt cancels the request in progress, completing it with cause
+func (ch *channel) interrupt(cause string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(ch.requests) > 0 {
+		ch.requests[0].cause = cause
+		ch.requests[0].cancel()
+	}
+}
+
+// stopped reports whether a request was stopped, and the cause to complete it with when it was interrupted instead
+func (ch *channel) stopped(active *activeRequest) (bool, string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return active.ctx.Err() != nil && active.cause == "", active.cause
+}
+
+// wait waits for requests to finish
+func (ch *channel) wait(requests []*activeRequest) {
+	for _, request := range requests {
+		<-request.done
+	}
+}
+
+// activeRequestIDs returns the Active-Request-Id-List header field for requests
+func activeRequestIDs(requests []*activeRequest) textproto.MIMEHeader {
+	if len(requests) == 0 {
+		return nil
+	}
+	ids := make([]string, len(requests))
+	for i, request := range requests {
+		ids[i] = strconv.FormatUint(uint64(request.id), 10)
+	}
+	return textproto.MIMEHeader{HeaderActiveRequestIDs: {strings.Join(ids, ",")}}
+}
+
+// respond sends the response to a request
+func (c *conn) respond(request *Message, statusCode int, state string, header textproto.MIMEHeader) {
+	c.send(&Message{Kind: Response, RequestID: request.RequestID, StatusCode: statusCode, State: state}, request, header, nil)
+}
+
+// event sends an event for a request
+func (c *conn) event(request *Message, name string, state string, header textproto.MIMEHeader, body []byte) {
+	c.send(&Message{Kind: Event, Name: name, RequestID: request.RequestID, State: state}, request, header, body)
+}
+
+// send writes a message on the request's channel
+func (c *conn) send(message *Message, request *Message, header textproto.MIMEHeader, body []byte) {
+	message.Header = textproto.MIMEHeader{}
+	for name, values := range header {
+		message.Header[name] = values
+	}
+	message.Header.Set(HeaderChannelIdentifier, request.ChannelID())
+	message.Body = body
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.netConn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	c.netConn.Write(message.Bytes())
+}
@@ -0,0 +1,251 @@
+package mrcp
+
+import (
+	"bufio"
+	"context"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMedia plays and records in memory
+type fakeMedia struct {
+	mu     sync.Mutex
+	played chan []byte
+	// blockPlay keeps Play going until it is canceled
+	blockPlay bool
+	// recording is the caller's audio, or nil for a caller who never speaks
+	recording []byte
+}
+
+// Play records the audio played, or blocks until canceled
+func (media *fakeMedia) Play(ctx context.Context, wav []byte) error {
+	media.mu.Lock()
+	blockPlay := media.blockPlay
+	media.mu.Unlock()
+	if blockPlay {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	media.played <- wav
+	return nil
+}
+
+// Record returns the recording, or a reader that waits until it is closed
+func (media *fakeMedia) Record(ctx context.Context) (io.ReadCloser, string, error) {
+	media.mu.Lock()
+	defer media.mu.Unlock()
+	if media.recording == nil {
+		reader, _ := io.Pipe()
+		return reader, "", nil
+	}
+	return io.NopCloser(strings.NewReader(string(media.recording))), audio.ContentTypeWAV, nil
+}
+
+// set changes how the media behaves
+func (media *fakeMedia) set(blockPlay bool, recording []byte) {
+	media.mu.Lock()
+	defer media.mu.Unlock()
+	media.blockPlay, media.recording = blockPlay, recording
+}
+
+// mrcpClient is a test harness speaking MRCPv2 to the server
+type mrcpClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID uint32
+}
+
+// send sends a request on a channel, returning its request id
+func (client *mrcpClient) send(channelID string, method string, header textproto.MIMEHeader, body string) uint32 {
+	client.nextID++
+	if header == nil {
+		header = textproto.MIMEHeader{}
+	}
+	if channelID != "" {
+		header.Set(HeaderChannelIdentifier, channelID)
+	}
+	request := &Message{Kind: Request, Name: method, RequestID: client.nextID, Header: header, Body: []byte(body)}
+	client.conn.Write(request.Bytes())
+	return client.nextID
+}
+
+// receive reads the next message from the server
+func (client *mrcpClient) receive() *Message {
+	message, err := ReadMessage(client.reader, 0)
+	So(err, ShouldBeNil)
+	return message
+}
+
+// wav returns a second of silence in PCM at sampleRate
+func wav(sampleRate int) []byte {
+	buffer := &audio.Buffer{SampleRate: sampleRate, Channels: 1, Samples: make([]int16, sampleRate)}
+	data, _ := buffer.WAV(audio.PCM)
+	return data
+}
+
+func TestServer(t *testing.T) {
+	Convey("Serving speech resources over MRCPv2", t, func() {
+		requests := make(chan *http.Request, 4)
+		uploads := make(chan string, 4)
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			requests <- req
+			if req.URL.Path == attspeech.TTSResource {
+				w.Write(wav(16000))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			uploads <- string(body)
+			if strings.Contains(string(body), "nomatch") {
+				w.Write([]byte(`{"Recognition":{"Status":"Speech Not Recognized","NBest":[]}}`))
+				return
+			}
+			w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"sales","Confidence":0.9,
+				"NluHypothesis":{"OutComposite":[{"Grammar":"menu","Out":"SALES"}]}}]}}`))
+		}))
+		defer api.Close()
+		apiClient := attspeech.New("foo", "bar", api.URL)
+		So(apiClient.SetAuthTokens(), ShouldBeNil)
+
+		media := &fakeMedia{played: make(chan []byte, 4), recording: wav(8000)}
+		server := &Server{Client: apiClient, Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), MaxMessageSize: 4096,
+			Media: func(channelID string) (Media, error) { return media, nil }}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		go server.Serve(listener)
+		defer server.Close()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		client := &mrcpClient{conn: conn, reader: bufio.NewReader(conn)}
+		synth, recog := "32AECB23433802@speechsynth", "32AECB23433802@speechrecog"
+		text := textproto.MIMEHeader{HeaderContentType: {"text/plain"}}
+		srgs := `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="menu"><rule id="menu"><one-of><item>sales</item></one-of></rule></grammar>`
+
+		Convey("Should speak and complete normally", func() {
+			id := client.send(synth, "SPEAK", text, "Welcome to Acme")
+			response := client.receive()
+			So(response.Kind, ShouldEqual, Response)
+			So(response.RequestID, ShouldEqual, id)
+			So(response.StatusCode, ShouldEqual, StatusSuccess)
+			So(response.State, ShouldEqual, InProgress)
+			So(response.ChannelID(), ShouldEqual, synth)
+			event := client.receive()
+			So(event.Name, ShouldEqual, "SPEAK-COMPLETE")
+			So(event.State, ShouldEqual, Complete)
+			So(event.Header.Get(HeaderCompletionCause), ShouldEqual, SpeakNormal)
+			header, _ := audio.ParseWAVHeader(<-media.played)
+			So(header.Format, ShouldResemble, PlayFormat)
+		})
+		Convey("Should close connections sending messages over the size limit", func() {
+			client.send(synth, "SPEAK", text, strings.Repeat("a", 4096))
+			_, err := ReadMessage(client.reader, 0)
+			So(err, ShouldNotBeNil)
+			So(len(media.played), ShouldEqual, 0)
+		})
+		Convey("Should use the voice set with SET-PARAMS", func() {
+			client.send(synth, "SET-PARAMS", textproto.MIMEHeader{HeaderVoiceName: {"crystal"}}, "")
+			So(client.receive().StatusCode, ShouldEqual, StatusSuccess)
+			client.send(synth, "GET-PARAMS", textproto.MIMEHeader{HeaderVoiceName: {""}}, "")
+			So(client.receive().Header.Get(HeaderVoiceName), ShouldEqual, "crystal")
+			client.send(synth, "SPEAK", text, "Hello")
+			client.receive()
+			client.receive()
+			So((<-requests).Header.Get("X-Arg"), ShouldContainSubstring, "VoiceName=crystal")
+		})
+		Convey("Should queue SPEAK requests and stop them all", func() {
+			media.set(true, media.recording)
+			first := client.send(synth, "SPEAK", text, "One")
+			So(client.receive().State, ShouldEqual, InProgress)
+			second := client.send(synth, "SPEAK", text, "Two")
+			So(client.receive().State, ShouldEqual, Pending)
+			client.send(synth, "STOP", nil, "")
+			response := client.receive()
+			So(response.Kind, ShouldEqual, Response)
+			So(response.State, ShouldEqual, Complete)
+			So(response.Header.Get(HeaderActiveRequestIDs), ShouldEqual, itoa(first)+","+itoa(second))
+		})
+		Convey("Should complete a SPEAK interrupted by barge-in", func() {
+			media.set(true, media.recording)
+			client.send(synth, "SPEAK", text, "One")
+			client.receive()
+			client.send(synth, "BARGE-IN-OCCURRED", nil, "")
+			messages := map[Kind]*Message{}
+			for i := 0; i < 2; i++ {
+				message := client.receive()
+				messages[message.Kind] = message
+			}
+			So(messages[Response].StatusCode, ShouldEqual, StatusSuccess)
+			So(messages[Event].Header.Get(HeaderCompletionCause), ShouldEqual, SpeakBargeIn)
+		})
+		Convey("Should recognize with a defined grammar and return NLSML", func() {
+			client.send(recog, "DEFINE-GRAMMAR", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}, HeaderContentID: {"menu"}}, srgs)
+			So(client.receive().Header.Get(HeaderCompletionCause), ShouldEqual, RecognitionSuccess)
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeURIList}}, "session:menu\r\n")
+			So(client.receive().State, ShouldEqual, InProgress)
+			event := client.receive()
+			So(event.Name, ShouldEqual, "RECOGNITION-COMPLETE")
+			So(event.Header.Get(HeaderCompletionCause), ShouldEqual, RecognitionSuccess)
+			So(event.Header.Get(HeaderContentType), ShouldEqual, ContentTypeNLSML)
+			So(string(event.Body), ShouldContainSubstring, `<interpretation grammar="session:menu" confidence="0.90"><instance>SALES</instance><input mode="speech">sales</input></interpretation>`)
+			So(<-uploads, ShouldContainSubstring, `<item>sales</item>`)
+		})
+		Convey("Should report grammars that match nothing", func() {
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}}, "<grammar>nomatch</grammar>")
+			client.receive()
+			So(client.receive().Header.Get(HeaderCompletionCause), ShouldEqual, RecognitionNoMatch)
+		})
+		Convey("Should time out callers who say nothing", func() {
+			media.set(false, nil)
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}, HeaderNoInputTimeout: {"50"}}, srgs)
+			client.receive()
+			So(client.receive().Header.Get(HeaderCompletionCause), ShouldEqual, RecognitionNoInputTimeout)
+		})
+		Convey("Should allow one RECOGNIZE at a time", func() {
+			media.set(false, nil)
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}}, srgs)
+			client.receive()
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}}, srgs)
+			So(client.receive().StatusCode, ShouldEqual, StatusInvalidState)
+			client.send(recog, "STOP", nil, "")
+			So(client.receive().Header.Get(HeaderActiveRequestIDs), ShouldEqual, "1")
+		})
+		Convey("Should fail RECOGNIZE without a grammar", func() {
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeURIList}}, "session:missing")
+			response := client.receive()
+			So(response.State, ShouldEqual, Complete)
+			So(response.Header.Get(HeaderCompletionCause), ShouldEqual, RecognitionGrammarFailure)
+		})
+		Convey("Should reject invalid requests", func() {
+			client.send("", "SPEAK", text, "Hello")
+			So(client.receive().StatusCode, ShouldEqual, StatusMissingHeader)
+			client.send("32AECB23433802@speakverify", "VERIFY", nil, "")
+			So(client.receive().StatusCode, ShouldEqual, StatusResourceNotAllocated)
+			client.send(synth, "PAUSE", nil, "")
+			So(client.receive().StatusCode, ShouldEqual, StatusMethodNotAllowed)
+			client.send(synth, "SPEAK", textproto.MIMEHeader{HeaderContentType: {"text/html"}}, "<p>Hello</p>")
+			So(client.receive().StatusCode, ShouldEqual, StatusUnsupportedEntity)
+			client.send(recog, "RECOGNIZE", textproto.MIMEHeader{HeaderContentType: {ContentTypeSRGS}, HeaderRecognitionTimeout: {"soon"}}, srgs)
+			So(client.receive().StatusCode, ShouldEqual, StatusIllegalHeaderValue)
+		})
+	})
+}
+
+// itoa formats a request id
+func itoa(id uint32) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,107 @@
+package mrcp
+
+import (
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/ssml"
+	"net/textproto"
+)
+
+// Completion causes of SPEAK-COMPLETE
+const (
+	SpeakNormal       = "000 normal"
+	SpeakBargeIn      = "001 barge-in"
+	SpeakParseFailure = "002 parse-failure"
+	SpeakError        = "004 error"
+)
+
+// handleSynthesizer handles the methods of a speechsynth channel
+func (c *conn) handleSynthesizer(ch *channel, request *Message) {
+	switch request.Name {
+	case "SPEAK":
+		c.speak(ch, request)
+	case "BARGE-IN-OCCURRED":
+		ch.interrupt(SpeakBargeIn)
+		c.respond(request, StatusSuccess, Complete, nil)
+	default:
+		c.respond(request, StatusMethodNotAllowed, Complete, nil)
+	}
+}
+
+/*
+speak synthesizes the text or SSML body of a SPEAK request and plays it.
+SPEAK requests queue behind the one in progress, so their response is
+PENDING rather than IN-PROGRESS until it completes.
+*/
+func (c *conn) speak(ch *channel, request *Message) {
+	contentType := mediaType(request.Header.Get(HeaderContentType))
+	if contentType != "text/plain" && contentType != ssml.ContentType {
+		c.respond(request, StatusUnsupportedEntity, Complete, nil)
+		return
+	}
+	if len(request.Body) == 0 {
+		c.respond(request, StatusMissingHeader, Complete, textproto.MIMEHeader{HeaderCompletionReason: {"SPEAK requires the text to speak"}})
+		return
+	}
+	apiRequest := c.server.Client.NewAPIRequest(c.server.Client.TTSResource)
+	apiRequest.Text = string(request.Body)
+	apiRequest.ContentType = contentType
+	apiRequest.Accept = attspeech.AcceptWAV
+	apiRequest.VoiceName = ch.param(request, HeaderVoiceName)
+	if apiRequest.VoiceName == "" {
+		apiRequest.VoiceName = c.server.Voice
+	}
+	apiRequest.ContentLanguage = ch.param(request, HeaderSpeechLanguage)
+
+	active, previous, _ := ch.start(c.ctx, request.RequestID, true)
+	if previous != nil {
+		c.respond(request, StatusSuccess, Pending, nil)
+	} else {
+		c.respond(request, StatusSuccess, InProgress, nil)
+	}
+	go func() {
+		defer ch.finish(active)
+		if previous != nil {
+			select {
+			case <-previous.done:
+			case <-active.ctx.Done():
+			}
+		}
+		cause, err := SpeakNormal, active.ctx.Err()
+		if err == nil {
+			cause, err = c.synthesize(ch, active, apiRequest)
+		}
+		stopped, interruptedCause := ch.stopped(active)
+		if stopped {
+			// Stopped requests complete in the STOP response instead
+			return
+		}
+		header := textproto.MIMEHeader{HeaderCompletionCause: {cause}}
+		if interruptedCause != "" {
+			header.Set(HeaderCompletionCause, interruptedCause)
+		} else if err != nil {
+			header.Set(HeaderCompletionReason, err.Error())
+		}
+		c.event(request, "SPEAK-COMPLETE", Complete, header, nil)
+	}()
+}
+
+// synthesize synthesizes the request and plays it on the channel's media
+func (c *conn) synthesize(ch *channel, active *activeRequest, apiRequest *attspeech.APIRequest) (string, error) {
+	if apiRequest.ContentType == ssml.ContentType {
+		if err := ssml.Validate(apiRequest.Text); err != nil {
+			return SpeakParseFailure, err
+		}
+	}
+	speech, err := c.server.Client.Synthesize(active.ctx, apiRequest)
+	if err != nil {
+		return SpeakError, err
+	}
+	wav, err := speech.WAV(PlayFormat)
+	if err != nil {
+		return SpeakError, err
+	}
+	if err := ch.media.Play(active.ctx, wav); err != nil {
+		return SpeakError, err
+	}
+	return SpeakNormal, nil
+}
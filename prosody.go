@@ -0,0 +1,86 @@
+package attspeech
+
+import (
+	"errors"
+	"strconv"
+)
+
+// The ranges of the Tempo and Volume Text to Speech parameters, and the service's defaults
+const (
+	MinTempo      = -18
+	MaxTempo      = 18
+	DefaultTempo  = 0
+	MinVolume     = 0
+	MaxVolume     = 500
+	DefaultVolume = 100
+)
+
+/*
+SetTempo sets the speaking rate, from MinTempo, the slowest, to MaxTempo,
+the fastest, with DefaultTempo the voice's normal rate
+
+	apiRequest := client.NewAPIRequest(TTSResource)
+	err := apiRequest.SetTempo(-4)
+*/
+func (apiRequest *APIRequest) SetTempo(tempo int) error {
+	if err := checkRange("tempo", tempo, MinTempo, MaxTempo); err != nil {
+		return err
+	}
+	apiRequest.Tempo = strconv.Itoa(tempo)
+	return nil
+}
+
+// SetVolume sets the loudness as a percentage of normal, from MinVolume to MaxVolume
+func (apiRequest *APIRequest) SetVolume(volume int) error {
+	if err := checkRange("volume", volume, MinVolume, MaxVolume); err != nil {
+		return err
+	}
+	apiRequest.Volume = strconv.Itoa(volume)
+	return nil
+}
+
+/*
+checkProsody validates Tempo and Volume, which may have been set directly
+as strings, and fills in the voice's defaults where they are unset and
+differ from the service's
+*/
+func checkProsody(apiRequest *APIRequest) error {
+	if apiRequest.Tempo != "" {
+		if err := checkSetting("tempo", apiRequest.Tempo, MinTempo, MaxTempo); err != nil {
+			return err
+		}
+	}
+	if apiRequest.Volume != "" {
+		if err := checkSetting("volume", apiRequest.Volume, MinVolume, MaxVolume); err != nil {
+			return err
+		}
+	}
+	voice, ok := LookupVoice(apiRequest.VoiceName)
+	if !ok {
+		return nil
+	}
+	if apiRequest.Tempo == "" && voice.DefaultTempo != DefaultTempo {
+		apiRequest.Tempo = strconv.Itoa(voice.DefaultTempo)
+	}
+	if apiRequest.Volume == "" && voice.DefaultVolume != DefaultVolume {
+		apiRequest.Volume = strconv.Itoa(voice.DefaultVolume)
+	}
+	return nil
+}
+
+// checkSetting reports a setting that is not a whole number within its range
+func checkSetting(name string, value string, min int, max int) error {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New(name + " " + strconv.Quote(value) + " must be a whole number from " + strconv.Itoa(min) + " to " + strconv.Itoa(max))
+	}
+	return checkRange(name, number, min, max)
+}
+
+// checkRange reports a setting outside its range
+func checkRange(name string, value int, min int, max int) error {
+	if value < min || value > max {
+		return errors.New(name + " " + strconv.Itoa(value) + " is out of range, it must be from " + strconv.Itoa(min) + " to " + strconv.Itoa(max))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package attspeech
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestProsody(t *testing.T) {
+	Convey("Setting the tempo and volume", t, func() {
+		apiRequest := &APIRequest{}
+		Convey("Should accept values within range", func() {
+			So(apiRequest.SetTempo(-18), ShouldBeNil)
+			So(apiRequest.SetVolume(500), ShouldBeNil)
+			So(apiRequest.Tempo, ShouldEqual, "-18")
+			So(apiRequest.Volume, ShouldEqual, "500")
+		})
+		Convey("Should reject values out of range", func() {
+			So(apiRequest.SetTempo(19).Error(), ShouldEqual, "tempo 19 is out of range, it must be from -18 to 18")
+			So(apiRequest.SetVolume(-1).Error(), ShouldEqual, "volume -1 is out of range, it must be from 0 to 500")
+			So(apiRequest.Tempo, ShouldBeBlank)
+		})
+		Convey("Should validate values set as strings", func() {
+			apiRequest.Tempo = "fast"
+			So(checkProsody(apiRequest).Error(), ShouldEqual, `tempo "fast" must be a whole number from -18 to 18`)
+			apiRequest.Tempo = ""
+			apiRequest.Volume = "900"
+			So(checkProsody(apiRequest).Error(), ShouldEqual, "volume 900 is out of range, it must be from 0 to 500")
+		})
+		Convey("Should apply voice defaults when tempo and volume are unset", func() {
+			RegisterVoice(&Voice{Name: "whisper", Language: "en-US", DefaultTempo: -2, DefaultVolume: 60})
+			defer delete(voices, "whisper")
+			apiRequest.VoiceName = "whisper"
+			So(checkProsody(apiRequest), ShouldBeNil)
+			So(apiRequest.Tempo, ShouldEqual, "-2")
+			So(apiRequest.Volume, ShouldEqual, "60")
+
+			apiRequest = &APIRequest{VoiceName: "whisper"}
+			So(apiRequest.SetTempo(4), ShouldBeNil)
+			So(checkProsody(apiRequest), ShouldBeNil)
+			So(apiRequest.Tempo, ShouldEqual, "4")
+			So(apiRequest.Volume, ShouldEqual, "60")
+
+			apiRequest = &APIRequest{VoiceName: "crystal"}
+			So(checkProsody(apiRequest), ShouldBeNil)
+			So(apiRequest.Tempo, ShouldBeBlank)
+			So(apiRequest.Volume, ShouldBeBlank)
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package attspeech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech/audio"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentWorkers is the number of segments recognized concurrently when SegmentOptions.Workers is not set
+const DefaultSegmentWorkers = 4
+
+// SegmentOptions configures SpeechToTextLong
+type SegmentOptions struct {
+	// Split tunes the voice activity detection, MaxSegment defaults to the speech to text limit
+	Split audio.SplitOptions
+	// Workers is the number of segments recognized concurrently
+	Workers int
+}
+
+// Transcript is the stitched result of recognizing audio segment by segment
+type Transcript struct {
+	Segments []TranscriptSegment `json:"segments"`
+}
+
+// TranscriptSegment is the recognition of one segment of a long recording
+type TranscriptSegment struct {
+	// Start and End are the offsets of the segment within the original audio
+	Start       time.Duration `json:"start"`
+	End         time.Duration `json:"end"`
+	Recognition *Recognition  `json:"recognition,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+/*
+SpeechToTextLong recognizes WAV audio longer than the API accepts in one
+request. Voice activity detection splits the audio at pauses into
+segments the speech to text endpoint accepts, which are recognized
+concurrently and returned in order with their offsets in the audio.
+
+	apiRequest := client.NewAPIRequest(STTResource)
+	apiRequest.Data = data // where data is WAV audio as *bytes.Buffer
+	apiRequest.XSpeechContext = "Generic"
+	transcript, err := client.SpeechToTextLong(ctx, apiRequest, nil)
+	fmt.Println(transcript.Text())
+
+Every segment is attempted, so when some fail the transcript is returned
+along with an error and the failed segments carry their Error.
+*/
+func (client *Client) SpeechToTextLong(ctx context.Context, apiRequest *APIRequest, options *SegmentOptions) (*Transcript, error) {
+	if apiRequest.Data == nil {
+		return nil, errors.New("data to convert to text must be provided")
+	}
+	sniffContentType(apiRequest)
+	if !isWAV(apiRequest.ContentType) {
+		return nil, errors.New("segmenting audio requires WAV audio")
+	}
+	if options == nil {
+		options = &SegmentOptions{}
+	}
+	split := options.Split
+	if split.MaxSegment <= 0 {
+		split.MaxSegment = audio.STT.MaxDuration
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = DefaultSegmentWorkers
+	}
+
+	header, err := audio.ParseWAVHeader(apiRequest.Data.Bytes())
+	if err != nil {
+		return nil, errors.New("invalid WAV audio: " + err.Error())
+	}
+	source := header.Format
+	source.Channels = 1
+	target, ok := audio.STT.Target(source)
+	if !ok {
+		return nil, errors.New("speech to text has no format the audio can be converted to")
+	}
+	buffer, err := audio.Decode(apiRequest.Data.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	segments := buffer.Split(split)
+	transcript := &Transcript{Segments: make([]TranscriptSegment, len(segments))}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				segment := segments[index]
+				result := &transcript.Segments[index]
+				result.Start, result.End = segment.Start, segment.End
+				recognition, err := client.recognizeSegment(ctx, apiRequest, buffer.Slice(segment), target)
+				if err != nil {
+					result.Error = err.Error()
+					continue
+				}
+				result.Recognition = recognition
+			}
+		}()
+	}
+	for index := range segments {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	failed, first := 0, ""
+	for _, segment := range transcript.Segments {
+		if segment.Error != "" {
+			if failed == 0 {
+				first = segment.Error
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return transcript, errors.New(strconv.Itoa(failed) + " of " + strconv.Itoa(len(segments)) + " segments failed: " + first)
+	}
+	return transcript, nil
+}
+
+// recognizeSegment sends one segment with the parameters of the original request
+func (client *Client) recognizeSegment(ctx context.Context, apiRequest *APIRequest, segment *audio.Buffer, format audio.Format) (*Recognition, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, err := segment.Convert(format)
+	if err != nil {
+		return nil, err
+	}
+	request := *apiRequest
+	request.Data = bytes.NewBuffer(data)
+	request.ContentType = audio.ContentTypeWAV
+	request.ContentLength = ""
+	return client.SpeechToTextContext(ctx, &request)
+}
+
+// Text joins the best hypothesis of each recognized segment
+func (transcript *Transcript) Text() string {
+	texts := []string{}
+	for _, segment := range transcript.Segments {
+		if segment.Recognition == nil || len(segment.Recognition.Recognition.NBest) == 0 {
+			continue
+		}
+		if text := segment.Recognition.Recognition.NBest[0].ResultText; text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
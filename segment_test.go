@@ -0,0 +1,88 @@
+package attspeech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSpeechToTextLong(t *testing.T) {
+	Convey("Recognizing long audio in segments", t, func() {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+			body, _ := ioutil.ReadAll(req.Body)
+			header, err := audio.ParseWAVHeader(body)
+			if err != nil || req.Header.Get("X-Speechcontext") != "Generic" {
+				w.WriteHeader(400)
+				w.Write([]byte(`{"RequestError":{"ServiceException":{"MessageId":"SVC0001","Text":"bad segment"}}}`))
+				return
+			}
+			fmt.Fprintf(w, `{"Recognition":{"Status":"OK","NBest":[{"ResultText":"%s %s"}]}}`, header.Format, header.Duration())
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+
+		speech := &audio.Buffer{SampleRate: 44100, Channels: 1}
+		for _, burst := range []time.Duration{time.Second, 0, 2 * time.Second, 0, time.Second} {
+			amplitude := 8000.0
+			if burst == 0 {
+				burst, amplitude = time.Second, 0
+			}
+			for i := 0; i < int(burst/time.Millisecond)*44; i++ {
+				speech.Samples = append(speech.Samples, int16(amplitude*math.Sin(float64(i)/10)))
+			}
+		}
+		data, _ := speech.WAV(audio.PCM)
+		apiRequest := client.NewAPIRequest(client.STTResource)
+		apiRequest.Data = bytes.NewBuffer(data)
+		apiRequest.XSpeechContext = "Generic"
+
+		Convey("Should recognize each segment and stitch the results in order", func() {
+			transcript, err := client.SpeechToTextLong(context.Background(), apiRequest, nil)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+			So(len(transcript.Segments), ShouldEqual, 3)
+			So(transcript.Segments[0].Start, ShouldEqual, 0)
+			So(transcript.Segments[1].Start, ShouldAlmostEqual, 1850*time.Millisecond, 20*time.Millisecond)
+			So(transcript.Segments[2].End, ShouldAlmostEqual, 6*time.Second, 20*time.Millisecond)
+			So(transcript.Text(), ShouldStartWith, "PCM 16-bit 16000 Hz mono 1.1")
+			So(transcript.Text(), ShouldContainSubstring, "mono 2.3s PCM")
+		})
+		Convey("Should return the segments that succeeded along with an error", func() {
+			apiRequest.XSpeechContext = "Voicemail"
+			transcript, err := client.SpeechToTextLong(context.Background(), apiRequest, &SegmentOptions{Workers: 1})
+			So(err.Error(), ShouldEqual, "3 of 3 segments failed: SVC0001 - bad segment - ")
+			So(transcript.Segments[2].Error, ShouldNotBeBlank)
+			So(transcript.Text(), ShouldBeBlank)
+		})
+		Convey("Should stop sending segments once cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := client.SpeechToTextLong(ctx, apiRequest, nil)
+			So(err.Error(), ShouldEqual, "3 of 3 segments failed: context canceled")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 0)
+		})
+		Convey("Should require WAV audio", func() {
+			apiRequest.Data = bytes.NewBufferString("#!AMR\n")
+			apiRequest.ContentType = ""
+			_, err := client.SpeechToTextLong(context.Background(), apiRequest, nil)
+			So(err.Error(), ShouldEqual, "segmenting audio requires WAV audio")
+		})
+	})
+}
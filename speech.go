@@ -0,0 +1,86 @@
+package attspeech
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech/audio"
+	"time"
+)
+
+// acceptContentTypes maps the audio Accept types to the content type sniffed from the audio returned. WAV aliases are sent as AcceptWAV.
+var acceptContentTypes = map[string]string{
+	AcceptWAV:   audio.ContentTypeWAV,
+	AcceptAMR:   audio.ContentTypeAMR,
+	AcceptAMRWB: audio.ContentTypeAMRWB,
+}
+
+// Speech is audio returned by Text to Speech along with the format it was found to be in
+type Speech struct {
+	Data []byte
+	// ContentType is detected from the audio, e.g. audio/wav or audio/amr
+	ContentType string
+	// Format and Duration are only known for WAV audio
+	Format   *audio.Format
+	Duration time.Duration
+}
+
+/*
+Synthesize is TextToSpeechContext returning the audio with its parsed
+format. When Accept is an audio type, audio in any other format is
+reported as an error rather than returned.
+
+	apiRequest.Accept = attspeech.AcceptWAV
+	speech, err := client.Synthesize(ctx, apiRequest)
+	fmt.Println(speech.Format, speech.Duration)
+	ulaw, err := speech.Raw(audio.Format{Encoding: audio.MuLaw, SampleRate: 8000, Channels: 1, BitsPerSample: 8})
+*/
+func (client *Client) Synthesize(ctx context.Context, apiRequest *APIRequest) (*Speech, error) {
+	data, err := client.TextToSpeechContext(ctx, apiRequest)
+	if err != nil {
+		return nil, err
+	}
+	speech := &Speech{Data: data, ContentType: audio.Sniff(data)}
+	if expected, ok := acceptContentTypes[mediaType(apiRequest.Accept)]; ok && speech.ContentType != expected {
+		received := speech.ContentType
+		if received == "" {
+			received = "unrecognized audio"
+		}
+		return nil, errors.New("requested " + apiRequest.Accept + " but received " + received)
+	}
+	if speech.ContentType == audio.ContentTypeWAV {
+		header, err := audio.ParseWAVHeader(data)
+		if err != nil {
+			return nil, errors.New("invalid WAV audio: " + err.Error())
+		}
+		speech.Format = &header.Format
+		speech.Duration = header.Duration()
+	}
+	return speech, nil
+}
+
+// WAV converts the speech to a WAV file in format, e.g. 8 kHz µ-law for telephony
+func (speech *Speech) WAV(format audio.Format) ([]byte, error) {
+	if err := speech.convertible(); err != nil {
+		return nil, err
+	}
+	if *speech.Format == format {
+		return speech.Data, nil
+	}
+	return audio.Convert(speech.Data, format)
+}
+
+// Raw converts the speech to headerless samples in format
+func (speech *Speech) Raw(format audio.Format) ([]byte, error) {
+	if err := speech.convertible(); err != nil {
+		return nil, err
+	}
+	return audio.ConvertRaw(speech.Data, format)
+}
+
+// convertible reports why the speech cannot be decoded locally
+func (speech *Speech) convertible() error {
+	if speech.Format == nil {
+		return errors.New("only WAV audio can be converted locally, request " + AcceptWAV)
+	}
+	return nil
+}
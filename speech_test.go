@@ -0,0 +1,74 @@
+package attspeech
+
+import (
+	"context"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSynthesize(t *testing.T) {
+	Convey("Synthesizing speech with its format", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			if req.Header.Get("Accept") == AcceptAMR {
+				w.Write([]byte("#!AMR\n\x3c"))
+				return
+			}
+			w.Write(wavBytes(1, 1, 16000, 16, 16000))
+		}))
+		defer ts.Close()
+		client := New("foo", "bar", ts.URL)
+		client.SetAuthTokens()
+		apiRequest := client.NewAPIRequest(TTSResource)
+		apiRequest.Text = "hello"
+
+		Convey("Should parse the format of WAV audio", func() {
+			apiRequest.Accept = AcceptWAV
+			speech, err := client.Synthesize(context.Background(), apiRequest)
+			So(err, ShouldBeNil)
+			So(speech.ContentType, ShouldEqual, audio.ContentTypeWAV)
+			So(*speech.Format, ShouldResemble, audio.Format{Encoding: audio.PCM, SampleRate: 16000, Channels: 1, BitsPerSample: 16})
+			So(speech.Duration, ShouldEqual, time.Second)
+		})
+		Convey("Should convert WAV audio for telephony", func() {
+			speech, _ := client.Synthesize(context.Background(), apiRequest)
+			ulaw := audio.Format{Encoding: audio.MuLaw, SampleRate: 8000, Channels: 1, BitsPerSample: 8}
+			raw, err := speech.Raw(ulaw)
+			So(err, ShouldBeNil)
+			So(len(raw), ShouldEqual, 8000)
+			wav, err := speech.WAV(ulaw)
+			So(err, ShouldBeNil)
+			header, _ := audio.ParseWAVHeader(wav)
+			So(header.Format, ShouldResemble, ulaw)
+		})
+		Convey("Should report AMR audio, which cannot be converted", func() {
+			apiRequest.Accept = AcceptAMR
+			speech, err := client.Synthesize(context.Background(), apiRequest)
+			So(err, ShouldBeNil)
+			So(speech.ContentType, ShouldEqual, audio.ContentTypeAMR)
+			So(speech.Format, ShouldBeNil)
+			_, err = speech.Raw(audio.Format{Encoding: audio.PCM, SampleRate: 8000, Channels: 1, BitsPerSample: 16})
+			So(err.Error(), ShouldEqual, "only WAV audio can be converted locally, request audio/x-wav")
+		})
+		Convey("Should check WAV aliases as WAV", func() {
+			apiRequest.Accept = "audio/wav"
+			speech, err := client.Synthesize(context.Background(), apiRequest)
+			So(err, ShouldBeNil)
+			So(apiRequest.Accept, ShouldEqual, AcceptWAV)
+			So(speech.ContentType, ShouldEqual, audio.ContentTypeWAV)
+		})
+		Convey("Should fail when the audio is not what was requested", func() {
+			apiRequest.Accept = AcceptAMRWB
+			_, err := client.Synthesize(context.Background(), apiRequest)
+			So(err.Error(), ShouldEqual, "requested audio/amr-wb but received audio/wav")
+		})
+	})
+}
@@ -0,0 +1,219 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// boundaries are the places text is split, from the most to the least natural
+var boundaries = []*regexp.Regexp{
+	regexp.MustCompile(`[.!?…]+["'”’)\]]*\s+`),
+	regexp.MustCompile(`[,;:–—]\s+`),
+	regexp.MustCompile(`\s+`),
+}
+
+/*
+SplitText splits plain text into pieces of at most max characters,
+preferring sentence boundaries, then clauses, then words. Joining the
+pieces gives back the original text.
+
+	chunks := ssml.SplitText(text, 4000)
+*/
+func SplitText(text string, max int) []string {
+	if max < 1 {
+		max = 1
+	}
+	return splitText(text, max, 0)
+}
+
+// splitText splits text at the boundaries from level on, cutting between characters as a last resort
+func splitText(text string, max int, level int) []string {
+	if utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+	if level == len(boundaries) {
+		pieces := []string{}
+		runes := []rune(text)
+		for len(runes) > max {
+			pieces = append(pieces, string(runes[:max]))
+			runes = runes[max:]
+		}
+		return append(pieces, string(runes))
+	}
+
+	pieces, current := []string{}, ""
+	for _, segment := range cut(text, boundaries[level]) {
+		if utf8.RuneCountInString(segment) > max {
+			if current != "" {
+				pieces = append(pieces, current)
+			}
+			smaller := splitText(segment, max, level+1)
+			pieces = append(pieces, smaller[:len(smaller)-1]...)
+			current = smaller[len(smaller)-1]
+			continue
+		}
+		if utf8.RuneCountInString(current)+utf8.RuneCountInString(segment) > max {
+			pieces = append(pieces, current)
+			current = ""
+		}
+		current += segment
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// cut splits text after each match of boundary
+func cut(text string, boundary *regexp.Regexp) []string {
+	segments, start := []string{}, 0
+	for _, match := range boundary.FindAllStringIndex(text, -1) {
+		segments = append(segments, text[start:match[1]])
+		start = match[1]
+	}
+	if start < len(text) {
+		segments = append(segments, text[start:])
+	}
+	return segments
+}
+
+// node is an SSML element or run of text, kept as written in the source document
+type node struct {
+	name     string
+	start    string
+	end      string
+	text     string
+	children []*node
+}
+
+// render returns the node as it was written
+func (n *node) render() string {
+	if n.name == "" {
+		return n.text
+	}
+	var rendered strings.Builder
+	rendered.WriteString(n.start)
+	for _, child := range n.children {
+		rendered.WriteString(child.render())
+	}
+	rendered.WriteString(n.end)
+	return rendered.String()
+}
+
+/*
+Split splits an SSML document into documents of at most max characters
+each, so text over the API's limit can be synthesized in parts. Documents
+are split between elements and at sentence, clause and word boundaries
+within text, and elements such as <p> and <prosody> that are split are
+repeated around each part so every part is spoken the same way.
+
+	documents, err := ssml.Split(document, 4000)
+*/
+func Split(document string, max int) ([]string, error) {
+	speak, err := parse(document)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(speak.render()) <= max {
+		return []string{speak.render()}, nil
+	}
+	bodies, err := splitChildren(speak, max-utf8.RuneCountInString(speak.start+speak.end))
+	if err != nil {
+		return nil, err
+	}
+	documents := []string{}
+	for _, body := range bodies {
+		if strings.TrimSpace(body) != "" {
+			documents = append(documents, speak.start+body+speak.end)
+		}
+	}
+	return documents, nil
+}
+
+// splitChildren splits the content of parent into parts of at most max characters
+func splitChildren(parent *node, max int) ([]string, error) {
+	if max <= 0 {
+		return nil, errors.New("SSML markup leaves no room for text within the character limit")
+	}
+	parts, current := []string{}, ""
+	for _, child := range parent.children {
+		rendered := child.render()
+		if utf8.RuneCountInString(current)+utf8.RuneCountInString(rendered) <= max {
+			current += rendered
+			continue
+		}
+		if current != "" {
+			parts = append(parts, current)
+			current = ""
+		}
+		if utf8.RuneCountInString(rendered) <= max {
+			current = rendered
+			continue
+		}
+
+		var pieces []string
+		switch {
+		case child.name == "":
+			pieces = SplitText(child.text, max)
+		case elements[child.name].content == mixed:
+			inner, err := splitChildren(child, max-utf8.RuneCountInString(child.start+child.end))
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range inner {
+				pieces = append(pieces, child.start+part+child.end)
+			}
+		default:
+			return nil, errors.New("<" + child.name + "> does not fit within the character limit and cannot be split")
+		}
+		parts = append(parts, pieces[:len(pieces)-1]...)
+		current = pieces[len(pieces)-1]
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+	return parts, nil
+}
+
+// parse builds the tree of the document's <speak> element, keeping the markup as written
+func parse(document string) (*node, error) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	root := &node{}
+	stack := []*node{root}
+	offset := int64(0)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("invalid SSML: " + err.Error())
+		}
+		raw := document[offset:decoder.InputOffset()]
+		offset = decoder.InputOffset()
+		parent := stack[len(stack)-1]
+		switch token := token.(type) {
+		case xml.StartElement:
+			child := &node{name: token.Name.Local, start: raw}
+			parent.children = append(parent.children, child)
+			stack = append(stack, child)
+		case xml.EndElement:
+			parent.end = raw
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if parent != root {
+				parent.children = append(parent.children, &node{text: raw})
+			}
+		}
+	}
+	for _, child := range root.children {
+		if child.name == "speak" {
+			return child, nil
+		}
+	}
+	return nil, errors.New("SSML must have a single <speak> root element")
+}
@@ -0,0 +1,65 @@
+package ssml
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	Convey("Splitting plain text", t, func() {
+		Convey("Should leave short text alone", func() {
+			So(SplitText("Hello world.", 100), ShouldResemble, []string{"Hello world."})
+		})
+		Convey("Should split at sentences first", func() {
+			text := "First sentence here. Second one! Third, with a clause? Fourth."
+			So(SplitText(text, 40), ShouldResemble, []string{"First sentence here. Second one! ", "Third, with a clause? Fourth."})
+		})
+		Convey("Should fall back to clauses, words and characters", func() {
+			So(SplitText("one two, three four five", 12), ShouldResemble, []string{"one two, ", "three four ", "five"})
+			So(SplitText("abcdefghij", 4), ShouldResemble, []string{"abcd", "efgh", "ij"})
+		})
+		Convey("Should join back into the original text", func() {
+			text := strings.Repeat("The quick brown fox jumps over the lazy dog; it was not amused. ", 40)
+			pieces := SplitText(text, 100)
+			So(strings.Join(pieces, ""), ShouldEqual, text)
+			for _, piece := range pieces {
+				So(utf8.RuneCountInString(piece), ShouldBeLessThanOrEqualTo, 100)
+			}
+		})
+	})
+}
+
+func TestSplit(t *testing.T) {
+	Convey("Splitting SSML", t, func() {
+		Convey("Should keep a short document whole", func() {
+			document := `<speak version="1.0">Hi</speak>`
+			documents, err := Split(document, 100)
+			So(err, ShouldBeNil)
+			So(documents, ShouldResemble, []string{document})
+		})
+		Convey("Should split between elements and repeat the elements it splits", func() {
+			document := `<?xml version="1.0"?><speak version="1.0" xml:lang="en-US"><p>First paragraph.</p><break time="1s"/><prosody rate="slow">Slow sentence one. Slow sentence two.</prosody></speak>`
+			documents, err := Split(document, 100)
+			So(err, ShouldBeNil)
+			So(documents, ShouldResemble, []string{
+				`<speak version="1.0" xml:lang="en-US"><p>First paragraph.</p><break time="1s"/></speak>`,
+				`<speak version="1.0" xml:lang="en-US"><prosody rate="slow">Slow sentence one. </prosody></speak>`,
+				`<speak version="1.0" xml:lang="en-US"><prosody rate="slow">Slow sentence two.</prosody></speak>`,
+			})
+			for _, document := range documents {
+				So(Validate(document), ShouldBeNil)
+				So(utf8.RuneCountInString(document), ShouldBeLessThanOrEqualTo, 100)
+			}
+		})
+		Convey("Should not split elements that may only contain text", func() {
+			_, err := Split(`<speak version="1.0"><say-as interpret-as="characters">ABCDEFGHIJKLMNOPQRSTUVWXYZ</say-as></speak>`, 50)
+			So(err.Error(), ShouldEqual, "<say-as> does not fit within the character limit and cannot be split")
+		})
+		Convey("Should fail when the markup leaves no room for text", func() {
+			_, err := Split(`<speak version="1.0">Some text to speak</speak>`, 20)
+			So(err.Error(), ShouldEqual, "SSML markup leaves no room for text within the character limit")
+		})
+	})
+}
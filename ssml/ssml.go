@@ -0,0 +1,179 @@
+/*
+Package ssml builds and validates Speech Synthesis Markup Language
+documents for the AT&T Text to Speech API, which supports a subset of
+SSML 1.0 for pauses, pronunciation and prosody.
+
+	b := ssml.NewBuilder()
+	b.Text("Your code is").Break(300*time.Millisecond).SayAs("characters", "", "A1B2")
+	b.Prosody(ssml.Prosody{Rate: "slow"}, func(b *ssml.Builder) {
+		b.Text("Please write it down.")
+	})
+	apiRequest.Text, err = b.Build()
+*/
+package ssml
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the content type TTS requests carrying SSML are sent with
+const ContentType = "application/ssml+xml"
+
+// Namespace is the SSML 1.0 XML namespace
+const Namespace = "http://www.w3.org/2001/10/synthesis"
+
+// Prosody sets the rate, pitch and volume of speech, empty fields are left unchanged
+type Prosody struct {
+	Rate   string
+	Pitch  string
+	Volume string
+}
+
+// Builder assembles an SSML document, escaping text as it is added
+type Builder struct {
+	// Language is the xml:lang of the document, e.g. en-US
+	Language string
+	body     strings.Builder
+	// spoken is set after words, which must be separated from the next words
+	spoken bool
+}
+
+// NewBuilder creates an empty SSML document
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Text adds text to speak
+func (b *Builder) Text(text string) *Builder {
+	b.space()
+	xml.EscapeText(&b.body, []byte(text))
+	b.spoken = true
+	return b
+}
+
+// Break adds a pause of d, written in whole milliseconds
+func (b *Builder) Break(d time.Duration) *Builder {
+	b.body.WriteString(`<break time="` + strconv.FormatInt(d.Round(time.Millisecond).Milliseconds(), 10) + `ms"/>`)
+	b.spoken = false
+	return b
+}
+
+// BreakStrength adds a pause of a relative strength, from none and x-weak to x-strong
+func (b *Builder) BreakStrength(strength string) *Builder {
+	b.body.WriteString(`<break strength="` + escape(strength) + `"/>`)
+	b.spoken = false
+	return b
+}
+
+// SayAs speaks text as a type of content, e.g. characters, date or telephone, format may be empty
+func (b *Builder) SayAs(interpretAs string, format string, text string) *Builder {
+	b.space()
+	b.body.WriteString(`<say-as interpret-as="` + escape(interpretAs) + `"`)
+	if format != "" {
+		b.body.WriteString(` format="` + escape(format) + `"`)
+	}
+	b.body.WriteString(">" + escape(text) + "</say-as>")
+	b.spoken = true
+	return b
+}
+
+// Phoneme speaks text with the pronunciation ph, written in alphabet such as ipa or x-sampa
+func (b *Builder) Phoneme(alphabet string, ph string, text string) *Builder {
+	b.space()
+	b.body.WriteString(`<phoneme alphabet="` + escape(alphabet) + `" ph="` + escape(ph) + `">` + escape(text) + "</phoneme>")
+	b.spoken = true
+	return b
+}
+
+// Sub speaks alias in place of text, e.g. "World Wide Web Consortium" for "W3C"
+func (b *Builder) Sub(alias string, text string) *Builder {
+	b.space()
+	b.body.WriteString(`<sub alias="` + escape(alias) + `">` + escape(text) + "</sub>")
+	b.spoken = true
+	return b
+}
+
+// Emphasis speaks content with a level of stress, strong, moderate, none or reduced
+func (b *Builder) Emphasis(level string, content func(b *Builder)) *Builder {
+	return b.element(`<emphasis level="`+escape(level)+`">`, "</emphasis>", content)
+}
+
+// Prosody speaks content with a different rate, pitch or volume
+func (b *Builder) Prosody(prosody Prosody, content func(b *Builder)) *Builder {
+	start := "<prosody"
+	for _, attr := range [][2]string{{"rate", prosody.Rate}, {"pitch", prosody.Pitch}, {"volume", prosody.Volume}} {
+		if attr[1] != "" {
+			start += " " + attr[0] + `="` + escape(attr[1]) + `"`
+		}
+	}
+	return b.element(start+">", "</prosody>", content)
+}
+
+// Paragraph wraps content in a paragraph
+func (b *Builder) Paragraph(content func(b *Builder)) *Builder {
+	return b.element("<p>", "</p>", content)
+}
+
+// Sentence wraps content in a sentence
+func (b *Builder) Sentence(content func(b *Builder)) *Builder {
+	return b.element("<s>", "</s>", content)
+}
+
+// String returns the document wrapped in its <speak> element
+func (b *Builder) String() string {
+	speak := `<speak version="1.0" xmlns="` + Namespace + `"`
+	if b.Language != "" {
+		speak += ` xml:lang="` + escape(b.Language) + `"`
+	}
+	return speak + ">" + b.body.String() + "</speak>"
+}
+
+// Build returns the document, or an error if it uses SSML the API does not support
+func (b *Builder) Build() (string, error) {
+	document := b.String()
+	if err := Validate(document); err != nil {
+		return "", err
+	}
+	return document, nil
+}
+
+// element wraps the content written by fn in start and end tags
+func (b *Builder) element(start string, end string, fn func(b *Builder)) *Builder {
+	b.space()
+	b.body.WriteString(start)
+	b.spoken = false
+	fn(b)
+	b.body.WriteString(end)
+	b.spoken = true
+	return b
+}
+
+// space separates the words about to be added from the previous words
+func (b *Builder) space() {
+	if b.spoken {
+		b.body.WriteByte(' ')
+	}
+}
+
+// IsSSML reports whether text is an SSML document rather than plain text
+func IsSSML(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "<?xml") {
+		end := strings.Index(text, "?>")
+		if end < 0 {
+			return false
+		}
+		text = strings.TrimSpace(text[end+2:])
+	}
+	return strings.HasPrefix(text, "<speak") && len(text) > 6 && strings.ContainsAny(text[6:7], " \t\r\n>/")
+}
+
+// escape escapes text for use in element content or a double quoted attribute
+func escape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
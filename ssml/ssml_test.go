@@ -0,0 +1,58 @@
+package ssml
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("Building SSML", t, func() {
+		Convey("Should escape text and separate words", func() {
+			b := NewBuilder()
+			b.Language = "en-US"
+			b.Text("Tom & Jerry").SayAs("characters", "", "A1B2").Text("said <hi>")
+			So(b.String(), ShouldEqual, `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US">Tom &amp; Jerry <say-as interpret-as="characters">A1B2</say-as> said &lt;hi&gt;</speak>`)
+		})
+		Convey("Should nest elements and add pauses", func() {
+			b := NewBuilder()
+			b.Paragraph(func(b *Builder) {
+				b.Sentence(func(b *Builder) {
+					b.Text("Welcome to").Sub("World Wide Web Consortium", "W3C")
+				})
+				b.Break(1500 * time.Millisecond).BreakStrength("strong")
+				b.Prosody(Prosody{Rate: "slow", Volume: "+6dB"}, func(b *Builder) {
+					b.Emphasis("strong", func(b *Builder) { b.Text("Listen") })
+					b.Text("carefully").Phoneme("ipa", "təˈmɑːtəʊ", "tomato")
+				})
+			})
+			document, err := b.Build()
+			So(err, ShouldBeNil)
+			So(document, ShouldEqual, `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis"><p><s>Welcome to <sub alias="World Wide Web Consortium">W3C</sub></s><break time="1500ms"/><break strength="strong"/><prosody rate="slow" volume="+6dB"><emphasis level="strong">Listen</emphasis> carefully <phoneme alphabet="ipa" ph="təˈmɑːtəʊ">tomato</phoneme></prosody></p></speak>`)
+		})
+		Convey("Should write long pauses the validator accepts", func() {
+			b := NewBuilder()
+			b.Text("Please hold").Break(time.Minute + 500*time.Microsecond)
+			document, err := b.Build()
+			So(err, ShouldBeNil)
+			So(document, ShouldContainSubstring, `<break time="60001ms"/>`)
+		})
+		Convey("Should fail to build unsupported values", func() {
+			b := NewBuilder()
+			b.Prosody(Prosody{Rate: "ludicrous"}, func(b *Builder) { b.Text("fast") })
+			_, err := b.Build()
+			So(err.Error(), ShouldEqual, `invalid rate "ludicrous" on <prosody>`)
+		})
+	})
+}
+
+func TestIsSSML(t *testing.T) {
+	Convey("Detecting SSML", t, func() {
+		So(IsSSML(`<speak version="1.0">hi</speak>`), ShouldBeTrue)
+		So(IsSSML("<?xml version=\"1.0\"?>\n<speak>hi</speak>"), ShouldBeTrue)
+		So(IsSSML("  <speak>hi</speak>"), ShouldBeTrue)
+		So(IsSSML("<speaker>hi</speaker>"), ShouldBeFalse)
+		So(IsSSML("speak up"), ShouldBeFalse)
+		So(IsSSML("<?xml version=\"1.0\"?>"), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,185 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// xmlNamespace is the namespace encoding/xml gives attributes with the xml: prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// content describes what an element may contain
+type content int
+
+const (
+	mixed content = iota
+	textOnly
+	empty
+)
+
+// element describes an SSML element the AT&T Text to Speech API supports
+type element struct {
+	attributes map[string]*regexp.Regexp
+	required   []string
+	content    content
+	// excludes lists elements that may not appear anywhere inside this one
+	excludes []string
+}
+
+var (
+	anything   = regexp.MustCompile(`^.+$`)
+	language   = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	breakTime  = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(ms|s)$`)
+	strength   = regexp.MustCompile(`^(none|x-weak|weak|medium|strong|x-strong)$`)
+	level      = regexp.MustCompile(`^(strong|moderate|none|reduced)$`)
+	rate       = regexp.MustCompile(`^(x-slow|slow|medium|fast|x-fast|default|[+-]?[0-9]+(\.[0-9]+)?%)$`)
+	pitch      = regexp.MustCompile(`^(x-low|low|medium|high|x-high|default|[+-][0-9]+(\.[0-9]+)?(Hz|st|%))$`)
+	volume     = regexp.MustCompile(`^(silent|x-soft|soft|medium|loud|x-loud|default|[+-][0-9]+(\.[0-9]+)?dB)$`)
+	alphabet   = regexp.MustCompile(`^(ipa|x-sampa)$`)
+	gender     = regexp.MustCompile(`^(male|female|neutral)$`)
+	attributes = map[string]*regexp.Regexp{"xml:lang": language}
+)
+
+// elements is the subset of SSML 1.0 supported by the AT&T Text to Speech API
+var elements = map[string]*element{
+	"speak":    {attributes: map[string]*regexp.Regexp{"version": regexp.MustCompile(`^1\.0$`), "xml:lang": language}, required: []string{"version"}, excludes: []string{"speak"}},
+	"p":        {attributes: attributes, excludes: []string{"p"}},
+	"s":        {attributes: attributes, excludes: []string{"p", "s"}},
+	"break":    {attributes: map[string]*regexp.Regexp{"time": breakTime, "strength": strength}, content: empty},
+	"emphasis": {attributes: map[string]*regexp.Regexp{"level": level}, excludes: []string{"p", "s"}},
+	"prosody":  {attributes: map[string]*regexp.Regexp{"rate": rate, "pitch": pitch, "volume": volume}, excludes: []string{"p", "s"}},
+	"say-as":   {attributes: map[string]*regexp.Regexp{"interpret-as": anything, "format": anything, "detail": anything}, required: []string{"interpret-as"}, content: textOnly},
+	"phoneme":  {attributes: map[string]*regexp.Regexp{"alphabet": alphabet, "ph": anything}, required: []string{"ph"}, content: textOnly},
+	"sub":      {attributes: map[string]*regexp.Regexp{"alias": anything}, required: []string{"alias"}, content: textOnly},
+	"voice":    {attributes: map[string]*regexp.Regexp{"name": anything, "gender": gender, "xml:lang": language}},
+	"mark":     {attributes: map[string]*regexp.Regexp{"name": anything}, required: []string{"name"}, content: empty},
+}
+
+/*
+Validate checks that document is well formed SSML using only the elements
+and attribute values the AT&T Text to Speech API supports, so mistakes are
+reported before the request is sent.
+
+	err := ssml.Validate(`<speak version="1.0">Hello<break time="1s"/>world</speak>`)
+*/
+func Validate(document string) error {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	stack := []string{}
+	root := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.New("invalid SSML: " + err.Error())
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			name, err := elementName(token.Name)
+			if err != nil {
+				return err
+			}
+			if len(stack) == 0 {
+				if root || name != "speak" {
+					return errors.New("SSML must have a single <speak> root element")
+				}
+				root = true
+			}
+			if err := checkNesting(name, stack); err != nil {
+				return err
+			}
+			if err := checkAttributes(name, token.Attr); err != nil {
+				return err
+			}
+			stack = append(stack, name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 0 {
+				if len(strings.TrimSpace(string(token))) > 0 {
+					return errors.New("SSML must not have text outside the <speak> element")
+				}
+				continue
+			}
+			if elements[stack[len(stack)-1]].content == empty && len(strings.TrimSpace(string(token))) > 0 {
+				return errors.New("<" + stack[len(stack)-1] + "> must be empty")
+			}
+		}
+	}
+	if !root {
+		return errors.New("SSML must have a single <speak> root element")
+	}
+	return nil
+}
+
+// elementName returns the name of a supported SSML element
+func elementName(name xml.Name) (string, error) {
+	if name.Space != "" && name.Space != Namespace {
+		return "", errors.New("unsupported SSML element <" + name.Local + "> in namespace " + name.Space)
+	}
+	if elements[name.Local] == nil {
+		return "", errors.New("unsupported SSML element <" + name.Local + ">")
+	}
+	return name.Local, nil
+}
+
+// checkNesting reports an element placed where its ancestors do not allow it
+func checkNesting(name string, stack []string) error {
+	if len(stack) == 0 {
+		return nil
+	}
+	parent := stack[len(stack)-1]
+	switch elements[parent].content {
+	case empty:
+		return errors.New("<" + parent + "> must be empty")
+	case textOnly:
+		return errors.New("<" + parent + "> may only contain text")
+	}
+	for _, ancestor := range stack {
+		for _, excluded := range elements[ancestor].excludes {
+			if excluded == name {
+				return errors.New("<" + name + "> cannot be used inside <" + ancestor + ">")
+			}
+		}
+	}
+	return nil
+}
+
+// checkAttributes reports unsupported, invalid and missing attributes
+func checkAttributes(name string, attrs []xml.Attr) error {
+	definition := elements[name]
+	seen := map[string]bool{}
+	for _, attr := range attrs {
+		key := attr.Name.Local
+		switch attr.Name.Space {
+		case "xmlns":
+			continue
+		case xmlNamespace:
+			key = "xml:" + key
+		case "":
+			if key == "xmlns" {
+				continue
+			}
+		default:
+			return errors.New("unsupported attribute " + attr.Name.Space + ":" + key + " on <" + name + ">")
+		}
+		pattern, ok := definition.attributes[key]
+		if !ok {
+			return errors.New("unsupported attribute " + key + " on <" + name + ">")
+		}
+		if !pattern.MatchString(attr.Value) {
+			return errors.New("invalid " + key + " \"" + attr.Value + "\" on <" + name + ">")
+		}
+		seen[key] = true
+	}
+	for _, key := range definition.required {
+		if !seen[key] {
+			return errors.New("<" + name + "> requires the " + key + " attribute")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package ssml
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Validating SSML", t, func() {
+		Convey("Should accept the supported subset", func() {
+			So(Validate(`<?xml version="1.0"?>
+<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xml:lang="en-US">
+	<p><s xml:lang="es-US">Hola</s></p>
+	<voice name="mike">Hi<mark name="here"/></voice>
+	<prosody rate="-10%" pitch="+2st" volume="loud">Call <say-as interpret-as="telephone" format="1">5551234</say-as></prosody>
+	<break time="250ms"/><break strength="x-weak"/>
+</speak>`), ShouldBeNil)
+		})
+		Convey("Should reject documents that are not SSML", func() {
+			So(Validate("hello").Error(), ShouldEqual, "SSML must not have text outside the <speak> element")
+			So(Validate("").Error(), ShouldEqual, "SSML must have a single <speak> root element")
+			So(Validate(`<p>hi</p>`).Error(), ShouldEqual, "SSML must have a single <speak> root element")
+			So(Validate(`<speak version="1.0">hi`).Error(), ShouldStartWith, "invalid SSML: XML syntax error")
+		})
+		Convey("Should reject unsupported elements and attributes", func() {
+			So(Validate(`<speak version="1.0"><audio src="a.wav"/></speak>`).Error(), ShouldEqual, "unsupported SSML element <audio>")
+			So(Validate(`<speak version="1.0"><break time="1s" duration="2"/></speak>`).Error(), ShouldEqual, "unsupported attribute duration on <break>")
+			So(Validate(`<speak version="1.0" xmlns:x="urn:x"><x:p/></speak>`).Error(), ShouldEqual, "unsupported SSML element <p> in namespace urn:x")
+		})
+		Convey("Should reject invalid and missing attribute values", func() {
+			So(Validate(`<speak version="1.0"><break time="5x"/></speak>`).Error(), ShouldEqual, `invalid time "5x" on <break>`)
+			So(Validate(`<speak version="1.0"><phoneme alphabet="arpabet" ph="x">a</phoneme></speak>`).Error(), ShouldEqual, `invalid alphabet "arpabet" on <phoneme>`)
+			So(Validate(`<speak version="1.0"><say-as>1</say-as></speak>`).Error(), ShouldEqual, "<say-as> requires the interpret-as attribute")
+			So(Validate(`<speak>hi</speak>`).Error(), ShouldEqual, "<speak> requires the version attribute")
+		})
+		Convey("Should reject invalid nesting", func() {
+			So(Validate(`<speak version="1.0"><s><p>hi</p></s></speak>`).Error(), ShouldEqual, "<p> cannot be used inside <s>")
+			So(Validate(`<speak version="1.0"><prosody rate="slow"><s>hi</s></prosody></speak>`).Error(), ShouldEqual, "<s> cannot be used inside <prosody>")
+			So(Validate(`<speak version="1.0"><sub alias="a"><break/></sub></speak>`).Error(), ShouldEqual, "<sub> may only contain text")
+			So(Validate(`<speak version="1.0"><break>pause</break></speak>`).Error(), ShouldEqual, "<break> must be empty")
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package attspeech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+SpeechToTextStream is SpeechToText for audio that is still being recorded.
+The audio is uploaded with chunked transfer encoding as it is read, so
+recognition finishes soon after the audio ends.
+
+	reader, writer := io.Pipe()
+	go record(writer) // writes microphone audio, then closes the writer
+	apiRequest := client.NewAPIRequest(STTResource)
+	apiRequest.ContentType = "audio/amr"
+	recognition, err := client.SpeechToTextStream(ctx, apiRequest, reader)
+
+The audio must be in a format the API accepts, as it cannot be sniffed,
+checked, transcoded or cached before it is sent. apiRequest.Data is not
+used.
+*/
+func (client *Client) SpeechToTextStream(ctx context.Context, apiRequest *APIRequest, audio io.Reader) (recognition *Recognition, err error) {
+	ctx, span := client.startSpan(ctx, "SpeechToTextStream", ResourceKey.String(client.STTResource))
+	defer func() { endSpan(span, err) }()
+	if apiRequest.ContentType == "" {
+		return nil, errors.New("a content type must be provided")
+	}
+	span.SetAttributes(ContentTypeKey.String(apiRequest.ContentType))
+
+	request := *apiRequest
+	request.ContentLength = ""
+	counter := &countingReader{r: audio}
+	body, statusCode, err := client.post(ctx, client.STTResource, counter, &request)
+	client.observeStream(client.STTResource, counter)
+	if err != nil {
+		return nil, err
+	}
+	return client.recognitionResponse(ctx, client.STTResource, statusCode, body)
+}
+
+/*
+SpeechToTextCustomStream is SpeechToTextCustom for audio that is still
+being recorded, uploading the grammar and dictionary and then the audio as
+it is read. apiRequest.Filename defaults to "audio" when it is empty.
+*/
+func (client *Client) SpeechToTextCustomStream(ctx context.Context, apiRequest *APIRequest, audio io.Reader, grammar string, dictionary string) (recognition *Recognition, err error) {
+	ctx, span := client.startSpan(ctx, "SpeechToTextCustomStream", ResourceKey.String(client.STTCResource))
+	defer func() { endSpan(span, err) }()
+	if grammar == "" {
+		return nil, errors.New("a grammar must be provided")
+	}
+	if apiRequest.ContentType == "" {
+		return nil, errors.New("content type must be provided")
+	}
+	span.SetAttributes(ContentTypeKey.String(apiRequest.ContentType))
+
+	request := *apiRequest
+	request.ContentLength = ""
+	if request.Filename == "" {
+		request.Filename = "audio"
+	}
+	counter := &countingReader{r: audio}
+	form, contentType := streamForm(request.Filename, request.ContentType, counter, grammar, dictionary)
+	defer form.Close()
+	request.ContentType = contentType
+	body, statusCode, err := client.post(ctx, client.STTCResource, form, &request)
+	client.observeStream(client.STTCResource, counter)
+	if err != nil {
+		return nil, err
+	}
+	return client.recognitionResponse(ctx, client.STTCResource, statusCode, body)
+}
+
+// recognitionResponse parses the response to a speech to text request
+func (client *Client) recognitionResponse(ctx context.Context, resource string, statusCode int, body []byte) (*Recognition, error) {
+	if statusCode != 200 {
+		return nil, client.apiError(ctx, resource, statusCode, body)
+	}
+	recognition := &Recognition{}
+	if err := json.Unmarshal(body, recognition); err != nil {
+		return nil, err
+	}
+	return recognition, nil
+}
+
+/*
+streamForm writes the multipart form buildForm builds, reading the audio
+part from audio as the form is sent rather than buffering it
+*/
+func streamForm(filename string, audioContentType string, audio io.Reader, grammar string, dictionary string) (io.ReadCloser, string) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part := func(contentDisposition string, contentType string, body io.Reader) error {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", contentDisposition)
+			header.Set("Content-Type", contentType)
+			w, err := form.CreatePart(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, body)
+			return err
+		}
+		var err error
+		if dictionary != "" {
+			err = part(`form-data; name="x-dictionary"; filename="speech_alpha.pls"`, "application/pls+xml", strings.NewReader(dictionary+"\n"))
+		}
+		if err == nil {
+			err = part(`form-data; name="x-grammar"`, "application/srgs+xml", strings.NewReader(grammar+"\n"))
+		}
+		if err == nil {
+			err = part(`form-data; name="x-voice"; filename="`+filename+`"`, audioContentType, audio)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	contentType := strings.Replace(form.FormDataContentType(), "form-data", "x-srgs-audio", 1)
+	return reader, contentType
+}
+
+// observeStream records the audio uploaded by a stream once it has been sent
+func (client *Client) observeStream(resource string, counter *countingReader) {
+	if client.Metrics != nil {
+		client.Metrics.ObserveAudioUploaded(resource, int(counter.n.Load()))
+	}
+}
+
+// countingReader counts the bytes read through it, which may be read while the upload is still being sent
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+// Read reads from the underlying reader and counts the bytes read
+func (reader *countingReader) Read(data []byte) (int, error) {
+	n, err := reader.r.Read(data)
+	reader.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,101 @@
+package attspeech
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpeechToTextStream(t *testing.T) {
+	Convey("Streaming audio to speech to text", t, func() {
+		uploads := make(chan *http.Request, 1)
+		bodies := make(chan []byte, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, OauthResource) {
+				w.Write(oauthJSON())
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			uploads <- req
+			bodies <- body
+			if req.Header.Get("X-Speechcontext") == "raise/error" {
+				w.WriteHeader(400)
+				w.Write(contentTypeErrorJSON())
+				return
+			}
+			w.Write(recognitionJSON())
+		}))
+		defer ts.Close()
+		metrics := &recordedMetrics{}
+		client := New("foo", "bar", ts.URL)
+		client.Metrics = metrics
+		client.SetAuthTokens()
+
+		Convey("Should upload the audio as it is written with chunked encoding", func() {
+			reader, writer := io.Pipe()
+			go func() {
+				for i := 0; i < 5; i++ {
+					writer.Write([]byte("frame"))
+					time.Sleep(time.Millisecond)
+				}
+				writer.Close()
+			}()
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.ContentType = "audio/amr"
+			recognition, err := client.SpeechToTextStream(context.Background(), apiRequest, reader)
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.Status, ShouldEqual, "OK")
+			upload := <-uploads
+			So(upload.TransferEncoding, ShouldResemble, []string{"chunked"})
+			So(upload.Header.Get("Content-Type"), ShouldEqual, "audio/amr")
+			So(string(<-bodies), ShouldEqual, "frameframeframeframeframe")
+			So(metrics.uploaded, ShouldEqual, 25)
+		})
+		Convey("Should require a content type", func() {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			_, err := client.SpeechToTextStream(context.Background(), apiRequest, strings.NewReader("frame"))
+			So(err.Error(), ShouldEqual, "a content type must be provided")
+		})
+		Convey("Should return API errors", func() {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.ContentType = "audio/amr"
+			apiRequest.XSpeechContext = "raise/error"
+			_, err := client.SpeechToTextStream(context.Background(), apiRequest, strings.NewReader("frame"))
+			So(err.Error(), ShouldEqual, "SVC0002 - Invalid input value for message part %1 - Content-Type")
+		})
+		Convey("Should stream the audio after the grammar for custom recognition", func() {
+			apiRequest := client.NewAPIRequest(client.STTCResource)
+			apiRequest.ContentType = "audio/amr"
+			recognition, err := client.SpeechToTextCustomStream(context.Background(), apiRequest, strings.NewReader("frames"), srgsXML(), plsXML())
+			So(err, ShouldBeNil)
+			So(recognition.Recognition.Status, ShouldEqual, "OK")
+			upload := <-uploads
+			mediaType, params, err := mime.ParseMediaType(upload.Header.Get("Content-Type"))
+			So(err, ShouldBeNil)
+			So(mediaType, ShouldEqual, "multipart/x-srgs-audio")
+			form := multipart.NewReader(strings.NewReader(string(<-bodies)), params["boundary"])
+			names := []string{}
+			for {
+				part, err := form.NextPart()
+				if err != nil {
+					break
+				}
+				names = append(names, part.FormName())
+				if part.FormName() == "x-voice" {
+					data, _ := io.ReadAll(part)
+					So(string(data), ShouldEqual, "frames")
+					So(part.FileName(), ShouldEqual, "audio")
+					So(part.Header.Get("Content-Type"), ShouldEqual, "audio/amr")
+				}
+			}
+			So(names, ShouldResemble, []string{"x-dictionary", "x-grammar", "x-voice"})
+		})
+	})
+}
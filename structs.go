@@ -2,6 +2,9 @@ package attspeech
 
 import (
 	"bytes"
+	"github.com/jsgoecke/attspeech/cache"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client is an ATT Speech API client
@@ -15,6 +18,26 @@ type Client struct {
 	Secret        string
 	Tokens        map[string]*Token
 	Scope         [3]string
+	// TranscodeAudio converts WAV uploads the API does not accept to a supported format
+	TranscodeAudio bool
+	// TextChunking, when set, splits text too long for one Text to Speech request and joins the audio
+	TextChunking *ChunkOptions
+	// TTSCache, when set, returns audio already synthesized for identical Text to Speech requests
+	TTSCache cache.Store
+	// RecognitionCache, when set, returns the stored recognition for audio already recognized with the same parameters
+	RecognitionCache cache.Store
+	// Metrics, when set, receives request counts and latencies, errors, token refreshes and audio volumes
+	Metrics Metrics
+	// TracerProvider creates the spans traced for each operation, the global provider when nil
+	TracerProvider trace.TracerProvider
+	// Propagator adds the trace context to outgoing requests, the global propagator when nil
+	Propagator propagation.TextMapPropagator
+	// Logging, when set, logs requests and responses with their secrets redacted
+	Logging  *LogOptions
+	limiters map[string]*limiter
+
+	ttsCacheCounters         cacheCounters
+	recognitionCacheCounters cacheCounters
 }
 
 // APIError represents an error from the AT&T Speech API
@@ -33,6 +56,18 @@ type APIError struct {
 	} `json:"RequestError"`
 }
 
+/*
+ServiceError is an error response from the AT&T Speech API, as opposed to
+a request the client rejected before sending or could not send
+*/
+type ServiceError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// MessageID identifies the exception, e.g. SVC0002, or is "unknown" when the response could not be parsed
+	MessageID string
+	err       error
+}
+
 // Recognition represents at AT&T recognition response
 type Recognition struct {
 	Recognition struct {
@@ -0,0 +1,152 @@
+/*
+Package subtitle builds SRT and WebVTT captions from long audio recognized
+with attspeech.SpeechToTextLong. The API reports no word timings, so each
+word's time is interpolated across its segment in proportion to its length.
+
+	transcript, err := client.SpeechToTextLong(ctx, apiRequest, nil)
+	cues := subtitle.Cues(transcript, &subtitle.Options{MaxLineLength: 32})
+	err = subtitle.WriteSRT(os.Stdout, cues)
+*/
+package subtitle
+
+import (
+	"github.com/jsgoecke/attspeech"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Defaults used when Options fields are not set
+const (
+	DefaultMaxLineLength  = 42
+	DefaultMaxLines       = 2
+	DefaultMaxCueDuration = 7 * time.Second
+)
+
+// Options configures how words are grouped into cues
+type Options struct {
+	// MaxLineLength is the most characters on a line, longer words get a line of their own
+	MaxLineLength int
+	// MaxLines is the most lines in a cue
+	MaxLines int
+	// MaxCueDuration is the longest a cue stays on screen
+	MaxCueDuration time.Duration
+}
+
+// Cue is a caption shown between Start and End
+type Cue struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Lines []string      `json:"lines"`
+}
+
+// word is a recognized word with its interpolated timing
+type word struct {
+	text  string
+	start time.Duration
+	end   time.Duration
+}
+
+// withDefaults fills in unset options
+func (options *Options) withDefaults() Options {
+	result := Options{}
+	if options != nil {
+		result = *options
+	}
+	if result.MaxLineLength <= 0 {
+		result.MaxLineLength = DefaultMaxLineLength
+	}
+	if result.MaxLines <= 0 {
+		result.MaxLines = DefaultMaxLines
+	}
+	if result.MaxCueDuration <= 0 {
+		result.MaxCueDuration = DefaultMaxCueDuration
+	}
+	return result
+}
+
+/*
+Cues groups the words of each recognized segment into cues, starting a
+new cue when the lines are full, the cue would stay on screen longer than
+options.MaxCueDuration, or a new segment begins. Segments that failed or
+recognized nothing are skipped.
+*/
+func Cues(transcript *attspeech.Transcript, options *Options) []Cue {
+	settings := options.withDefaults()
+	cues := []Cue{}
+	for _, segment := range transcript.Segments {
+		var cue *Cue
+		for _, word := range segmentWords(segment) {
+			if cue != nil && !cue.fits(word, settings) {
+				cues = append(cues, *cue)
+				cue = nil
+			}
+			if cue == nil {
+				cue = &Cue{Start: word.start}
+			}
+			cue.add(word, settings.MaxLineLength)
+		}
+		if cue != nil {
+			cues = append(cues, *cue)
+		}
+	}
+	return cues
+}
+
+// fits reports whether word can be added to the cue
+func (cue *Cue) fits(word word, options Options) bool {
+	if word.end-cue.Start > options.MaxCueDuration {
+		return false
+	}
+	return cue.continues(word, options.MaxLineLength) || len(cue.Lines) < options.MaxLines
+}
+
+// add appends word to the last line, or to a new line when it would make the line too long
+func (cue *Cue) add(word word, maxLineLength int) {
+	if cue.continues(word, maxLineLength) {
+		cue.Lines[len(cue.Lines)-1] += " " + word.text
+	} else {
+		cue.Lines = append(cue.Lines, word.text)
+	}
+	cue.End = word.end
+}
+
+// continues reports whether word fits on the last line of the cue
+func (cue *Cue) continues(word word, maxLineLength int) bool {
+	if len(cue.Lines) == 0 {
+		return false
+	}
+	last := cue.Lines[len(cue.Lines)-1]
+	return utf8.RuneCountInString(last)+1+utf8.RuneCountInString(word.text) <= maxLineLength
+}
+
+/*
+segmentWords interpolates the timing of the words of a segment's best
+hypothesis, sharing the segment's duration out in proportion to the
+length of each word plus the space after it.
+*/
+func segmentWords(segment attspeech.TranscriptSegment) []word {
+	if segment.Recognition == nil || len(segment.Recognition.Recognition.NBest) == 0 {
+		return nil
+	}
+	best := segment.Recognition.Recognition.NBest[0]
+	texts := best.Words
+	if len(texts) == 0 {
+		texts = strings.Fields(best.ResultText)
+	}
+
+	total := 0
+	for _, text := range texts {
+		total += utf8.RuneCountInString(text) + 1
+	}
+	words := make([]word, 0, len(texts))
+	length := segment.End - segment.Start
+	position := 0
+	for _, text := range texts {
+		start := segment.Start + time.Duration(int64(length)*int64(position)/int64(total))
+		position += utf8.RuneCountInString(text) + 1
+		end := segment.Start + time.Duration(int64(length)*int64(position)/int64(total))
+		words = append(words, word{text: text, start: start, end: end})
+	}
+	return words
+}
@@ -0,0 +1,76 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"github.com/jsgoecke/attspeech"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCues(t *testing.T) {
+	Convey("Grouping recognized words into cues", t, func() {
+		Convey("Should interpolate word timings in proportion to their length", func() {
+			transcript := &attspeech.Transcript{Segments: []attspeech.TranscriptSegment{
+				{Start: time.Second, End: 3 * time.Second, Recognition: recognition("a bbb", "a", "bbb")},
+			}}
+			cues := Cues(transcript, nil)
+			So(len(cues), ShouldEqual, 1)
+			So(cues[0], ShouldResemble, Cue{Start: time.Second, End: 3 * time.Second, Lines: []string{"a bbb"}})
+
+			cues = Cues(transcript, &Options{MaxLineLength: 3, MaxLines: 1})
+			So(cues, ShouldResemble, []Cue{
+				{Start: time.Second, End: 1666666666, Lines: []string{"a"}},
+				{Start: 1666666666, End: 3 * time.Second, Lines: []string{"bbb"}},
+			})
+		})
+		Convey("Should wrap lines and start a new cue when the lines are full", func() {
+			transcript := &attspeech.Transcript{Segments: []attspeech.TranscriptSegment{
+				{Start: 0, End: 5 * time.Second, Recognition: recognition("the quick brown fox jumps over the lazy dog")},
+			}}
+			cues := Cues(transcript, &Options{MaxLineLength: 10, MaxLines: 2})
+			So(len(cues), ShouldEqual, 3)
+			So(cues[0].Lines, ShouldResemble, []string{"the quick", "brown fox"})
+			So(cues[1].Lines, ShouldResemble, []string{"jumps over", "the lazy"})
+			So(cues[2].Lines, ShouldResemble, []string{"dog"})
+			So(cues[1].Start, ShouldEqual, cues[0].End)
+			So(cues[2].End, ShouldEqual, 5*time.Second)
+		})
+		Convey("Should limit how long a cue stays on screen", func() {
+			transcript := &attspeech.Transcript{Segments: []attspeech.TranscriptSegment{
+				{Start: 0, End: 20 * time.Second, Recognition: recognition("one two three four")},
+			}}
+			cues := Cues(transcript, &Options{MaxCueDuration: 10 * time.Second})
+			So(len(cues), ShouldEqual, 3)
+			So(cues[0].Lines, ShouldResemble, []string{"one two"})
+			for _, cue := range cues {
+				So(cue.End-cue.Start, ShouldBeLessThanOrEqualTo, 10*time.Second)
+			}
+		})
+		Convey("Should start a new cue for each segment and skip failed segments", func() {
+			transcript := &attspeech.Transcript{Segments: []attspeech.TranscriptSegment{
+				{Start: 0, End: time.Second, Recognition: recognition("hello")},
+				{Start: time.Second, End: 2 * time.Second, Error: "bad segment"},
+				{Start: 3 * time.Second, End: 4 * time.Second, Recognition: recognition("world")},
+			}}
+			cues := Cues(transcript, nil)
+			So(len(cues), ShouldEqual, 2)
+			So(cues[1].Start, ShouldEqual, 3*time.Second)
+		})
+	})
+}
+
+// recognition builds a Recognition whose best hypothesis is text, with words when given
+func recognition(text string, words ...string) *attspeech.Recognition {
+	nbest := map[string]interface{}{"ResultText": text}
+	if len(words) > 0 {
+		nbest["Words"] = words
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"Recognition": map[string]interface{}{"Status": "OK", "NBest": []interface{}{nbest}},
+	})
+	recognition := &attspeech.Recognition{}
+	json.NewDecoder(strings.NewReader(string(data))).Decode(recognition)
+	return recognition
+}
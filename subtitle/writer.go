@@ -0,0 +1,54 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// vttEscaper escapes the characters WebVTT reserves for markup
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+/*
+WriteSRT writes cues as a SubRip (.srt) file
+
+	00:00:01,250 --> 00:00:03,500
+*/
+func WriteSRT(w io.Writer, cues []Cue) error {
+	writer := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(writer, "%d\n%s --> %s\n", i+1, timestamp(cue.Start, ','), timestamp(cue.End, ','))
+		for _, line := range cue.Lines {
+			fmt.Fprintln(writer, line)
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
+
+/*
+WriteWebVTT writes cues as a WebVTT (.vtt) file
+
+	00:00:01.250 --> 00:00:03.500
+*/
+func WriteWebVTT(w io.Writer, cues []Cue) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprint(writer, "WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(writer, "%s --> %s\n", timestamp(cue.Start, '.'), timestamp(cue.End, '.'))
+		for _, line := range cue.Lines {
+			fmt.Fprintln(writer, vttEscaper.Replace(line))
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
+
+// timestamp formats d as hh:mm:ss followed by separator and milliseconds
+func timestamp(d time.Duration, separator rune) string {
+	milliseconds := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d",
+		milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, separator, milliseconds%1000)
+}
@@ -0,0 +1,27 @@
+package subtitle
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestWriters(t *testing.T) {
+	Convey("Writing subtitle files", t, func() {
+		cues := []Cue{
+			{Start: 1250 * time.Millisecond, End: 3500 * time.Millisecond, Lines: []string{"Fish & chips", "<please>"}},
+			{Start: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Lines: []string{"Thanks."}},
+		}
+		Convey("Should write SRT", func() {
+			out := &bytes.Buffer{}
+			So(WriteSRT(out, cues), ShouldBeNil)
+			So(out.String(), ShouldEqual, "1\n00:00:01,250 --> 00:00:03,500\nFish & chips\n<please>\n\n2\n01:02:03,004 --> 01:02:05,000\nThanks.\n\n")
+		})
+		Convey("Should write WebVTT with markup characters escaped", func() {
+			out := &bytes.Buffer{}
+			So(WriteWebVTT(out, cues), ShouldBeNil)
+			So(out.String(), ShouldEqual, "WEBVTT\n\n00:00:01.250 --> 00:00:03.500\nFish &amp; chips\n&lt;please&gt;\n\n01:02:03.004 --> 01:02:05.000\nThanks.\n\n")
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package attspeech
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"time"
+)
+
+// TracerName is the instrumentation name of the spans the client creates
+const TracerName = "github.com/jsgoecke/attspeech"
+
+// Span attribute keys
+const (
+	ResourceKey      = attribute.Key("attspeech.resource")
+	ContentTypeKey   = attribute.Key("attspeech.content_type")
+	AudioDurationKey = attribute.Key("attspeech.audio.duration")
+	VoiceKey         = attribute.Key("attspeech.voice")
+	ScopeKey         = attribute.Key("attspeech.scope")
+	CacheHitKey      = attribute.Key("attspeech.cache.hit")
+	StatusCodeKey    = attribute.Key("http.response.status_code")
+	MessageIDKey     = attribute.Key("attspeech.message_id")
+)
+
+/*
+startSpan starts a span for an operation as a child of any span in ctx,
+using client.TracerProvider or the global provider when it is not set.
+Spans are recorded by whichever provider is installed:
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recognition, err := client.SpeechToTextContext(ctx, apiRequest)
+*/
+func (client *Client) startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := client.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(TracerName).Start(ctx, "attspeech."+name, trace.WithAttributes(attributes...))
+}
+
+// endSpan marks the span as failed when err is set, with its secrets redacted, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		err = redactError(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext adds the trace context of ctx to the headers of an outgoing request
+func (client *Client) injectTraceContext(ctx context.Context, req *http.Request) {
+	propagator := client.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// setAudioDuration records the length of audio on the current span
+func setAudioDuration(ctx context.Context, duration time.Duration) {
+	trace.SpanFromContext(ctx).SetAttributes(AudioDurationKey.Float64(duration.Seconds()))
+}
@@ -0,0 +1,122 @@
+package attspeech
+
+import (
+	"bytes"
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	Convey("Tracing speech operations", t, func() {
+		traceparents := make(chan string, 10)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			traceparents <- req.Header.Get("Traceparent")
+			switch {
+			case strings.Contains(req.RequestURI, OauthResource):
+				w.Write(oauthJSON())
+			case strings.Contains(req.RequestURI, TTSResource):
+				w.WriteHeader(400)
+				w.Write(policyErrorJSON())
+			default:
+				w.Write(recognitionJSON())
+			}
+		}))
+		defer ts.Close()
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		client := New("foo", "bar", ts.URL)
+		client.TracerProvider = provider
+		client.Propagator = propagation.TraceContext{}
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "call flow")
+		So(client.SetAuthTokensContext(ctx), ShouldBeNil)
+
+		spans := func(name string) []sdktrace.ReadOnlySpan {
+			found := []sdktrace.ReadOnlySpan{}
+			for _, span := range recorder.Ended() {
+				if span.Name() == name {
+					found = append(found, span)
+				}
+			}
+			return found
+		}
+		attributes := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+			m := map[attribute.Key]attribute.Value{}
+			for _, kv := range span.Attributes() {
+				m[kv.Key] = kv.Value
+			}
+			return m
+		}
+
+		Convey("Should trace fetching each token under the caller's span", func() {
+			auth := spans("attspeech.SetAuthTokens")
+			So(len(auth), ShouldEqual, 1)
+			So(auth[0].Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+			tokens := spans("attspeech.FetchToken")
+			So(len(tokens), ShouldEqual, 3)
+			So(tokens[0].Parent().SpanID(), ShouldEqual, auth[0].SpanContext().SpanID())
+			So(attributes(tokens[2])[ScopeKey].AsString(), ShouldEqual, "TTS")
+			So(attributes(tokens[2])[StatusCodeKey].AsInt64(), ShouldEqual, 200)
+		})
+		Convey("Should record token errors without the client secret", func() {
+			unreachable := httptest.NewServer(http.NotFoundHandler())
+			unreachable.Close()
+			client.APIBase = unreachable.URL
+			So(client.SetAuthTokensContext(ctx), ShouldNotBeNil)
+			for _, span := range append(spans("attspeech.SetAuthTokens"), spans("attspeech.FetchToken")...) {
+				if span.Status().Code != codes.Error {
+					continue
+				}
+				So(span.Status().Description, ShouldContainSubstring, "client_secret=[REDACTED]")
+				So(span.Status().Description, ShouldNotContainSubstring, "client_secret=bar")
+				for _, event := range span.Events() {
+					for _, kv := range event.Attributes {
+						So(kv.Value.Emit(), ShouldNotContainSubstring, "client_secret=bar")
+					}
+				}
+			}
+			So(spans("attspeech.FetchToken")[3].Status().Code, ShouldEqual, codes.Error)
+		})
+		Convey("Should propagate the trace context to the API", func() {
+			traceparent := <-traceparents
+			So(traceparent, ShouldStartWith, "00-"+parent.SpanContext().TraceID().String()+"-")
+		})
+		Convey("Should trace recognitions with the audio they uploaded", func() {
+			apiRequest := client.NewAPIRequest(client.STTResource)
+			apiRequest.Data = bytes.NewBuffer(wavBytes(1, 1, 8000, 16, 12000))
+			_, err := client.SpeechToTextContext(ctx, apiRequest)
+			So(err, ShouldBeNil)
+			stt := spans("attspeech.SpeechToText")
+			So(len(stt), ShouldEqual, 1)
+			So(stt[0].Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+			So(attributes(stt[0])[ContentTypeKey].AsString(), ShouldEqual, "audio/wav")
+			So(attributes(stt[0])[AudioDurationKey].AsFloat64(), ShouldEqual, 1.5)
+			So(attributes(stt[0])[CacheHitKey].AsBool(), ShouldBeFalse)
+			posts := spans("attspeech.POST")
+			So(len(posts), ShouldEqual, 1)
+			So(posts[0].Parent().SpanID(), ShouldEqual, stt[0].SpanContext().SpanID())
+			So(attributes(posts[0])[ResourceKey].AsString(), ShouldEqual, STTResource)
+		})
+		Convey("Should record errors with their MessageId", func() {
+			apiRequest := client.NewAPIRequest(client.TTSResource)
+			apiRequest.Text = "hello"
+			apiRequest.VoiceName = "mike"
+			_, err := client.TextToSpeechContext(ctx, apiRequest)
+			So(err, ShouldNotBeNil)
+			tts := spans("attspeech.TextToSpeech")
+			So(len(tts), ShouldEqual, 1)
+			So(tts[0].Status().Code, ShouldEqual, codes.Error)
+			So(attributes(tts[0])[VoiceKey].AsString(), ShouldEqual, "mike")
+			So(attributes(tts[0])[MessageIDKey].AsString(), ShouldEqual, "SVC0002")
+			So(attributes(spans("attspeech.POST")[0])[StatusCodeKey].AsInt64(), ShouldEqual, 400)
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package attspeech
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// The Accept types Text to Speech can return
+const (
+	AcceptWAV   = "audio/x-wav"
+	AcceptAMR   = "audio/amr"
+	AcceptAMRWB = "audio/amr-wb"
+)
+
+// Voice describes a Text to Speech voice and what it can produce
+type Voice struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Gender   string `json:"gender"`
+	// SampleRates are the rates, in Hz, the voice is synthesized at
+	SampleRates []int `json:"sample_rates"`
+	// Formats are the Accept types the voice can be returned as
+	Formats []string `json:"formats"`
+	// DefaultTempo and DefaultVolume are sent when a request leaves Tempo or Volume unset
+	DefaultTempo  int `json:"default_tempo"`
+	DefaultVolume int `json:"default_volume"`
+}
+
+// voiceFormats are the output formats every AT&T voice supports
+var voiceFormats = []string{AcceptWAV, AcceptAMR, AcceptAMRWB}
+
+// voices are the Text to Speech voices documented by the AT&T Speech API, by lower case name
+var voices = map[string]*Voice{}
+
+func init() {
+	for _, voice := range []*Voice{
+		{Name: "crystal", Language: "en-US", Gender: "female"},
+		{Name: "mike", Language: "en-US", Gender: "male"},
+		{Name: "rich", Language: "en-US", Gender: "male"},
+		{Name: "lauren", Language: "en-US", Gender: "female"},
+		{Name: "claire", Language: "en-US", Gender: "female"},
+		{Name: "rosa", Language: "es-US", Gender: "female"},
+		{Name: "alberto", Language: "es-US", Gender: "male"},
+	} {
+		voice.SampleRates = []int{8000, 16000}
+		voice.Formats = voiceFormats
+		RegisterVoice(voice)
+	}
+}
+
+/*
+RegisterVoice adds a copy of voice to the registry, or replaces the voice
+with the same name, so voices added to the service can be used before
+this library knows about them. A DefaultVolume of zero is taken as unset
+and becomes DefaultVolume. It is not safe to call concurrently with
+requests.
+
+	attspeech.RegisterVoice(&attspeech.Voice{Name: "julia", Language: "en-US", Gender: "female",
+		SampleRates: []int{8000, 16000}, Formats: []string{attspeech.AcceptWAV}})
+*/
+func RegisterVoice(voice *Voice) {
+	registered := copyVoice(voice)
+	if registered.DefaultVolume == 0 {
+		registered.DefaultVolume = DefaultVolume
+	}
+	voices[strings.ToLower(voice.Name)] = registered
+}
+
+// copyVoice returns a copy of voice that shares none of its slices, so the registry cannot be changed through it
+func copyVoice(voice *Voice) *Voice {
+	copied := *voice
+	copied.SampleRates = append([]int(nil), voice.SampleRates...)
+	copied.Formats = append([]string(nil), voice.Formats...)
+	return &copied
+}
+
+// LookupVoice returns a copy of the voice named name, ignoring case
+func LookupVoice(name string) (*Voice, bool) {
+	voice, ok := voices[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	return copyVoice(voice), true
+}
+
+// Voices returns copies of the registered voices sorted by language and name, optionally only those for language
+func Voices(language string) []*Voice {
+	list := []*Voice{}
+	for _, voice := range voices {
+		if language == "" || strings.EqualFold(voice.Language, language) {
+			list = append(list, copyVoice(voice))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Language != list[j].Language {
+			return list[i].Language < list[j].Language
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// wavAliases are the other media types WAV is known by, which Text to Speech returns as AcceptWAV
+var wavAliases = map[string]bool{"audio/wav": true, "audio/wave": true, "audio/vnd.wave": true}
+
+// acceptFormat returns the media type of an Accept type, with WAV aliases as AcceptWAV
+func acceptFormat(accept string) string {
+	accept = mediaType(accept)
+	if wavAliases[accept] {
+		return AcceptWAV
+	}
+	return accept
+}
+
+// Supports reports whether the voice can be returned as the Accept type accept, WAV aliases such as audio/wav included
+func (voice *Voice) Supports(accept string) bool {
+	accept = acceptFormat(accept)
+	for _, format := range voice.Formats {
+		if format == accept {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+checkVoice rejects Text to Speech requests for an unknown voice, a voice
+in a different language than ContentLanguage, or an audio Accept type the
+voice cannot produce. An Accept that is not an audio type, such as the
+application/json NewAPIRequest sets, leaves the format to the service. A
+WAV alias such as audio/wav is sent as AcceptWAV, the type the service
+documents.
+*/
+func checkVoice(apiRequest *APIRequest) error {
+	if acceptFormat(apiRequest.Accept) == AcceptWAV {
+		apiRequest.Accept = AcceptWAV
+	}
+	if apiRequest.VoiceName == "" {
+		return nil
+	}
+	voice, ok := LookupVoice(apiRequest.VoiceName)
+	if !ok {
+		names := []string{}
+		for _, voice := range Voices("") {
+			names = append(names, voice.Name)
+		}
+		return errors.New("unknown voice " + apiRequest.VoiceName + ", expected one of " + strings.Join(names, ", "))
+	}
+	if apiRequest.ContentLanguage != "" && !strings.EqualFold(apiRequest.ContentLanguage, voice.Language) {
+		return errors.New("voice " + voice.Name + " speaks " + voice.Language + ", not " + apiRequest.ContentLanguage)
+	}
+	if strings.HasPrefix(mediaType(apiRequest.Accept), "audio/") && !voice.Supports(apiRequest.Accept) {
+		return errors.New("voice " + voice.Name + " cannot produce " + apiRequest.Accept + ", expected one of " + strings.Join(voice.Formats, ", "))
+	}
+	return nil
+}
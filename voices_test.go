@@ -0,0 +1,85 @@
+package attspeech
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestVoices(t *testing.T) {
+	Convey("Looking up voices", t, func() {
+		Convey("Should find voices ignoring case", func() {
+			voice, ok := LookupVoice("Crystal")
+			So(ok, ShouldBeTrue)
+			So(voice.Language, ShouldEqual, "en-US")
+			So(voice.Supports("audio/AMR-WB; rate=16000"), ShouldBeTrue)
+			So(voice.Supports("audio/mpeg"), ShouldBeFalse)
+			So(voice.Supports("audio/wav"), ShouldBeTrue)
+			So(voice.Supports("audio/Wave"), ShouldBeTrue)
+			_, ok = LookupVoice("crystl")
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Should list voices by language", func() {
+			So(len(Voices("")), ShouldEqual, 7)
+			spanish := Voices("es-us")
+			So(len(spanish), ShouldEqual, 2)
+			So(spanish[0].Name, ShouldEqual, "alberto")
+		})
+		Convey("Should register new voices", func() {
+			julia := &Voice{Name: "julia", Language: "en-US", Formats: []string{AcceptWAV}}
+			RegisterVoice(julia)
+			defer delete(voices, "julia")
+			julia.Language = "es-US"
+			julia.Formats[0] = AcceptAMR
+			voice, ok := LookupVoice("julia")
+			So(ok, ShouldBeTrue)
+			So(voice, ShouldNotPointTo, julia)
+			So(voice.Language, ShouldEqual, "en-US")
+			So(voice.DefaultVolume, ShouldEqual, DefaultVolume)
+			So(julia.DefaultVolume, ShouldEqual, 0)
+
+			voice.Language = "fr-FR"
+			voice.Formats[0] = AcceptAMR
+			listed := Voices("en-US")[0]
+			listed.Formats[0] = AcceptAMR
+			voice, _ = LookupVoice("julia")
+			So(voice.Language, ShouldEqual, "en-US")
+			So(voice.Formats, ShouldResemble, []string{AcceptWAV})
+			listed, _ = LookupVoice(listed.Name)
+			So(listed.Formats[0], ShouldEqual, AcceptWAV)
+			So(voice.Supports(AcceptAMR), ShouldBeFalse)
+		})
+	})
+}
+
+func TestCheckVoice(t *testing.T) {
+	Convey("Validating the voice of a Text to Speech request", t, func() {
+		apiRequest := &APIRequest{Accept: "application/json", Text: "hola"}
+		Convey("Should accept requests without a voice or with a matching voice", func() {
+			So(checkVoice(apiRequest), ShouldBeNil)
+			apiRequest.VoiceName = "rosa"
+			apiRequest.ContentLanguage = "es-US"
+			apiRequest.Accept = AcceptAMR
+			So(checkVoice(apiRequest), ShouldBeNil)
+		})
+		Convey("Should reject unknown voices", func() {
+			apiRequest.VoiceName = "crystl"
+			So(checkVoice(apiRequest).Error(), ShouldEqual, "unknown voice crystl, expected one of claire, crystal, lauren, mike, rich, alberto, rosa")
+		})
+		Convey("Should reject a language the voice does not speak", func() {
+			apiRequest.VoiceName = "rosa"
+			apiRequest.ContentLanguage = "en-US"
+			So(checkVoice(apiRequest).Error(), ShouldEqual, "voice rosa speaks es-US, not en-US")
+		})
+		Convey("Should reject audio formats the voice cannot produce", func() {
+			apiRequest.VoiceName = "mike"
+			apiRequest.Accept = "audio/mpeg"
+			So(checkVoice(apiRequest).Error(), ShouldEqual, "voice mike cannot produce audio/mpeg, expected one of audio/x-wav, audio/amr, audio/amr-wb")
+		})
+		Convey("Should accept WAV aliases and send them as audio/x-wav", func() {
+			apiRequest.VoiceName = "mike"
+			apiRequest.Accept = "audio/wav"
+			So(checkVoice(apiRequest), ShouldBeNil)
+			So(apiRequest.Accept, ShouldEqual, AcceptWAV)
+		})
+	})
+}
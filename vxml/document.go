@@ -0,0 +1,187 @@
+/*
+Package vxml interprets a subset of VoiceXML 2.0, so call flows written
+for VoiceXML platforms can run against the AT&T Speech API. Prompts are
+synthesized with TextToSpeech and fields are recognized with
+SpeechToTextCustom using their inline SRGS grammars. Audio is played and
+recorded through an IO, which tests can script:
+
+	document, err := vxml.Parse(data)
+	interpreter := &vxml.Interpreter{Client: client, IO: phone}
+	variables, err := interpreter.Run(ctx, document)
+
+The supported elements are <vxml>, <var>, <form>, <block>, <field>,
+<prompt> with <value>, inline <grammar>, <filled>, <nomatch>, <noinput>,
+<if>, <elseif>, <else>, <assign>, <clear>, <goto>, <reprompt>, <log> and
+<exit>. Expressions are a small subset of ECMAScript: literals, variables,
+comparisons, &&, ||, ! and +.
+*/
+package vxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// GrammarNamespace is the SRGS namespace added to inline grammars that inherit the VoiceXML one
+const GrammarNamespace = "http://www.w3.org/2001/06/grammar"
+
+// Document is a parsed VoiceXML document
+type Document struct {
+	root  *node
+	forms []*node
+}
+
+// node is an element or run of text, keeping its markup as written for inline grammars
+type node struct {
+	name  string
+	attrs map[string]string
+	// start and end are the element's tags, and text the decoded text of a text node, as written
+	start    string
+	end      string
+	raw      string
+	text     string
+	children []*node
+}
+
+// attr returns an attribute of the element, or "" when it is not set
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+// render returns the node as it was written
+func (n *node) render() string {
+	if n.name == "" {
+		return n.raw
+	}
+	var rendered strings.Builder
+	rendered.WriteString(n.start)
+	for _, child := range n.children {
+		rendered.WriteString(child.render())
+	}
+	rendered.WriteString(n.end)
+	return rendered.String()
+}
+
+// elements returns the child elements named name, or all child elements when name is empty
+func (n *node) elements(name string) []*node {
+	elements := []*node{}
+	for _, child := range n.children {
+		if child.name != "" && (name == "" || child.name == name) {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+/*
+Parse parses a VoiceXML document, checking that it has a <vxml> root with
+at least one <form>, that form ids are unique, and that every field has a
+name and an inline grammar.
+*/
+func Parse(data []byte) (*Document, error) {
+	root, err := parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	document := &Document{root: root, forms: root.elements("form")}
+	if len(document.forms) == 0 {
+		return nil, errors.New("a VoiceXML document must have at least one <form>")
+	}
+	ids := map[string]bool{}
+	for _, form := range document.forms {
+		if id := form.attr("id"); id != "" {
+			if ids[id] {
+				return nil, errors.New("duplicate form id " + id)
+			}
+			ids[id] = true
+		}
+		for _, field := range form.elements("field") {
+			if field.attr("name") == "" {
+				return nil, errors.New("every <field> must have a name")
+			}
+			if _, err := fieldGrammar(field); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return document, nil
+}
+
+// form returns the form with id, or the first form when id is empty
+func (document *Document) form(id string) (*node, error) {
+	if id == "" {
+		return document.forms[0], nil
+	}
+	for _, form := range document.forms {
+		if form.attr("id") == id {
+			return form, nil
+		}
+	}
+	return nil, errors.New("no form with id " + id)
+}
+
+/*
+fieldGrammar returns the inline SRGS grammar of a field as written,
+declaring the SRGS namespace when the grammar inherits the VoiceXML one
+*/
+func fieldGrammar(field *node) (string, error) {
+	grammars := field.elements("grammar")
+	if len(grammars) == 0 {
+		return "", errors.New("field " + field.attr("name") + " must have an inline <grammar>")
+	}
+	grammar := grammars[0]
+	if grammar.attr("src") != "" {
+		return "", errors.New("field " + field.attr("name") + " refers to an external grammar, only inline grammars are supported")
+	}
+	rendered := grammar.render()
+	if !strings.Contains(grammar.start, "xmlns=") {
+		rendered = `<grammar xmlns="` + GrammarNamespace + `"` + strings.TrimPrefix(rendered, "<grammar")
+	}
+	return rendered, nil
+}
+
+// parse builds the tree of the document's <vxml> element
+func parse(document string) (*node, error) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	root := &node{}
+	stack := []*node{root}
+	offset := int64(0)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("invalid VoiceXML: " + err.Error())
+		}
+		raw := document[offset:decoder.InputOffset()]
+		offset = decoder.InputOffset()
+		parent := stack[len(stack)-1]
+		switch token := token.(type) {
+		case xml.StartElement:
+			child := &node{name: token.Name.Local, start: raw, attrs: map[string]string{}}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == "" {
+					child.attrs[attr.Name.Local] = attr.Value
+				}
+			}
+			parent.children = append(parent.children, child)
+			stack = append(stack, child)
+		case xml.EndElement:
+			parent.end = raw
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if parent != root {
+				parent.children = append(parent.children, &node{raw: raw, text: string(token)})
+			}
+		}
+	}
+	for _, child := range root.children {
+		if child.name == "vxml" {
+			return child, nil
+		}
+	}
+	return nil, errors.New("VoiceXML must have a <vxml> root element")
+}
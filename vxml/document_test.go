@@ -0,0 +1,50 @@
+package vxml
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Parsing VoiceXML", t, func() {
+		Convey("Should parse forms and their inline grammars", func() {
+			document, err := Parse([]byte(coffeeDocument))
+			So(err, ShouldBeNil)
+			So(len(document.forms), ShouldEqual, 2)
+			form, err := document.form("tea")
+			So(err, ShouldBeNil)
+			So(form.attr("id"), ShouldEqual, "tea")
+			_, err = document.form("juice")
+			So(err.Error(), ShouldEqual, "no form with id juice")
+
+			first, _ := document.form("")
+			grammar, err := fieldGrammar(first.elements("field")[0])
+			So(err, ShouldBeNil)
+			So(grammar, ShouldStartWith, `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="drink" mode="voice">`)
+			So(grammar, ShouldEndWith, `<item>coffee</item><item>tea</item></one-of></rule></grammar>`)
+		})
+		Convey("Should keep grammars that declare their namespace", func() {
+			document, err := Parse([]byte(`<vxml><form><field name="answer">
+				<grammar xmlns="http://www.w3.org/2001/06/grammar" root="yes"><rule id="yes">yes</rule></grammar>
+			</field></form></vxml>`))
+			So(err, ShouldBeNil)
+			grammar, _ := fieldGrammar(document.forms[0].elements("field")[0])
+			So(grammar, ShouldEqual, `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="yes"><rule id="yes">yes</rule></grammar>`)
+		})
+		Convey("Should reject documents it cannot run", func() {
+			documents := map[string]string{
+				`<speak>Hello</speak>`:                                "VoiceXML must have a <vxml> root element",
+				`<vxml><form>`:                                        "invalid VoiceXML: XML syntax error on line 1: unexpected EOF",
+				`<vxml><var name="x"/></vxml>`:                        "a VoiceXML document must have at least one <form>",
+				`<vxml><form id="a"/><form id="a"/></vxml>`:           "duplicate form id a",
+				`<vxml><form><field><grammar/></field></form></vxml>`: "every <field> must have a name",
+				`<vxml><form><field name="drink"/></form></vxml>`:     "field drink must have an inline <grammar>",
+				`<vxml><form><field name="drink"><grammar src="drinks.grxml"/></field></form></vxml>`: "field drink refers to an external grammar, only inline grammars are supported",
+			}
+			for document, message := range documents {
+				_, err := Parse([]byte(document))
+				So(err.Error(), ShouldEqual, message)
+			}
+		})
+	})
+}
@@ -0,0 +1,311 @@
+package vxml
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+Variables holds the values of VoiceXML variables: strings, float64
+numbers and bools. A variable that is absent is undefined.
+*/
+type Variables map[string]interface{}
+
+// expression evaluates an expression by recursive descent, one precedence level per method
+type expression struct {
+	tokens    []string
+	position  int
+	variables Variables
+}
+
+/*
+evaluate evaluates an ECMAScript expression of literals, variables,
+comparisons, +, -, &&, || and ! against variables
+*/
+func evaluate(source string, variables Variables) (interface{}, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	expr := &expression{tokens: tokens, variables: variables}
+	value, err := expr.or()
+	if err != nil {
+		return nil, err
+	}
+	if expr.position < len(tokens) {
+		return nil, errors.New("unexpected " + strconv.Quote(tokens[expr.position]) + " in expression " + strconv.Quote(source))
+	}
+	return value, nil
+}
+
+// operators are the operator tokens, longest first so they are matched greedily
+var operators = []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "(", ")"}
+
+// tokenize splits an expression into literals, names and operators
+func tokenize(source string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(source); {
+		char := rune(source[i])
+		switch {
+		case unicode.IsSpace(char):
+			i++
+		case char == '\'' || char == '"':
+			end := strings.IndexByte(source[i+1:], source[i])
+			if end < 0 {
+				return nil, errors.New("unterminated string in expression " + strconv.Quote(source))
+			}
+			tokens = append(tokens, source[i:i+end+2])
+			i += end + 2
+		case isNameChar(char) || char == '.':
+			start := i
+			for i < len(source) && (isNameChar(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[i:], operator) {
+					tokens = append(tokens, operator)
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.New("unexpected " + strconv.Quote(string(char)) + " in expression " + strconv.Quote(source))
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// isNameChar reports whether char may appear in a name or number
+func isNameChar(char rune) bool {
+	return char == '_' || char == '$' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// peek returns the next token, or "" at the end
+func (expr *expression) peek() string {
+	if expr.position < len(expr.tokens) {
+		return expr.tokens[expr.position]
+	}
+	return ""
+}
+
+// or evaluates a || b
+func (expr *expression) or() (interface{}, error) {
+	left, err := expr.and()
+	for err == nil && expr.peek() == "||" {
+		expr.position++
+		var right interface{}
+		if right, err = expr.and(); truthy(left) {
+			continue
+		}
+		left = right
+	}
+	return left, err
+}
+
+// and evaluates a && b
+func (expr *expression) and() (interface{}, error) {
+	left, err := expr.equality()
+	for err == nil && expr.peek() == "&&" {
+		expr.position++
+		var right interface{}
+		if right, err = expr.equality(); !truthy(left) {
+			continue
+		}
+		left = right
+	}
+	return left, err
+}
+
+// equality evaluates a == b and a != b, strict equality comparing the same way
+func (expr *expression) equality() (interface{}, error) {
+	left, err := expr.relational()
+	for err == nil {
+		operator := expr.peek()
+		if operator != "==" && operator != "!=" && operator != "===" && operator != "!==" {
+			break
+		}
+		expr.position++
+		var right interface{}
+		if right, err = expr.relational(); err == nil {
+			left = equal(left, right) == (operator == "==" || operator == "===")
+		}
+	}
+	return left, err
+}
+
+// relational evaluates <, <=, > and >=, comparing numbers numerically and anything else as strings
+func (expr *expression) relational() (interface{}, error) {
+	left, err := expr.additive()
+	for err == nil {
+		operator := expr.peek()
+		if operator != "<" && operator != "<=" && operator != ">" && operator != ">=" {
+			break
+		}
+		expr.position++
+		var right interface{}
+		if right, err = expr.additive(); err != nil {
+			break
+		}
+		comparison := 0
+		leftNumber, leftOK := left.(float64)
+		rightNumber, rightOK := right.(float64)
+		if leftOK && rightOK {
+			switch {
+			case leftNumber < rightNumber:
+				comparison = -1
+			case leftNumber > rightNumber:
+				comparison = 1
+			}
+		} else {
+			comparison = strings.Compare(toString(left), toString(right))
+		}
+		switch operator {
+		case "<":
+			left = comparison < 0
+		case "<=":
+			left = comparison <= 0
+		case ">":
+			left = comparison > 0
+		case ">=":
+			left = comparison >= 0
+		}
+	}
+	return left, err
+}
+
+// additive evaluates a + b, concatenating when either is a string, and a - b
+func (expr *expression) additive() (interface{}, error) {
+	left, err := expr.unary()
+	for err == nil && (expr.peek() == "+" || expr.peek() == "-") {
+		operator := expr.peek()
+		expr.position++
+		var right interface{}
+		if right, err = expr.unary(); err != nil {
+			break
+		}
+		_, leftString := left.(string)
+		_, rightString := right.(string)
+		switch {
+		case operator == "+" && (leftString || rightString):
+			left = toString(left) + toString(right)
+		case operator == "+":
+			left = toNumber(left) + toNumber(right)
+		default:
+			left = toNumber(left) - toNumber(right)
+		}
+	}
+	return left, err
+}
+
+// unary evaluates !a and -a
+func (expr *expression) unary() (interface{}, error) {
+	switch expr.peek() {
+	case "!":
+		expr.position++
+		value, err := expr.unary()
+		return !truthy(value), err
+	case "-":
+		expr.position++
+		value, err := expr.unary()
+		return -toNumber(value), err
+	}
+	return expr.primary()
+}
+
+// primary evaluates a literal, a variable or a parenthesized expression
+func (expr *expression) primary() (interface{}, error) {
+	token := expr.peek()
+	expr.position++
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of expression")
+	case token == "(":
+		value, err := expr.or()
+		if err == nil && expr.peek() != ")" {
+			return nil, errors.New("missing ) in expression")
+		}
+		expr.position++
+		return value, err
+	case token[0] == '\'' || token[0] == '"':
+		return token[1 : len(token)-1], nil
+	case token == "true", token == "false":
+		return token == "true", nil
+	case token == "undefined", token == "null":
+		return nil, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		number, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, errors.New("invalid number " + strconv.Quote(token))
+		}
+		return number, nil
+	case isNameChar(rune(token[0])):
+		return expr.variables[token], nil
+	}
+	return nil, errors.New("unexpected " + strconv.Quote(token) + " in expression")
+}
+
+// truthy converts a value to a bool as ECMAScript does
+func truthy(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	}
+	return true
+}
+
+// equal compares numbers numerically and anything else by its string form, undefined equaling only itself
+func equal(left interface{}, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	leftNumber, leftOK := left.(float64)
+	rightNumber, rightOK := right.(float64)
+	if leftOK && rightOK {
+		return leftNumber == rightNumber
+	}
+	return toString(left) == toString(right)
+}
+
+// toString converts a value to a string as ECMAScript does
+func toString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "undefined"
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	}
+	return ""
+}
+
+// toNumber converts a value to a number, strings that are not numbers being 0
+func toNumber(value interface{}) float64 {
+	switch value := value.(type) {
+	case bool:
+		if value {
+			return 1
+		}
+	case float64:
+		return value
+	case string:
+		number, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number
+	}
+	return 0
+}
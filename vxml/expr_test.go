@@ -0,0 +1,58 @@
+package vxml
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	Convey("Evaluating expressions", t, func() {
+		variables := Variables{"drink": "coffee", "size": 2.0, "drink$.confidence": 0.8, "member": true}
+
+		Convey("Should evaluate literals, variables and operators", func() {
+			expressions := map[string]interface{}{
+				`'tea'`:                              "tea",
+				`"it's"`:                             "it's",
+				`1.5`:                                1.5,
+				`drink`:                              "coffee",
+				`missing`:                            nil,
+				`drink == 'coffee'`:                  true,
+				`drink != "coffee"`:                  false,
+				`size === 2`:                         true,
+				`size + 1`:                           3.0,
+				`size - 3`:                           -1.0,
+				`'a ' + drink + size`:                "a coffee2",
+				`drink$.confidence >= 0.5`:           true,
+				`size < 10 && drink == 'coffee'`:     true,
+				`!member || size > 5`:                false,
+				`missing == undefined`:               true,
+				`missing || 'default'`:               "default",
+				`-(size + 1)`:                        -3.0,
+				`drink == 'tea' || (member && true)`: true,
+			}
+			for expression, expected := range expressions {
+				value, err := evaluate(expression, variables)
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, expected)
+			}
+		})
+		Convey("Should report invalid expressions", func() {
+			_, err := evaluate(`drink == 'tea`, variables)
+			So(err.Error(), ShouldEqual, `unterminated string in expression "drink == 'tea"`)
+			_, err = evaluate(`size * 2`, variables)
+			So(err.Error(), ShouldEqual, `unexpected "*" in expression "size * 2"`)
+			_, err = evaluate(`(size`, variables)
+			So(err.Error(), ShouldEqual, "missing ) in expression")
+			_, err = evaluate(`size 2`, variables)
+			So(err.Error(), ShouldEqual, `unexpected "2" in expression "size 2"`)
+		})
+		Convey("Should convert values as ECMAScript does", func() {
+			So(truthy(""), ShouldBeFalse)
+			So(truthy(0.0), ShouldBeFalse)
+			So(truthy("0"), ShouldBeTrue)
+			So(toString(nil), ShouldEqual, "undefined")
+			So(toString(2.50), ShouldEqual, "2.5")
+			So(toNumber(" 7 "), ShouldEqual, 7)
+		})
+	})
+}
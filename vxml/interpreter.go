@@ -0,0 +1,461 @@
+package vxml
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/dialog"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is how long a field waits for an answer when Interpreter.Timeout is not set
+	DefaultTimeout = dialog.DefaultTimeout
+	// DefaultMaxAttempts is how many nomatch and noinput events a field may raise when Interpreter.MaxAttempts is not set
+	DefaultMaxAttempts = 3
+)
+
+/*
+IO plays prompts to the caller and records their answers. It is the
+dialog package's IO, so the same audio can drive both.
+*/
+type IO = dialog.IO
+
+// Interpreter runs VoiceXML documents
+type Interpreter struct {
+	Client *attspeech.Client
+	// IO plays prompts and records answers, a recording with no audio raising noinput
+	IO IO
+	// Voice is the voice prompts are spoken with, the API's default when empty
+	Voice string
+	// Timeout is how long each field waits for an answer
+	Timeout time.Duration
+	// MaxAttempts ends the dialog with an error when a field raises more nomatch and noinput events than this
+	MaxAttempts int
+	// Logger receives <log> messages, slog.Default() when nil
+	Logger *slog.Logger
+}
+
+// flow is how executable content continues
+type flow int
+
+const (
+	proceed flow = iota
+	gotoForm
+	exitDialog
+	reprompt
+)
+
+// outcome is the result of running executable content
+type outcome struct {
+	flow   flow
+	target string
+}
+
+// execution is the state of one run of a document
+type execution struct {
+	interpreter *Interpreter
+	ctx         context.Context
+	document    *Document
+	variables   Variables
+	// done holds the blocks that have run in the current form
+	done map[*node]bool
+	// promptCounts and eventCounts count the visits to each field and the events raised in it
+	promptCounts map[*node]int
+	eventCounts  map[*node]map[string]int
+	// skipPrompts is set after an event handler, so prompts are only played again on <reprompt>
+	skipPrompts bool
+}
+
+/*
+Run runs a document from its first form until it exits or a form
+completes without a <goto>, returning the variables it ends with.
+
+	variables, err := interpreter.Run(ctx, document)
+	fmt.Println(variables["drink"])
+*/
+func (interpreter *Interpreter) Run(ctx context.Context, document *Document) (Variables, error) {
+	d := &execution{interpreter: interpreter, ctx: ctx, document: document, variables: Variables{}}
+	if _, err := d.execute(document.root.elements("var")); err != nil {
+		return d.variables, err
+	}
+	form, err := document.form("")
+	for err == nil {
+		var result outcome
+		if result, err = d.runForm(form); err != nil || result.flow != gotoForm {
+			break
+		}
+		form, err = document.form(result.target)
+	}
+	return d.variables, err
+}
+
+/*
+runForm is the form interpretation algorithm: it visits the first block
+that has not run or field that is not filled, whose cond allows it, until
+none remain or executable content leaves the form
+*/
+func (d *execution) runForm(form *node) (outcome, error) {
+	d.done = map[*node]bool{}
+	d.promptCounts = map[*node]int{}
+	d.eventCounts = map[*node]map[string]int{}
+	for _, field := range form.elements("field") {
+		delete(d.variables, field.attr("name"))
+	}
+	if result, err := d.execute(form.elements("var")); err != nil || result.flow != proceed {
+		return result, err
+	}
+	for {
+		if err := d.ctx.Err(); err != nil {
+			return outcome{}, err
+		}
+		item, err := d.selectItem(form)
+		if err != nil || item == nil {
+			return outcome{flow: exitDialog}, err
+		}
+		var result outcome
+		if item.name == "block" {
+			d.done[item] = true
+			result, err = d.execute(item.children)
+		} else {
+			result, err = d.collect(form, item)
+		}
+		if err != nil || result.flow == gotoForm || result.flow == exitDialog {
+			return result, err
+		}
+	}
+}
+
+// selectItem returns the next form item to visit, or nil when the form is complete
+func (d *execution) selectItem(form *node) (*node, error) {
+	for _, item := range form.elements("") {
+		switch item.name {
+		case "block":
+			if d.done[item] {
+				continue
+			}
+		case "field":
+			if _, filled := d.variables[item.attr("name")]; filled {
+				continue
+			}
+		default:
+			continue
+		}
+		if ok, err := d.condition(item); err != nil || ok {
+			return item, err
+		}
+	}
+	return nil, nil
+}
+
+// collect plays a field's prompts, recognizes the answer and runs the filled or event handlers
+func (d *execution) collect(form *node, field *node) (outcome, error) {
+	d.promptCounts[field]++
+	if !d.skipPrompts {
+		if err := d.playPrompts(field); err != nil {
+			return outcome{}, err
+		}
+	}
+	d.skipPrompts = false
+
+	recognition, err := d.recognize(field)
+	if err != nil {
+		return outcome{}, err
+	}
+	if recognition == nil {
+		return d.raise(form, field, "noinput")
+	}
+	recognized := recognition.Recognition
+	if recognized.Status != "OK" || len(recognized.NBest) == 0 {
+		return d.raise(form, field, "nomatch")
+	}
+
+	best := recognized.NBest[0]
+	name := field.attr("name")
+	d.variables[name] = best.ResultText
+	if outs := best.NluHypothesis.OutComposite; len(outs) > 0 && outs[0].Out != "" {
+		d.variables[name] = outs[0].Out
+	}
+	d.variables[name+"$.utterance"] = best.ResultText
+	d.variables[name+"$.confidence"] = float64(best.Confidence)
+	for _, filled := range field.elements("filled") {
+		if result, err := d.execute(filled.children); err != nil || result.flow != proceed {
+			return result, err
+		}
+	}
+	for _, filled := range form.elements("filled") {
+		if !d.allFilled(form, filled) {
+			continue
+		}
+		if result, err := d.execute(filled.children); err != nil || result.flow != proceed {
+			return result, err
+		}
+	}
+	return outcome{}, nil
+}
+
+// allFilled reports whether the fields named by a form-level <filled> namelist, or every field, are filled
+func (d *execution) allFilled(form *node, filled *node) bool {
+	names := strings.Fields(filled.attr("namelist"))
+	if len(names) == 0 {
+		for _, field := range form.elements("field") {
+			names = append(names, field.attr("name"))
+		}
+	}
+	for _, name := range names {
+		if _, ok := d.variables[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// recognize records the answer to a field and recognizes it with the field's grammar, returning nil when there is no answer
+func (d *execution) recognize(field *node) (*attspeech.Recognition, error) {
+	grammar, err := fieldGrammar(field)
+	if err != nil {
+		return nil, err
+	}
+	recognizer := &dialog.ClientRecognizer{Client: d.interpreter.Client, IO: d.interpreter.IO, Timeout: d.interpreter.Timeout, Filename: field.attr("name")}
+	return recognizer.Recognize(d.ctx, grammar)
+}
+
+/*
+raise handles a nomatch or noinput event with the handler in the field,
+form or document whose count is the highest not above the number of
+times the event has been raised in the field. Without a handler the
+field is simply prompted again.
+*/
+func (d *execution) raise(form *node, field *node, event string) (outcome, error) {
+	if d.eventCounts[field] == nil {
+		d.eventCounts[field] = map[string]int{}
+	}
+	d.eventCounts[field][event]++
+	count := d.eventCounts[field][event]
+	attempts := d.eventCounts[field]["nomatch"] + d.eventCounts[field]["noinput"]
+	maxAttempts := d.interpreter.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if attempts > maxAttempts {
+		return outcome{}, errors.New("field " + field.attr("name") + " was not filled after " + strconv.Itoa(maxAttempts) + " attempts")
+	}
+
+	for _, scope := range []*node{field, form, d.document.root} {
+		handlers, err := d.counted(scope.elements(event), count)
+		if err != nil {
+			return outcome{}, err
+		}
+		if len(handlers) == 0 {
+			continue
+		}
+		result, err := d.execute(handlers[0].children)
+		switch {
+		case err != nil:
+			return outcome{}, err
+		case result.flow == proceed:
+			d.skipPrompts = true
+		case result.flow != reprompt:
+			return result, nil
+		}
+		return outcome{}, nil
+	}
+	return outcome{}, nil
+}
+
+// playPrompts plays the prompts of a field chosen by how many times it has been visited
+func (d *execution) playPrompts(field *node) error {
+	prompts, err := d.counted(field.elements("prompt"), d.promptCounts[field])
+	if err != nil {
+		return err
+	}
+	for _, prompt := range prompts {
+		if _, err := d.execute([]*node{prompt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+counted returns the elements whose cond holds and whose count is the
+highest of them not above count, elements without a count counting as 1
+*/
+func (d *execution) counted(elements []*node, count int) ([]*node, error) {
+	best := 0
+	selected := []*node{}
+	for _, element := range elements {
+		ok, err := d.condition(element)
+		if err != nil {
+			return nil, err
+		}
+		elementCount := 1
+		if value := element.attr("count"); value != "" {
+			if elementCount, err = strconv.Atoi(value); err != nil {
+				return nil, errors.New("invalid count " + strconv.Quote(value))
+			}
+		}
+		if !ok || elementCount > count || elementCount < best {
+			continue
+		}
+		if elementCount > best {
+			best = elementCount
+			selected = selected[:0]
+		}
+		selected = append(selected, element)
+	}
+	return selected, nil
+}
+
+// condition evaluates an element's cond attribute, true when it has none
+func (d *execution) condition(element *node) (bool, error) {
+	cond := element.attr("cond")
+	if cond == "" {
+		return true, nil
+	}
+	value, err := evaluate(cond, d.variables)
+	return truthy(value), err
+}
+
+// execute runs executable content, stopping at a <goto>, <exit> or <reprompt>
+func (d *execution) execute(nodes []*node) (outcome, error) {
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		var err error
+		switch n.name {
+		case "", "value":
+			// Text and values outside a <prompt> are spoken together as one
+			end := i + 1
+			for end < len(nodes) && (nodes[end].name == "" || nodes[end].name == "value") {
+				end++
+			}
+			var text string
+			if text, err = d.promptText(&node{name: "prompt", children: nodes[i:end]}); err == nil {
+				err = d.speak(text)
+			}
+			i = end - 1
+		case "prompt":
+			var ok bool
+			if ok, err = d.condition(n); err == nil && ok {
+				var text string
+				if text, err = d.promptText(n); err == nil {
+					err = d.speak(text)
+				}
+			}
+		case "var", "assign":
+			var value interface{}
+			if expr := n.attr("expr"); expr != "" {
+				value, err = evaluate(expr, d.variables)
+			}
+			if n.attr("name") == "" {
+				err = errors.New("<" + n.name + "> must have a name")
+			} else if err == nil {
+				d.variables[n.attr("name")] = value
+			}
+		case "clear":
+			for _, name := range strings.Fields(n.attr("namelist")) {
+				delete(d.variables, name)
+			}
+		case "if":
+			return d.executeIf(n, nodes[i+1:])
+		case "goto":
+			target := n.attr("next")
+			if !strings.HasPrefix(target, "#") {
+				return outcome{}, errors.New("only <goto> to a form in the same document, such as #main, is supported")
+			}
+			return outcome{flow: gotoForm, target: strings.TrimPrefix(target, "#")}, nil
+		case "exit":
+			return outcome{flow: exitDialog}, nil
+		case "reprompt":
+			return outcome{flow: reprompt}, nil
+		case "log":
+			var text string
+			if text, err = d.promptText(n); err == nil {
+				d.logger().Info("vxml", "message", text)
+			}
+		case "filled", "nomatch", "noinput", "grammar":
+			// Handled by the field they belong to
+		default:
+			err = errors.New("unsupported element <" + n.name + ">")
+		}
+		if err != nil {
+			return outcome{}, err
+		}
+	}
+	return outcome{}, nil
+}
+
+/*
+executeIf runs the branch of an <if> whose condition holds, the <elseif>
+and <else> elements dividing its children into branches, then continues
+with the content following the <if>
+*/
+func (d *execution) executeIf(element *node, rest []*node) (outcome, error) {
+	branch := []*node{}
+	taken, err := d.condition(element)
+	if err != nil {
+		return outcome{}, err
+	}
+	found := taken
+	for _, child := range element.children {
+		if child.name == "elseif" || child.name == "else" {
+			if found {
+				break
+			}
+			if taken, err = d.condition(child); err != nil {
+				return outcome{}, err
+			}
+			found = taken
+			continue
+		}
+		if taken {
+			branch = append(branch, child)
+		}
+	}
+	result, err := d.execute(branch)
+	if err != nil || result.flow != proceed {
+		return result, err
+	}
+	return d.execute(rest)
+}
+
+// promptText returns the text of a prompt, with <value> elements replaced by their values
+func (d *execution) promptText(element *node) (string, error) {
+	if element.name == "value" || (element.name == "log" && element.attr("expr") != "") {
+		value, err := evaluate(element.attr("expr"), d.variables)
+		return toString(value), err
+	}
+	var text strings.Builder
+	for _, child := range element.children {
+		if child.name == "" {
+			text.WriteString(child.text)
+			continue
+		}
+		childText, err := d.promptText(child)
+		if err != nil {
+			return "", err
+		}
+		text.WriteString(childText)
+	}
+	return strings.Join(strings.Fields(text.String()), " "), nil
+}
+
+// speak synthesizes text and plays it to the caller
+func (d *execution) speak(text string) error {
+	if text == "" {
+		return nil
+	}
+	synthesizer := &dialog.ClientSynthesizer{Client: d.interpreter.Client, IO: d.interpreter.IO, Voice: d.interpreter.Voice}
+	return synthesizer.Say(d.ctx, text)
+}
+
+// logger returns the logger for <log> messages
+func (d *execution) logger() *slog.Logger {
+	if d.interpreter.Logger != nil {
+		return d.interpreter.Logger
+	}
+	return slog.Default()
+}
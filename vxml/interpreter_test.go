@@ -0,0 +1,173 @@
+package vxml
+
+import (
+	"context"
+	"errors"
+	"github.com/jsgoecke/attspeech"
+	"github.com/jsgoecke/attspeech/audio"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const coffeeDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<vxml version="2.1" xmlns="http://www.w3.org/2001/vxml">
+  <var name="noinputs" expr="0"/>
+  <form id="order">
+    <block>Welcome to the coffee shop.</block>
+    <field name="drink">
+      <prompt>Would you like coffee or tea?</prompt>
+      <prompt count="2">Please say coffee or tea.</prompt>
+      <grammar root="drink" mode="voice"><rule id="drink"><one-of><item>coffee</item><item>tea</item></one-of></rule></grammar>
+      <nomatch>Sorry, I did not understand. <reprompt/></nomatch>
+      <noinput>I did not hear you. <assign name="noinputs" expr="noinputs + 1"/><reprompt/></noinput>
+      <filled>
+        <if cond="drink == 'tea'">
+          <goto next="#tea"/>
+        <elseif cond="drink$.confidence &lt; 0.5"/>
+          I think you said <value expr="drink"/>.
+        <else/>
+          You chose <value expr="drink"/>.
+        </if>
+      </filled>
+    </field>
+  </form>
+  <form id="tea">
+    <block>Tea is out of stock.<exit/>This is never said.</block>
+  </form>
+</vxml>`
+
+// scriptedIO answers each field with the next of its answers, an empty answer being silence
+type scriptedIO struct {
+	answers []string
+	played  int
+}
+
+// Play counts the prompts played
+func (io *scriptedIO) Play(ctx context.Context, wav []byte) error {
+	io.played++
+	return nil
+}
+
+// Record returns the next answer as AMR audio carrying its text
+func (io *scriptedIO) Record(ctx context.Context, timeout time.Duration) ([]byte, string, error) {
+	if len(io.answers) == 0 {
+		return nil, "", errors.New("no more answers")
+	}
+	answer := io.answers[0]
+	io.answers = io.answers[1:]
+	if answer == "" {
+		return nil, "", nil
+	}
+	return []byte("#!AMR\n" + answer), audio.ContentTypeAMR, nil
+}
+
+func TestInterpreter(t *testing.T) {
+	Convey("Running VoiceXML dialogs", t, func() {
+		spoken := []string{}
+		grammars := []string{}
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.Contains(req.RequestURI, attspeech.OauthResource) {
+				w.Write([]byte(`{"access_token":"123","token_type":"bearer","expires_in":500,"refresh_token":"456"}`))
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			if req.URL.Path == attspeech.TTSResource {
+				spoken = append(spoken, string(body))
+				buffer := &audio.Buffer{SampleRate: 8000, Channels: 1, Samples: make([]int16, 800)}
+				data, _ := buffer.WAV(audio.PCM)
+				w.Write(data)
+				return
+			}
+			grammars = append(grammars, string(body))
+			answer := string(body[strings.Index(string(body), "#!AMR\n")+6:])
+			answer = strings.TrimSpace(strings.Split(answer, "\r\n")[0])
+			switch answer {
+			case "coffee", "tea":
+				w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"` + answer + `","Confidence":0.9}]}}`))
+			case "cough":
+				w.Write([]byte(`{"Recognition":{"Status":"OK","NBest":[{"ResultText":"coffee","Confidence":0.3}]}}`))
+			default:
+				w.Write([]byte(`{"Recognition":{"Status":"Speech Not Recognized","NBest":[]}}`))
+			}
+		}))
+		defer api.Close()
+		client := attspeech.New("foo", "bar", api.URL)
+		So(client.SetAuthTokens(), ShouldBeNil)
+		document, err := Parse([]byte(coffeeDocument))
+		So(err, ShouldBeNil)
+		phone := &scriptedIO{}
+		interpreter := &Interpreter{Client: client, IO: phone, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+		Convey("Should prompt, recognize and run the filled handler", func() {
+			phone.answers = []string{"coffee"}
+			variables, err := interpreter.Run(context.Background(), document)
+			So(err, ShouldBeNil)
+			So(spoken, ShouldResemble, []string{"Welcome to the coffee shop.", "Would you like coffee or tea?", "You chose coffee."})
+			So(phone.played, ShouldEqual, 3)
+			So(variables["drink"], ShouldEqual, "coffee")
+			So(variables["drink$.utterance"], ShouldEqual, "coffee")
+			So(variables["drink$.confidence"], ShouldAlmostEqual, 0.9, 0.001)
+			So(grammars[0], ShouldContainSubstring, `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="drink" mode="voice">`)
+		})
+		Convey("Should take the elseif branch", func() {
+			phone.answers = []string{"cough"}
+			_, err := interpreter.Run(context.Background(), document)
+			So(err, ShouldBeNil)
+			So(spoken[len(spoken)-1], ShouldEqual, "I think you said coffee.")
+		})
+		Convey("Should handle noinput and nomatch, reprompting by count", func() {
+			phone.answers = []string{"", "mumble", "coffee"}
+			variables, err := interpreter.Run(context.Background(), document)
+			So(err, ShouldBeNil)
+			So(spoken, ShouldResemble, []string{
+				"Welcome to the coffee shop.",
+				"Would you like coffee or tea?",
+				"I did not hear you.",
+				"Please say coffee or tea.",
+				"Sorry, I did not understand.",
+				"Please say coffee or tea.",
+				"You chose coffee.",
+			})
+			So(variables["noinputs"], ShouldEqual, 1)
+		})
+		Convey("Should go to other forms and exit", func() {
+			phone.answers = []string{"tea"}
+			variables, err := interpreter.Run(context.Background(), document)
+			So(err, ShouldBeNil)
+			So(spoken[len(spoken)-1], ShouldEqual, "Tea is out of stock.")
+			So(variables["drink"], ShouldEqual, "tea")
+		})
+		Convey("Should not prompt again after a handler without reprompt", func() {
+			document, _ := Parse([]byte(`<vxml><form><field name="drink">
+				<prompt>Coffee or tea?</prompt>
+				<grammar root="drink"><rule id="drink">coffee</rule></grammar>
+				<noinput><log>silence</log></noinput>
+			</field></form></vxml>`))
+			phone.answers = []string{"", "coffee"}
+			_, err := interpreter.Run(context.Background(), document)
+			So(err, ShouldBeNil)
+			So(spoken, ShouldResemble, []string{"Coffee or tea?"})
+		})
+		Convey("Should give up after MaxAttempts", func() {
+			interpreter.MaxAttempts = 2
+			phone.answers = []string{"", "mumble", ""}
+			_, err := interpreter.Run(context.Background(), document)
+			So(err.Error(), ShouldEqual, "field drink was not filled after 2 attempts")
+		})
+		Convey("Should return errors from the IO", func() {
+			_, err := interpreter.Run(context.Background(), document)
+			So(err.Error(), ShouldEqual, "no more answers")
+		})
+		Convey("Should report unsupported elements", func() {
+			document, _ := Parse([]byte(`<vxml><form><block><submit next="/order"/></block></form></vxml>`))
+			_, err := interpreter.Run(context.Background(), document)
+			So(err.Error(), ShouldEqual, "unsupported element <submit>")
+		})
+	})
+}